@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// batchImageExtensions are the image inputs batch mode reads; outputs are
+// limited to the save formats
+var batchImageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".tif":  true,
+	".tiff": true,
+	".bmp":  true,
+	".webp": true,
+}
+
+// batchDocumentExtensions are inputs whose pages are saved as a multi-page
+// TIFF unless -format says otherwise
+var batchDocumentExtensions = map[string]bool{
+	".pdf": true,
+}
+
+// transformationSpecs collects repeated -t flags in command line order
+type transformationSpecs []string
+
+func (s *transformationSpecs) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *transformationSpecs) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+type batchJob struct {
+	inputPath  string
+	outputPath string
+}
+
+// runBatch drives the ImagePipeline without a window and returns the process exit code.
+func runBatch(args []string) int {
+	flags := flag.NewFlagSet("batch", flag.ContinueOnError)
+	var specs transformationSpecs
+	flags.Var(&specs, "t", "transformation `id[:name=value,...]`, repeat in processing order")
+	recipePath := flags.String("recipe", "", "load the transformation stack from a recipe `file` (.json, .yaml); -t steps are appended")
+	outputDir := flags.String("out", "", "output `directory` (required)")
+	format := flags.String("format", "", "output format: png, tif, jpg, webp or pdf (default: same as input, PNG for BMP and TIFF for PDF)")
+	save := DefaultSaveOptions()
+	flags.IntVar(&save.JPEGQuality, "jpeg-quality", save.JPEGQuality, "JPEG quality, 0-100")
+	flags.BoolVar(&save.JPEGProgressive, "jpeg-progressive", save.JPEGProgressive, "write progressive JPEG")
+	flags.StringVar(&save.JPEGSubsampling, "jpeg-subsampling", save.JPEGSubsampling, "JPEG chroma subsampling: 4:2:0, 4:2:2 or 4:4:4")
+	flags.IntVar(&save.PNGCompression, "png-compression", save.PNGCompression, "PNG compression level, 0-9")
+	flags.StringVar(&save.TIFFCompression, "tiff-compression", save.TIFFCompression, "TIFF compression: lzw, deflate or none")
+	flags.BoolVar(&save.TIFFGroup4, "tiff-g4", save.TIFFGroup4, "write black-and-white TIFF with CCITT Group 4")
+	flags.IntVar(&save.WebPQuality, "webp-quality", save.WebPQuality, "WebP quality, 1-100")
+	pdfOriginal := flags.Bool("pdf-original", false, "add the unprocessed pages to PDF output as a hidden layer")
+	ocrCommand := flags.String("ocr", "", "OCR `command` for the text layer of PDF output; it gets a page PNG as last argument and prints Tesseract TSV")
+	recursive := flags.Bool("recursive", false, "descend into subdirectories of input directories")
+	overwrite := flags.Bool("overwrite", false, "replace existing output files")
+	verbose := flags.Bool("debug", false, "enable pipeline and algorithm debug logging")
+	flags.Usage = func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "Usage: %s batch -out DIR [-recipe FILE] [-t ID[:name=value,...] ...] INPUT...\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(out, "INPUT may be image files, PDFs or directories of them. Multi-page inputs are\n")
+		fmt.Fprintf(out, "written as one multi-page TIFF or PDF, or as numbered files per page for other\n")
+		fmt.Fprintf(out, "formats.\n\n")
+		fmt.Fprintf(out, "Transformations:\n")
+		for _, info := range transformationRegistry.List() {
+			transformation := info.Factory(&DebugConfig{})
+			fmt.Fprintf(out, "  %-10s %s (%s): %s\n", info.ID, info.DisplayName, info.Category, info.Description)
+			for _, spec := range transformation.ParameterSpecs() {
+				fmt.Fprintf(out, "  %-10s   %-18s %-6s %-18s default %s\n", "", spec.Name, spec.Type, spec.Range(), spec.Format(spec.Default))
+				if spec.Help != "" {
+					fmt.Fprintf(out, "  %-10s   %-18s %s\n", "", "", spec.Help)
+				}
+			}
+			transformation.Close()
+		}
+		fmt.Fprintf(out, "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if *outputDir == "" || flags.NArg() == 0 || (len(specs) == 0 && *recipePath == "") {
+		flags.Usage()
+		return 2
+	}
+
+	if *format != "" {
+		*format = "." + strings.TrimPrefix(strings.ToLower(*format), ".")
+		if !isSaveFormat(*format) && *format != ".pdf" {
+			fmt.Fprintf(os.Stderr, "batch: unsupported output format %q\n", *format)
+			return 2
+		}
+	}
+
+	config := &DebugConfig{}
+	if *verbose {
+		config = &debugConfig
+	}
+
+	if err := save.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "batch: %v\n", err)
+		return 2
+	}
+
+	options := ExportOptions{Save: save, OriginalLayer: *pdfOriginal}
+	if *ocrCommand != "" {
+		recognizer, err := newCommandRecognizer(*ocrCommand)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "batch: %v\n", err)
+			return 2
+		}
+		options.Recognizer = recognizer
+	}
+
+	transformations := make([]Transformation, 0, len(specs))
+	var bypassed []int
+	if *recipePath != "" {
+		recipe, err := LoadRecipe(*recipePath)
+		if err == nil {
+			transformations, err = recipe.Build(config)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "batch: %v\n", err)
+			return 2
+		}
+		for i, step := range recipe.Transformations {
+			if step.Bypassed {
+				bypassed = append(bypassed, i)
+			}
+		}
+	}
+	for _, spec := range specs {
+		transformation, err := parseTransformationSpec(spec, config)
+		if err != nil {
+			for _, created := range transformations {
+				created.Close()
+			}
+			fmt.Fprintf(os.Stderr, "batch: %v\n", err)
+			return 2
+		}
+		transformations = append(transformations, transformation)
+	}
+
+	jobs, err := collectBatchJobs(flags.Args(), *outputDir, *format, *recursive)
+	if err != nil {
+		for _, created := range transformations {
+			created.Close()
+		}
+		fmt.Fprintf(os.Stderr, "batch: %v\n", err)
+		return 2
+	}
+	if len(jobs) == 0 {
+		for _, created := range transformations {
+			created.Close()
+		}
+		fmt.Fprintln(os.Stderr, "batch: no input images found")
+		return 2
+	}
+
+	pipeline := NewImagePipeline(config)
+	defer pipeline.Close()
+	pipeline.SetPreviewEnabled(false)
+	// Every image starts from scratch, so cached intermediate steps are never reused
+	pipeline.SetCacheBudget(0)
+
+	if err := pipeline.SetTransformations(transformations); err != nil {
+		fmt.Fprintf(os.Stderr, "batch: %v\n", err)
+		return 2
+	}
+	for _, index := range bypassed {
+		if err := pipeline.SetTransformationBypassed(index, true); err != nil {
+			fmt.Fprintf(os.Stderr, "batch: %v\n", err)
+			return 2
+		}
+	}
+
+	fmt.Printf("Processing %d image(s) with %d transformation(s)\n", len(jobs), len(transformations))
+
+	failures := 0
+	batchStart := time.Now()
+	for i, job := range jobs {
+		start := time.Now()
+		outputs, err := processBatchJob(pipeline, job, *overwrite, options)
+		if err != nil {
+			failures++
+			fmt.Fprintf(os.Stderr, "[%d/%d] FAILED %s: %v\n", i+1, len(jobs), job.inputPath, err)
+			continue
+		}
+		output := outputs[0]
+		if len(outputs) > 1 {
+			output = fmt.Sprintf("%s ... %s (%d files)", outputs[0], outputs[len(outputs)-1], len(outputs))
+		}
+		fmt.Printf("[%d/%d] %s -> %s (%v)\n", i+1, len(jobs), job.inputPath, output, time.Since(start).Round(time.Millisecond))
+	}
+
+	fmt.Printf("Done: %d succeeded, %d failed in %v\n", len(jobs)-failures, failures, time.Since(batchStart).Round(time.Millisecond))
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
+
+// processBatchJob runs every page of the job's input through pipeline and
+// returns the files written
+func processBatchJob(pipeline *ImagePipeline, job batchJob, overwrite bool, options ExportOptions) ([]string, error) {
+	document, err := openDocument(job.inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !overwrite {
+		for _, path := range documentOutputPaths(document, job.outputPath) {
+			if _, err := os.Stat(path); err == nil {
+				return nil, fmt.Errorf("output %s already exists (use -overwrite)", path)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(job.outputPath), 0o755); err != nil {
+		return nil, err
+	}
+	options.Warn = func(warning string) {
+		fmt.Fprintf(os.Stderr, "batch: warning: %s: %s\n", job.inputPath, warning)
+	}
+	return exportDocument(context.Background(), pipeline, document, job.outputPath, options)
+}
+
+// collectBatchJobs expands input files and directories into input/output pairs.
+// Directory inputs keep their relative layout below the output directory.
+func collectBatchJobs(inputs []string, outputDir, format string, recursive bool) ([]batchJob, error) {
+	var jobs []batchJob
+	seen := make(map[string]bool)
+
+	addJob := func(inputPath, relPath string) error {
+		ext := filepath.Ext(relPath)
+		outExt := format
+		if outExt == "" {
+			outExt = strings.ToLower(ext)
+			if batchDocumentExtensions[outExt] {
+				outExt = ".tif"
+			} else if !isSaveFormat(outExt) {
+				// BMP is read but not written
+				outExt = ".png"
+			}
+		}
+		outputPath := filepath.Join(outputDir, strings.TrimSuffix(relPath, ext)+outExt)
+		if seen[outputPath] {
+			return fmt.Errorf("several inputs map to output %s", outputPath)
+		}
+		seen[outputPath] = true
+		jobs = append(jobs, batchJob{inputPath: inputPath, outputPath: outputPath})
+		return nil
+	}
+
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			if err := addJob(input, filepath.Base(input)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var found []string
+		err = filepath.WalkDir(input, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				if path != input && !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if ext := strings.ToLower(filepath.Ext(path)); batchImageExtensions[ext] || batchDocumentExtensions[ext] {
+				found = append(found, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(found)
+		for _, path := range found {
+			relPath, err := filepath.Rel(input, path)
+			if err != nil {
+				return nil, err
+			}
+			if err := addJob(path, relPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return jobs, nil
+}
+
+// parseTransformationSpec builds a transformation from "id:name=value,name=value".
+// Values are parsed and checked with the transformation's parameter specs.
+func parseTransformationSpec(spec string, config *DebugConfig) (Transformation, error) {
+	id, paramList, _ := strings.Cut(spec, ":")
+	id = strings.TrimSpace(id)
+	transformation, err := transformationRegistry.New(id, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(paramList) == "" {
+		return transformation, nil
+	}
+
+	specs := transformation.ParameterSpecs()
+	params := make(map[string]interface{})
+	for _, pair := range strings.Split(paramList, ",") {
+		name, raw, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			transformation.Close()
+			return nil, fmt.Errorf("%s: malformed parameter %q (expected name=value)", id, pair)
+		}
+
+		parameter, ok := findParameterSpec(specs, name)
+		if !ok {
+			transformation.Close()
+			return nil, fmt.Errorf("%s: unknown parameter %q", id, name)
+		}
+		value, err := parameter.Parse(raw)
+		if err != nil {
+			transformation.Close()
+			return nil, fmt.Errorf("%s: %w", id, err)
+		}
+		params[name] = value
+	}
+
+	if err := applyParameters(transformation, params); err != nil {
+		transformation.Close()
+		return nil, fmt.Errorf("%s: %w", id, err)
+	}
+
+	return transformation, nil
+}
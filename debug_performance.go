@@ -17,7 +17,7 @@ type DebugPerformance struct {
 	stackMutex           sync.RWMutex
 	hangDetectionEnabled bool
 	hangThreshold        time.Duration
-	activeOperations     map[string]*ActiveOperation
+	activeOperations     map[string][]*ActiveOperation
 	activeOpMutex        sync.RWMutex
 	goroutineTracker     *GoroutineTracker
 
@@ -62,7 +62,7 @@ func NewDebugPerformance(config *DebugConfig) *DebugPerformance {
 		operationStack:       make([]OperationEntry, 0),
 		hangDetectionEnabled: true,
 		hangThreshold:        30 * time.Second,
-		activeOperations:     make(map[string]*ActiveOperation),
+		activeOperations:     make(map[string][]*ActiveOperation),
 		goroutineTracker:     &GoroutineTracker{initialCount: runtime.NumGoroutine()},
 		lastLoggedVariance:   make(map[string]float64),
 		lastLogTime:          make(map[string]time.Time),
@@ -98,14 +98,14 @@ func (d *DebugPerformance) StartOperation(name, contextStr string) context.Conte
 		ctx, cancel := context.WithTimeout(context.Background(), d.hangThreshold)
 
 		d.activeOpMutex.Lock()
-		d.activeOperations[name] = &ActiveOperation{
+		d.activeOperations[name] = append(d.activeOperations[name], &ActiveOperation{
 			Name:      name,
 			StartTime: entry.StartTime,
 			Context:   ctx,
 			Cancel:    cancel,
 			ThreadID:  entry.ThreadID,
 			MatCount:  entry.MatCount,
-		}
+		})
 		d.activeOpMutex.Unlock()
 
 		go d.monitorOperation(name, ctx)
@@ -127,16 +127,21 @@ func (d *DebugPerformance) EndOperation(name string) {
 		return
 	}
 
+	// Tiles run operations concurrently, so the operation is not always on
+	// top of the stack; end the most recent one with this name
 	lastIdx := len(d.operationStack) - 1
-	entry := d.operationStack[lastIdx]
-
-	if entry.Name != name {
-		log.Printf("[PERF DEBUG] WARNING: EndOperation mismatch! Expected '%s', got '%s'", name, entry.Name)
+	for lastIdx >= 0 && d.operationStack[lastIdx].Name != name {
+		lastIdx--
+	}
+	if lastIdx < 0 {
+		log.Printf("[PERF DEBUG] WARNING: EndOperation called for '%s' but it was never started", name)
 		log.Printf("[PERF DEBUG] Current stack:")
 		for i, op := range d.operationStack {
 			log.Printf("[PERF DEBUG]   [%d] %s (started %v ago)", i, op.Name, time.Since(op.StartTime))
 		}
+		return
 	}
+	entry := d.operationStack[lastIdx]
 
 	duration := time.Since(entry.StartTime)
 	var m runtime.MemStats
@@ -158,13 +163,17 @@ func (d *DebugPerformance) EndOperation(name string) {
 		log.Printf("[PERF DEBUG] POTENTIAL LEAK: %s created %d Mat(s) that weren't cleaned up", name, matDelta)
 	}
 
-	d.operationStack = d.operationStack[:lastIdx]
+	d.operationStack = append(d.operationStack[:lastIdx], d.operationStack[lastIdx+1:]...)
 
 	if d.hangDetectionEnabled {
 		d.activeOpMutex.Lock()
-		if activeOp, exists := d.activeOperations[name]; exists {
-			activeOp.Cancel()
-			delete(d.activeOperations, name)
+		if active := d.activeOperations[name]; len(active) > 0 {
+			active[len(active)-1].Cancel()
+			if len(active) == 1 {
+				delete(d.activeOperations, name)
+			} else {
+				d.activeOperations[name] = active[:len(active)-1]
+			}
 		}
 		d.activeOpMutex.Unlock()
 	}
@@ -386,9 +395,11 @@ func (d *DebugPerformance) GetActiveOperations() []string {
 	defer d.activeOpMutex.RUnlock()
 
 	operations := make([]string, 0, len(d.activeOperations))
-	for name, op := range d.activeOperations {
-		duration := time.Since(op.StartTime)
-		operations = append(operations, fmt.Sprintf("%s (running %v)", name, duration))
+	for name, active := range d.activeOperations {
+		for _, op := range active {
+			duration := time.Since(op.StartTime)
+			operations = append(operations, fmt.Sprintf("%s (running %v)", name, duration))
+		}
 	}
 	return operations
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func operationNames(d *DebugPerformance) []string {
+	d.stackMutex.RLock()
+	defer d.stackMutex.RUnlock()
+
+	names := make([]string, len(d.operationStack))
+	for i, entry := range d.operationStack {
+		names[i] = entry.Name
+	}
+	return names
+}
+
+func TestEndOperationOutOfOrder(t *testing.T) {
+	d := NewDebugPerformance(&DebugConfig{Pipeline: true})
+
+	d.StartOperation("outer", "")
+	d.StartOperation("tile", "1")
+	d.StartOperation("tile", "2")
+	d.StartOperation("inner", "")
+
+	// Concurrent tiles end before operations started after them
+	d.EndOperation("tile")
+	if got := fmt.Sprint(operationNames(d)); got != "[outer tile inner]" {
+		t.Errorf("stack %s after ending a tile, want [outer tile inner]", got)
+	}
+
+	d.EndOperation("unknown")
+	if got := fmt.Sprint(operationNames(d)); got != "[outer tile inner]" {
+		t.Errorf("stack %s after ending an unknown operation, want it unchanged", got)
+	}
+
+	d.EndOperation("outer")
+	d.EndOperation("inner")
+	d.EndOperation("tile")
+	if names := operationNames(d); len(names) != 0 {
+		t.Errorf("stack %v after ending everything, want it empty", names)
+	}
+	d.EndOperation("tile")
+
+	d.activeOpMutex.RLock()
+	defer d.activeOpMutex.RUnlock()
+	if len(d.activeOperations) != 0 {
+		t.Errorf("%d operations still watched for hangs", len(d.activeOperations))
+	}
+}
+
+func TestEndOperationConcurrent(t *testing.T) {
+	d := NewDebugPerformance(&DebugConfig{Pipeline: true})
+	d.StartOperation("outer", "")
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				d.StartOperation("tile", fmt.Sprint(worker))
+				d.EndOperation("tile")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := fmt.Sprint(operationNames(d)); got != "[outer]" {
+		t.Errorf("stack %s after concurrent tiles, want [outer]", got)
+	}
+}
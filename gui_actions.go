@@ -2,13 +2,15 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/widget"
-	"gocv.io/x/gocv"
 )
 
 func (ui *ImageRestorationUI) openImage() {
@@ -23,44 +25,16 @@ func (ui *ImageRestorationUI) openImage() {
 		ui.debugGUI.LogFileOperation("open", reader.URI().Name())
 
 		go func() {
-			mat := gocv.IMRead(reader.URI().Path(), gocv.IMReadColor)
-			defer mat.Close()
-
-			if mat.Empty() {
-				err := fmt.Errorf("failed to load image")
-				ui.debugGUI.LogError(err)
-				fyne.Do(func() {
-					dialog.ShowError(err, ui.window)
-				})
-				return
-			}
-
-			size := mat.Size()
-			ui.debugGUI.LogImageInfo(size[1], size[0], mat.Channels())
-
-			ui.pipeline.ClearTransformations()
-
-			clonedMat := mat.Clone()
-			err = ui.pipeline.SetOriginalImage(clonedMat)
+			document, err := openDocument(reader.URI().Path())
 			if err != nil {
-				clonedMat.Close()
 				ui.debugGUI.LogError(err)
 				fyne.Do(func() {
 					dialog.ShowError(err, ui.window)
 				})
 				return
 			}
-
-			fyne.Do(func() {
-				ui.updateUI()
-				ui.updateWindowTitle(reader.URI().Name())
-
-				ui.parametersContainer.Objects[0] = widget.NewLabel("Select a Transformation")
-				ui.parametersContainer.Refresh()
-				ui.transformationsList.UnselectAll()
-			})
-
-			ui.debugGUI.Log("Image loaded successfully")
+			ui.debugGUI.Log(fmt.Sprintf("Document has %d page(s)", document.PageCount()))
+			ui.loadPage(document, 0)
 		}()
 	}, ui.window)
 }
@@ -72,69 +46,83 @@ func (ui *ImageRestorationUI) saveImage() {
 		dialog.ShowInformation("No Image", "Please load an image first", ui.window)
 		return
 	}
+	if ui.exportRunning() {
+		return
+	}
 
 	dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
 		if err != nil || writer == nil {
 			ui.debugGUI.LogError(err)
 			return
 		}
-		defer writer.Close()
+		writer.Close()
 
-		filename := writer.URI().Name()
 		filePath := writer.URI().Path()
+		ui.debugGUI.LogFileOperation("save", filepath.Base(filePath))
 
-		ui.debugGUI.LogFileOperation("save", filename)
-
-		ext := strings.ToLower(filepath.Ext(filename))
-		ui.debugGUI.LogFileExtensionCheck(filename, ext, ext != "")
-
-		if ext == "" {
-			filePath = filePath + ".png"
-			filename = filename + ".png"
-			ui.debugGUI.Log("Added .png extension to filename")
-		} else if ext != ".png" && ext != ".jpg" && ext != ".jpeg" && ext != ".tiff" && ext != ".tif" {
-			filePath = strings.TrimSuffix(filePath, ext) + ".png"
-			filename = strings.TrimSuffix(filename, ext) + ".png"
+		ext := strings.ToLower(filepath.Ext(filePath))
+		ui.debugGUI.LogFileExtensionCheck(filepath.Base(filePath), ext, isSaveFormat(ext))
+		if !isSaveFormat(ext) {
+			ext = savedSaveFormat()
 		}
 
-		go func() {
-			ui.debugGUI.Log("Forcing full reprocessing before save to ensure latest parameters")
-			err := ui.pipeline.ProcessImage()
-			if err != nil {
-				ui.debugGUI.LogError(fmt.Errorf("failed to reprocess image before save: %w", err))
-				fyne.Do(func() {
-					dialog.ShowError(err, ui.window)
-				})
-				return
+		// The options dialog settles the format; a name the dialog changed
+		// leaves the file the save dialog created unused
+		ui.showSaveOptions(ext, func(ext string, options SaveOptions) {
+			if !strings.EqualFold(filepath.Ext(filePath), ext) {
+				os.Remove(filePath)
+				filePath = strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ext
+				ui.debugGUI.Log("Saving as " + filepath.Base(filePath))
 			}
+			ui.writeProcessedImage(filePath, options)
+		})
+	}, ui.window)
+}
 
-			processedImage := ui.pipeline.GetProcessedImage()
-			defer processedImage.Close()
+// writeProcessedImage saves the open image through the current
+// transformations to filePath, first offering PNG for a black-and-white
+// result in a lossy format
+func (ui *ImageRestorationUI) writeProcessedImage(filePath string, options SaveOptions) {
+	source := filePath
+	if ui.document != nil {
+		source = ui.document.Path
+	}
 
-			hasImage := !processedImage.Empty()
-			ui.debugGUI.LogSaveOperation(filename, filepath.Ext(filename), hasImage)
+	// The preview ends in the same kind of image as the full resolution
+	preview := ui.pipeline.GetPreviewImage()
+	warning := bilevelWarning(strings.ToLower(filepath.Ext(filePath)), preview)
+	preview.Close()
 
-			if !hasImage {
-				ui.debugGUI.LogSaveResult(filename, false, "no processed image available")
-				fyne.Do(func() {
-					dialog.ShowError(fmt.Errorf("no processed image available"), ui.window)
-				})
-				return
-			}
+	go ui.confirmBilevelFormat(filePath, warning, func(filePath string) {
+		ui.saveSnapshot(source, filePath, options)
+	})
+}
 
-			success := gocv.IMWrite(filePath, processedImage)
-			if !success {
-				err := fmt.Errorf("failed to write image to %s", filePath)
-				ui.debugGUI.LogSaveResult(filename, false, err.Error())
-				fyne.Do(func() {
-					dialog.ShowError(err, ui.window)
-				})
-			} else {
-				ui.debugGUI.LogSaveResult(filename, true, "")
-				ui.debugGUI.Log("Image saved successfully")
+// confirmBilevelFormat calls save with filePath, first offering PNG in its
+// place when warning says the chosen format spoils a black-and-white
+// image. The file the save dialog created for a replaced name is removed.
+func (ui *ImageRestorationUI) confirmBilevelFormat(filePath, warning string, save func(filePath string)) {
+	if warning == "" {
+		save(filePath)
+		return
+	}
+
+	ui.debugGUI.Log("Bilevel image in a lossy format: " + filePath)
+	pngPath := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".png"
+	fyne.Do(func() {
+		message := widget.NewLabel(warning + "\n\nSave as " + filepath.Base(pngPath) + " instead?")
+		message.Wrapping = fyne.TextWrapWord
+		confirm := dialog.NewCustomConfirm("Black-and-White Image", "Save as PNG", "Keep "+saveFormatName(strings.ToLower(filepath.Ext(filePath))), message, func(usePNG bool) {
+			if usePNG {
+				os.Remove(filePath)
+				filePath = pngPath
 			}
-		}()
-	}, ui.window)
+			ui.debugGUI.Log("Saving bilevel image as " + filepath.Base(filePath))
+			go save(filePath)
+		}, ui.window)
+		confirm.Resize(fyne.NewSize(420, 0))
+		confirm.Show()
+	})
 }
 
 func (ui *ImageRestorationUI) resetTransformations() {
@@ -148,3 +136,136 @@ func (ui *ImageRestorationUI) resetTransformations() {
 		ui.transformationsList.UnselectAll()
 	})
 }
+
+func (ui *ImageRestorationUI) saveRecipe() {
+	ui.debugGUI.LogButtonClick("SAVE RECIPE")
+
+	recipe, err := ui.pipeline.Recipe()
+	if err != nil {
+		ui.debugGUI.LogError(err)
+		dialog.ShowError(err, ui.window)
+		return
+	}
+	if len(recipe.Transformations) == 0 {
+		dialog.ShowInformation("No Transformations", "Add transformations before saving a recipe", ui.window)
+		return
+	}
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			ui.debugGUI.LogError(err)
+			return
+		}
+		defer writer.Close()
+
+		ui.debugGUI.LogFileOperation("save recipe", writer.URI().Name())
+
+		data, err := EncodeRecipe(recipe, isYAMLRecipe(writer.URI().Name()))
+		if err == nil {
+			_, err = writer.Write(data)
+		}
+		if err != nil {
+			ui.debugGUI.LogError(err)
+			dialog.ShowError(fmt.Errorf("failed to save recipe: %w", err), ui.window)
+			return
+		}
+
+		ui.debugGUI.Log("Recipe saved successfully")
+	}, ui.window)
+	saveDialog.SetFileName("recipe.json")
+	saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".json", ".yaml", ".yml"}))
+	saveDialog.Show()
+}
+
+func (ui *ImageRestorationUI) loadRecipe() {
+	ui.debugGUI.LogButtonClick("LOAD RECIPE")
+
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil || reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		name := reader.URI().Name()
+		ui.debugGUI.LogFileOperation("load recipe", name)
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			ui.debugGUI.LogError(err)
+			dialog.ShowError(err, ui.window)
+			return
+		}
+
+		recipe, err := DecodeRecipe(data, isYAMLRecipe(name))
+		if err != nil {
+			err = fmt.Errorf("failed to parse recipe %s: %w", name, err)
+			ui.debugGUI.LogError(err)
+			dialog.ShowError(err, ui.window)
+			return
+		}
+
+		go func() {
+			if err := ui.pipeline.ApplyRecipe(recipe); err != nil {
+				ui.debugGUI.LogError(err)
+				fyne.Do(func() {
+					dialog.ShowError(err, ui.window)
+				})
+				return
+			}
+
+			fyne.Do(func() {
+				ui.updateUI()
+				ui.parametersContainer.Objects[0] = widget.NewLabel("Select a Transformation")
+				ui.parametersContainer.Refresh()
+				ui.transformationsList.UnselectAll()
+			})
+
+			ui.debugGUI.Log("Recipe loaded successfully")
+		}()
+	}, ui.window)
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".json", ".yaml", ".yml"}))
+	openDialog.Show()
+}
+
+func (ui *ImageRestorationUI) undo() {
+	ui.debugGUI.LogButtonClick("Undo")
+	if ui.pipeline.CanUndo() {
+		ui.stepHistory(ui.pipeline.Undo)
+	}
+}
+
+func (ui *ImageRestorationUI) redo() {
+	ui.debugGUI.LogButtonClick("Redo")
+	if ui.pipeline.CanRedo() {
+		ui.stepHistory(ui.pipeline.Redo)
+	}
+}
+
+// stepHistory runs an undo or redo step and refreshes the parameters panel,
+// since the selected transformation may have changed or disappeared.
+func (ui *ImageRestorationUI) stepHistory(step func() (string, error)) {
+	go func() {
+		description, err := step()
+		if err != nil {
+			ui.debugGUI.LogError(err)
+		} else {
+			ui.debugGUI.Log(fmt.Sprintf("History step: %s", description))
+		}
+
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, ui.window)
+			}
+			ui.updateUI()
+
+			selected := ui.selectedTransformation
+			if selected >= 0 && selected < len(ui.pipeline.transformations) {
+				ui.showTransformationParameters(ui.pipeline.transformations[selected])
+				return
+			}
+			ui.parametersContainer.Objects[0] = widget.NewLabel("Select a Transformation")
+			ui.parametersContainer.Refresh()
+			ui.transformationsList.UnselectAll()
+		})
+	}()
+}
@@ -51,19 +51,39 @@ func (ui *ImageRestorationUI) createCenterPanel() fyne.CanvasObject {
 	ui.transformationsList = widget.NewList(
 		func() int { return len(ui.pipeline.transformations) },
 		func() fyne.CanvasObject {
-			return container.NewBorder(
-				nil, nil, nil,
+			handle := newDragHandle()
+			row := container.NewBorder(
+				nil, nil,
+				container.NewHBox(handle, widget.NewCheck("", nil)),
 				widget.NewButtonWithIcon("", theme.ContentRemoveIcon(), nil),
 				widget.NewLabel("Transformation"),
 			)
+			handle.row = row
+			return row
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
 			borderContainer := obj.(*fyne.Container)
 			label := borderContainer.Objects[0].(*widget.Label)
-			removeBtn := borderContainer.Objects[1].(*widget.Button)
+			controls := borderContainer.Objects[1].(*fyne.Container)
+			handle := controls.Objects[0].(*dragHandle)
+			enabledCheck := controls.Objects[1].(*widget.Check)
+			removeBtn := borderContainer.Objects[2].(*widget.Button)
 
 			if id < len(ui.pipeline.transformations) {
+				bypassed := ui.pipeline.IsTransformationBypassed(id)
+				label.Importance = widget.MediumImportance
+				if bypassed {
+					label.Importance = widget.LowImportance
+				}
 				label.SetText(ui.pipeline.transformations[id].Name())
+				handle.onDropped = func(rows int) {
+					ui.moveTransformation(id, id+rows)
+				}
+				enabledCheck.OnChanged = nil
+				enabledCheck.SetChecked(!bypassed)
+				enabledCheck.OnChanged = func(enabled bool) {
+					ui.setTransformationBypassed(id, !enabled)
+				}
 				removeBtn.OnTapped = func() {
 					ui.removeTransformation(id)
 				}
@@ -71,6 +91,9 @@ func (ui *ImageRestorationUI) createCenterPanel() fyne.CanvasObject {
 		},
 	)
 	ui.transformationsList.OnSelected = ui.onAppliedTransformationSelected
+	ui.transformationsList.OnUnselected = func(widget.ListItemID) {
+		ui.selectedTransformation = -1
+	}
 
 	transformationsListContainer := container.NewBorder(
 		makeHeader("ACTIVE TRANSFORMATIONS"), nil, nil, nil,
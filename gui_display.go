@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// displayTone is the part of the sample range, as fractions of the depth's
+// maximum, that the screen shows from black to white
+type displayTone struct {
+	low, high float64
+}
+
+var fullTone = displayTone{low: 0, high: 1}
+
+// toneOf stretches deeper colour samples from the darkest to the brightest,
+// as 16-bit scans often use only part of their range. 8-bit images are
+// shown as they are.
+func toneOf(mat gocv.Mat) displayTone {
+	depth := matDepth(mat)
+	if depth == gocv.MatTypeCV8U {
+		return fullTone
+	}
+
+	planes := gocv.Split(mat)
+	defer func() {
+		for _, plane := range planes {
+			plane.Close()
+		}
+	}()
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, plane := range planes[:colourChannels(len(planes))] {
+		minVal, maxVal, _, _ := gocv.MinMaxLoc(plane)
+		low = math.Min(low, float64(minVal))
+		high = math.Max(high, float64(maxVal))
+	}
+	if high <= low {
+		return fullTone
+	}
+	return displayTone{low: low / depthMax(depth), high: high / depthMax(depth)}
+}
+
+// displayMat returns an 8-bit copy of mat for the screen, its colour
+// samples mapped with tone; alpha is scaled as it is. Both panes use the
+// tone of the original, so the preview of any depth is shown at the same
+// brightness. The pipeline keeps working on the original samples.
+func displayMat(mat gocv.Mat, tone displayTone) (gocv.Mat, error) {
+	if tone == fullTone {
+		return convertDepth(mat, gocv.MatTypeCV8U)
+	}
+
+	planes := gocv.Split(mat)
+	defer func() {
+		for _, plane := range planes {
+			plane.Close()
+		}
+	}()
+
+	maxValue := depthMax(matDepth(mat))
+	low := tone.low * maxValue
+	scale := 255 / ((tone.high - tone.low) * maxValue)
+
+	colour := colourChannels(len(planes))
+	display := make([]gocv.Mat, len(planes))
+	for i, plane := range planes {
+		var err error
+		if i < colour {
+			display[i] = gocv.NewMat()
+			err = plane.ConvertToWithParams(&display[i], gocv.MatTypeCV8U, float32(scale), float32(-low*scale))
+		} else {
+			display[i], err = convertDepth(plane, gocv.MatTypeCV8U)
+		}
+		defer display[i].Close()
+		if err != nil {
+			return gocv.NewMat(), openCVError("display conversion", err)
+		}
+	}
+
+	result := gocv.NewMat()
+	if err := gocv.Merge(display, &result); err != nil {
+		result.Close()
+		return gocv.NewMat(), openCVError("display conversion", err)
+	}
+	return result, nil
+}
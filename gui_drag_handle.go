@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// dragHandle is the grip at the start of an active transformation row.
+// Dragging it vertically moves the row by whole row heights on release.
+type dragHandle struct {
+	widget.BaseWidget
+	row       fyne.CanvasObject
+	onDropped func(rows int)
+	dragged   float32
+}
+
+func newDragHandle() *dragHandle {
+	handle := &dragHandle{}
+	handle.ExtendBaseWidget(handle)
+	return handle
+}
+
+func (h *dragHandle) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(widget.NewIcon(theme.MenuIcon()))
+}
+
+func (h *dragHandle) Cursor() desktop.Cursor {
+	return desktop.VResizeCursor
+}
+
+func (h *dragHandle) Dragged(event *fyne.DragEvent) {
+	h.dragged += event.Dragged.DY
+}
+
+func (h *dragHandle) DragEnd() {
+	distance := h.dragged
+	h.dragged = 0
+
+	rowHeight := h.MinSize().Height
+	if h.row != nil {
+		rowHeight = h.row.Size().Height
+	}
+	rowHeight += theme.Padding()
+
+	rows := int(math.Round(float64(distance / rowHeight)))
+	if rows != 0 && h.onDropped != nil {
+		h.onDropped(rows)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Saves and exports run as export jobs: one at a time, in the background,
+// with their own progress bar beside the preview's. A job renders a
+// snapshot of the image and transformation stack on a pipeline of its own,
+// so edits made while it runs change neither the file being written nor
+// have to wait for the full-resolution render.
+
+func (ui *ImageRestorationUI) createExportIndicator() fyne.CanvasObject {
+	ui.exportLabel = widget.NewLabel("")
+	ui.exportBar = widget.NewProgressBar()
+	ui.exportCancel = widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), ui.cancelExport)
+
+	ui.exportBox = container.NewHBox(
+		ui.exportLabel,
+		container.NewGridWrap(fyne.NewSize(200, ui.exportBar.MinSize().Height), ui.exportBar),
+		ui.exportCancel,
+	)
+	ui.exportBox.Hide()
+	return ui.exportBox
+}
+
+// exportRunning reports whether an export job is running, telling the user
+// so when it is
+func (ui *ImageRestorationUI) exportRunning() bool {
+	ui.exportMutex.Lock()
+	running := ui.exportJobCancel != nil
+	ui.exportMutex.Unlock()
+
+	if running {
+		fyne.Do(func() {
+			dialog.ShowInformation("Export Running", "Please wait for the current export to finish or cancel it", ui.window)
+		})
+	}
+	return running
+}
+
+// startExportJob shows the export progress for a job called name. The
+// returned context is cancelled by the Cancel button and reports progress
+// and stages to the indicator; finish must be called when the job ends. It
+// returns false without starting when another export job is running.
+func (ui *ImageRestorationUI) startExportJob(name string) (context.Context, func(), bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ui.exportMutex.Lock()
+	if ui.exportJobCancel != nil {
+		ui.exportMutex.Unlock()
+		cancel()
+		return nil, nil, false
+	}
+	ui.exportJobCancel = cancel
+	ui.exportMutex.Unlock()
+
+	ui.debugGUI.LogUIEvent("Export started: " + name)
+
+	// Like the preview's, progress is forwarded in percent-sized steps
+	var lastReported float64
+	ctx = WithProgress(ctx, func(fraction float64) {
+		ui.exportMutex.Lock()
+		if fraction-lastReported < 0.01 && fraction < 1 {
+			ui.exportMutex.Unlock()
+			return
+		}
+		lastReported = fraction
+		ui.exportMutex.Unlock()
+
+		fyne.Do(func() {
+			ui.exportBar.SetValue(fraction)
+		})
+	})
+	ctx = WithStage(ctx, func(stage string) {
+		ui.debugGUI.Log(name + ": " + stage)
+		fyne.Do(func() {
+			ui.exportLabel.SetText(name + ": " + stage)
+		})
+	})
+
+	fyne.Do(func() {
+		ui.exportLabel.SetText(name)
+		ui.exportBar.SetValue(0)
+		ui.exportCancel.Enable()
+		ui.exportBox.Show()
+	})
+
+	finish := func() {
+		cancel()
+		ui.exportMutex.Lock()
+		ui.exportJobCancel = nil
+		ui.exportMutex.Unlock()
+
+		fyne.Do(func() {
+			ui.exportBox.Hide()
+		})
+	}
+	return ctx, finish, true
+}
+
+func (ui *ImageRestorationUI) cancelExport() {
+	ui.debugGUI.LogButtonClick("Cancel export")
+
+	ui.exportMutex.Lock()
+	defer ui.exportMutex.Unlock()
+
+	if ui.exportJobCancel != nil {
+		ui.exportJobCancel()
+		ui.exportLabel.SetText("Cancelling...")
+		ui.exportCancel.Disable()
+	}
+}
+
+// runExport writes document through the current transformations to
+// filePath as an export job and reports the result. release, if not nil,
+// is called when the document is no longer needed.
+func (ui *ImageRestorationUI) runExport(document *Document, filePath string, options ExportOptions, release func()) {
+	if release != nil {
+		defer release()
+	}
+
+	// Pages written one per file, or none at all, leave the name the save
+	// dialog created unused
+	var paths []string
+	defer func() {
+		if !slices.Contains(paths, filePath) {
+			os.Remove(filePath)
+		}
+	}()
+
+	recipe, err := ui.pipeline.Recipe()
+	if err != nil {
+		ui.debugGUI.LogError(err)
+		fyne.Do(func() {
+			dialog.ShowError(err, ui.window)
+		})
+		return
+	}
+
+	name := "Saving " + filepath.Base(filePath)
+	if document.PageCount() > 1 {
+		name = fmt.Sprintf("Exporting %d pages", document.PageCount())
+	}
+	ctx, finish, ok := ui.startExportJob(name)
+	if !ok {
+		ui.exportRunning()
+		return
+	}
+
+	pipeline := NewImagePipeline(ui.pipeline.config)
+	pipeline.SetPreviewEnabled(false)
+	pipeline.SetCacheBudget(0)
+	err = pipeline.ApplyRecipe(recipe)
+	if err == nil {
+		paths, err = exportDocument(ctx, pipeline, document, filePath, options)
+	}
+	pipeline.Close()
+	finish()
+
+	if errors.Is(err, context.Canceled) {
+		ui.debugGUI.Log("Export cancelled")
+		fyne.Do(func() {
+			dialog.ShowInformation("Export Cancelled", "The export to "+filePath+" was cancelled", ui.window)
+		})
+		return
+	}
+	if err != nil {
+		ui.debugGUI.LogSaveResult(filepath.Base(filePath), false, err.Error())
+		fyne.Do(func() {
+			dialog.ShowError(err, ui.window)
+		})
+		return
+	}
+
+	ui.debugGUI.LogSaveResult(filepath.Base(filePath), true, "")
+	ui.debugGUI.Log(fmt.Sprintf("Exported %d page(s) to %d file(s)", document.PageCount(), len(paths)))
+	message := "Saved to " + paths[0]
+	switch {
+	case len(paths) > 1:
+		message = fmt.Sprintf("Exported %d pages to %d files in %s:\n%s ... %s", document.PageCount(), len(paths),
+			filepath.Dir(paths[0]), filepath.Base(paths[0]), filepath.Base(paths[len(paths)-1]))
+	case document.PageCount() > 1:
+		message = fmt.Sprintf("Exported %d pages to %s", document.PageCount(), paths[0])
+	}
+	fyne.Do(func() {
+		dialog.ShowInformation("Export Complete", message, ui.window)
+	})
+}
+
+// saveSnapshot saves the open image, loaded from source, through the
+// current transformations to filePath as an export job
+func (ui *ImageRestorationUI) saveSnapshot(source, filePath string, options SaveOptions) {
+	original := ui.pipeline.GetOriginalImage()
+	if original.Empty() {
+		original.Close()
+		os.Remove(filePath)
+		fyne.Do(func() {
+			dialog.ShowError(fmt.Errorf("no image loaded"), ui.window)
+		})
+		return
+	}
+	ui.debugGUI.LogSaveOperation(filepath.Base(filePath), strings.ToLower(filepath.Ext(filePath)), true)
+
+	document := imageDocument(source, original, ui.pipeline.Metadata())
+	ui.runExport(document, filePath, ExportOptions{Save: options}, func() {
+		original.Close()
+	})
+}
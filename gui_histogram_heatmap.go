@@ -0,0 +1,157 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// histogramHeatmap shows a joint 2D histogram on a log scale, gray level g
+// to the right and guided mean f upwards, with a crosshair at the
+// thresholds (s, t). Tapping or dragging moves the crosshair; onChanged
+// receives the thresholds when the pointer is released.
+type histogramHeatmap struct {
+	widget.BaseWidget
+	onChanged func(s, t int)
+
+	image         *canvas.Image
+	vertical      *canvas.Line
+	horizontal    *canvas.Line
+	s, t          int
+	showCrosshair bool
+	dragging      bool
+}
+
+func newHistogramHeatmap(onChanged func(s, t int)) *histogramHeatmap {
+	h := &histogramHeatmap{onChanged: onChanged}
+	h.image = canvas.NewImageFromImage(image.NewNRGBA(image.Rect(0, 0, 256, 256)))
+	h.image.FillMode = canvas.ImageFillStretch
+	h.image.ScaleMode = canvas.ImageScalePixels
+	h.vertical = canvas.NewLine(theme.Color(theme.ColorNamePrimary))
+	h.horizontal = canvas.NewLine(theme.Color(theme.ColorNamePrimary))
+	h.vertical.StrokeWidth = 1
+	h.horizontal.StrokeWidth = 1
+	h.ExtendBaseWidget(h)
+	return h
+}
+
+// SetHistogram redraws the heatmap; nil clears it
+func (h *histogramHeatmap) SetHistogram(hist *histogram2D) {
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	if hist != nil {
+		peak := uint32(0)
+		for _, count := range hist {
+			if count > peak {
+				peak = count
+			}
+		}
+		scale := 1.0
+		if peak > 0 {
+			scale = 1 / math.Log1p(float64(peak))
+		}
+		for g := 0; g < 256; g++ {
+			for f := 0; f < 256; f++ {
+				img.SetNRGBA(g, 255-f, heatColor(math.Log1p(float64(hist[g<<8|f]))*scale))
+			}
+		}
+	}
+	h.image.Image = img
+	h.image.Refresh()
+}
+
+// SetThresholds moves the crosshair unless the user is dragging it
+func (h *histogramHeatmap) SetThresholds(s, t int, visible bool) {
+	if h.dragging {
+		return
+	}
+	h.s, h.t, h.showCrosshair = s, t, visible
+	h.Refresh()
+}
+
+func (h *histogramHeatmap) Tapped(event *fyne.PointEvent) {
+	h.moveTo(event.Position)
+	if h.onChanged != nil {
+		h.onChanged(h.s, h.t)
+	}
+}
+
+func (h *histogramHeatmap) Dragged(event *fyne.DragEvent) {
+	h.dragging = true
+	h.moveTo(event.Position)
+}
+
+func (h *histogramHeatmap) DragEnd() {
+	h.dragging = false
+	if h.onChanged != nil {
+		h.onChanged(h.s, h.t)
+	}
+}
+
+func (h *histogramHeatmap) moveTo(position fyne.Position) {
+	size := h.Size()
+	if size.Width <= 0 || size.Height <= 0 {
+		return
+	}
+	h.s = clampThreshold(int(position.X / size.Width * 256))
+	h.t = clampThreshold(255 - int(position.Y/size.Height*256))
+	h.showCrosshair = true
+	h.Refresh()
+}
+
+func (h *histogramHeatmap) CreateRenderer() fyne.WidgetRenderer {
+	return &histogramHeatmapRenderer{heatmap: h}
+}
+
+type histogramHeatmapRenderer struct {
+	heatmap *histogramHeatmap
+}
+
+func (r *histogramHeatmapRenderer) Layout(size fyne.Size) {
+	h := r.heatmap
+	h.image.Resize(size)
+	h.image.Move(fyne.NewPos(0, 0))
+
+	x := (float32(h.s) + 0.5) / 256 * size.Width
+	y := (255 - float32(h.t) + 0.5) / 256 * size.Height
+	h.vertical.Position1 = fyne.NewPos(x, 0)
+	h.vertical.Position2 = fyne.NewPos(x, size.Height)
+	h.horizontal.Position1 = fyne.NewPos(0, y)
+	h.horizontal.Position2 = fyne.NewPos(size.Width, y)
+}
+
+func (r *histogramHeatmapRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(256, 256)
+}
+
+func (r *histogramHeatmapRenderer) Refresh() {
+	h := r.heatmap
+	h.vertical.Hidden = !h.showCrosshair
+	h.horizontal.Hidden = !h.showCrosshair
+	r.Layout(h.Size())
+	canvas.Refresh(h)
+}
+
+func (r *histogramHeatmapRenderer) Objects() []fyne.CanvasObject {
+	h := r.heatmap
+	return []fyne.CanvasObject{h.image, h.vertical, h.horizontal}
+}
+
+func (r *histogramHeatmapRenderer) Destroy() {}
+
+// heatColor maps 0..1 through black, red and yellow to white
+func heatColor(value float64) color.NRGBA {
+	value = math.Max(0, math.Min(1, value))
+	channel := func(start float64) uint8 {
+		return uint8(math.Round(255 * math.Max(0, math.Min(1, (value-start)*3))))
+	}
+	return color.NRGBA{R: channel(0), G: channel(1.0 / 3), B: channel(2.0 / 3), A: 255}
+}
+
+func clampThreshold(value int) int {
+	return max(0, min(value, 255))
+}
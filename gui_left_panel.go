@@ -10,13 +10,22 @@ import (
 )
 
 func (ui *ImageRestorationUI) createLeftPanel() fyne.CanvasObject {
-	transformations := []string{"2D Otsu", "Lanczos4 Scaling"}
+	ui.availableTransformations = transformationRegistry.List()
 
 	ui.availableTransformationsList = widget.NewList(
-		func() int { return len(transformations) },
-		func() fyne.CanvasObject { return widget.NewLabel("Transformation") },
+		func() int { return len(ui.availableTransformations) },
+		func() fyne.CanvasObject {
+			name := widget.NewLabel("Transformation")
+			name.TextStyle = fyne.TextStyle{Bold: true}
+			category := widget.NewLabel("Category")
+			category.Importance = widget.LowImportance
+			return container.NewVBox(name, category)
+		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			obj.(*widget.Label).SetText(transformations[id])
+			info := ui.availableTransformations[id]
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(info.DisplayName)
+			row.Objects[1].(*widget.Label).SetText(info.Category)
 		},
 	)
 	ui.availableTransformationsList.OnSelected = ui.onTransformationSelected
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/widget"
 )
 
@@ -19,6 +21,10 @@ type ImageRestorationUI struct {
 	previewScroll                *container.Scroll
 	transformationsList          *widget.List
 	availableTransformationsList *widget.List
+	availableTransformations     []TransformationInfo
+	selectedTransformation       int
+	undoButton                   *widget.Button
+	redoButton                   *widget.Button
 	parametersContainer          *fyne.Container
 	imageInfoLabel               *widget.RichText
 	psnrProgress                 *widget.ProgressBar
@@ -28,19 +34,47 @@ type ImageRestorationUI struct {
 	debugGUI                     *DebugGUI
 	debugRender                  *DebugRender
 
+	document       *Document // open file; nil until one is opened
+	pageIndex      int
+	pageNavigator  *fyne.Container
+	pageLabel      *widget.Label
+	pagePrevButton *widget.Button
+	pageNextButton *widget.Button
+
+	// Last choices of the PDF export, offered again
+	pdfExportOptions ExportOptions
+	ocrCommand       string
+
+	progressBox    *fyne.Container
+	progressLabel  *widget.Label
+	progressBar    *widget.ProgressBar
+	progressCancel *widget.Button
+
 	updateMutex       sync.Mutex
-	lastUpdateTime    time.Time
-	processingUpdate  bool
+	previewCancel     context.CancelFunc
 	parameterDebounce time.Duration
+
+	jobMutex  sync.Mutex
+	jobID     int
+	jobCancel context.CancelFunc
+
+	// Saves and exports, see gui_export.go
+	exportBox       *fyne.Container
+	exportLabel     *widget.Label
+	exportBar       *widget.ProgressBar
+	exportCancel    *widget.Button
+	exportMutex     sync.Mutex
+	exportJobCancel context.CancelFunc
 }
 
 func NewImageRestorationUI(window fyne.Window, config *DebugConfig) *ImageRestorationUI {
 	return &ImageRestorationUI{
-		window:            window,
-		pipeline:          NewImagePipeline(config),
-		debugGUI:          NewDebugGUI(config),
-		debugRender:       NewDebugRender(config),
-		parameterDebounce: 200 * time.Millisecond,
+		window:                 window,
+		pipeline:               NewImagePipeline(config),
+		debugGUI:               NewDebugGUI(config),
+		debugRender:            NewDebugRender(config),
+		parameterDebounce:      200 * time.Millisecond,
+		selectedTransformation: -1,
 	}
 }
 
@@ -50,6 +84,13 @@ func (ui *ImageRestorationUI) BuildUI() fyne.CanvasObject {
 	centerPanel := ui.createCenterPanel()
 	rightPanel := ui.createRightPanel()
 
+	ui.window.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: fyne.KeyModifierShortcutDefault}, func(fyne.Shortcut) {
+		ui.undo()
+	})
+	ui.window.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierShift}, func(fyne.Shortcut) {
+		ui.redo()
+	})
+
 	return container.NewBorder(
 		toolbar,
 		nil,
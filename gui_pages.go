@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createPageNavigator builds the page controls of multi-page documents,
+// hidden while a single image is open
+func (ui *ImageRestorationUI) createPageNavigator() fyne.CanvasObject {
+	ui.pagePrevButton = widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() {
+		ui.showPage(ui.pageIndex - 1)
+	})
+	ui.pageNextButton = widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() {
+		ui.showPage(ui.pageIndex + 1)
+	})
+	ui.pageLabel = widget.NewLabel("")
+	exportBtn := widget.NewButtonWithIcon("EXPORT PAGES", theme.DownloadIcon(), ui.exportPages)
+
+	ui.pageNavigator = container.NewHBox(widget.NewSeparator(), ui.pagePrevButton, ui.pageLabel, ui.pageNextButton, exportBtn)
+	ui.pageNavigator.Hide()
+	return ui.pageNavigator
+}
+
+// updatePageNavigator shows the current page of the open document. It must
+// run on the UI thread.
+func (ui *ImageRestorationUI) updatePageNavigator() {
+	if ui.document == nil || ui.document.PageCount() <= 1 {
+		ui.pageNavigator.Hide()
+		return
+	}
+
+	ui.pageLabel.SetText(fmt.Sprintf("Page %d / %d", ui.pageIndex+1, ui.document.PageCount()))
+	if ui.pageIndex > 0 {
+		ui.pagePrevButton.Enable()
+	} else {
+		ui.pagePrevButton.Disable()
+	}
+	if ui.pageIndex < ui.document.PageCount()-1 {
+		ui.pageNextButton.Enable()
+	} else {
+		ui.pageNextButton.Disable()
+	}
+	ui.pageNavigator.Show()
+}
+
+func (ui *ImageRestorationUI) showPage(index int) {
+	ui.debugGUI.LogButtonClick(fmt.Sprintf("Page %d", index+1))
+
+	document := ui.document
+	if document == nil || index < 0 || index >= document.PageCount() {
+		return
+	}
+	ui.pagePrevButton.Disable()
+	ui.pageNextButton.Disable()
+	go ui.loadPage(document, index)
+}
+
+// loadPage makes page index of document the original image. The
+// transformation stack is kept so a tuned setup is reused on the next page.
+func (ui *ImageRestorationUI) loadPage(document *Document, index int) {
+	mat, metadata, err := document.Page(index)
+	defer mat.Close()
+	if err == nil {
+		size := mat.Size()
+		ui.debugGUI.LogImageInfo(size[1], size[0], mat.Channels())
+		ui.debugGUI.Log(fmt.Sprintf("Image metadata: %s", metadata))
+		err = ui.pipeline.SetOriginalImage(mat, metadata)
+	}
+	if err != nil {
+		ui.debugGUI.LogError(err)
+		fyne.Do(func() {
+			ui.updatePageNavigator()
+			dialog.ShowError(err, ui.window)
+		})
+		return
+	}
+
+	fyne.Do(func() {
+		ui.document = document
+		ui.pageIndex = index
+		ui.updatePageNavigator()
+		ui.updateUI()
+		ui.updateWindowTitle(filepath.Base(document.Path))
+
+		ui.parametersContainer.Objects[0] = widget.NewLabel("Select a Transformation")
+		ui.parametersContainer.Refresh()
+		ui.transformationsList.UnselectAll()
+	})
+
+	ui.debugGUI.Log(fmt.Sprintf("Page %d of %d loaded successfully", index+1, document.PageCount()))
+}
+
+// exportPages runs every page of the open document through the current
+// transformations. A TIFF or PDF name gets one multi-page file, other
+// formats a file per page numbered after the chosen name.
+func (ui *ImageRestorationUI) exportPages() {
+	ui.debugGUI.LogButtonClick("EXPORT PAGES")
+	ui.chooseExportPath(".tif", func(filePath string) {
+		ext := strings.ToLower(filepath.Ext(filePath))
+		if !isSaveFormat(ext) && ext != ".pdf" {
+			os.Remove(filePath)
+			filePath = strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".tif"
+		}
+
+		// The open page stands for the document when checking the format
+		preview := ui.pipeline.GetPreviewImage()
+		warning := bilevelWarning(strings.ToLower(filepath.Ext(filePath)), preview)
+		preview.Close()
+
+		document, options := ui.document, ui.pdfExportOptions
+		options.Save = savedSaveOptions()
+		go ui.confirmBilevelFormat(filePath, warning, func(filePath string) {
+			ui.runExport(document, filePath, options, nil)
+		})
+	})
+}
+
+// exportPDF asks for the layers of a PDF and exports the open document,
+// a single image included, to it
+func (ui *ImageRestorationUI) exportPDF() {
+	ui.debugGUI.LogButtonClick("EXPORT PDF")
+	if ui.document == nil || !ui.pipeline.HasImage() {
+		dialog.ShowInformation("No Image", "Please load an image first", ui.window)
+		return
+	}
+
+	originalCheck := widget.NewCheck("Include the original pages as a hidden layer", nil)
+	originalCheck.SetChecked(ui.pdfExportOptions.OriginalLayer)
+	ocrEntry := widget.NewEntry()
+	ocrEntry.SetPlaceHolder("program and arguments")
+	ocrEntry.SetText(ui.ocrCommand)
+	form := widget.NewForm(
+		widget.NewFormItem("", originalCheck),
+		widget.NewFormItem("OCR command", ocrEntry),
+	)
+
+	optionsDialog := dialog.NewCustomConfirm("Export PDF", "Choose File", "Cancel", container.NewVBox(
+		form,
+		widget.NewLabel("The OCR command gets each page as a PNG file appended and must print\nTesseract TSV; its words become the invisible text layer. Leave it empty\nfor a PDF without text."),
+	), func(ok bool) {
+		if !ok {
+			return
+		}
+		options := ExportOptions{OriginalLayer: originalCheck.Checked}
+		ui.ocrCommand = strings.TrimSpace(ocrEntry.Text)
+		if ui.ocrCommand != "" {
+			recognizer, err := newCommandRecognizer(ui.ocrCommand)
+			if err != nil {
+				dialog.ShowError(err, ui.window)
+				return
+			}
+			options.Recognizer = recognizer
+		}
+		ui.pdfExportOptions = options
+		ui.debugGUI.Log(fmt.Sprintf("PDF options: original layer %t, OCR %q", options.OriginalLayer, ui.ocrCommand))
+
+		ui.chooseExportPath(".pdf", func(filePath string) {
+			if !isPDFPath(filePath) {
+				os.Remove(filePath)
+				filePath = strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".pdf"
+			}
+			options.Save = savedSaveOptions()
+			go ui.runExport(ui.document, filePath, options, nil)
+		})
+	}, ui.window)
+	optionsDialog.Show()
+}
+
+// chooseExportPath asks where to export the open document, suggesting its
+// name with ext. choose gets the path the save dialog created.
+func (ui *ImageRestorationUI) chooseExportPath(ext string, choose func(filePath string)) {
+	document := ui.document
+	if document == nil {
+		dialog.ShowInformation("No Document", "Please open a document first", ui.window)
+		return
+	}
+	if ui.exportRunning() {
+		return
+	}
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			ui.debugGUI.LogError(err)
+			return
+		}
+		writer.Close()
+
+		filePath := writer.URI().Path()
+		ui.debugGUI.LogFileOperation("export pages", filepath.Base(filePath))
+		choose(filePath)
+	}, ui.window)
+	saveDialog.SetFileName(strings.TrimSuffix(filepath.Base(document.Path), filepath.Ext(document.Path)) + ext)
+	saveDialog.Show()
+}
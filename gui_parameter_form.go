@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// parameterForm is a parameter panel generated from a transformation's
+// ParameterSpecs. Numbers use entries applied on Enter, booleans checkboxes
+// and enums drop-downs.
+type parameterForm struct {
+	transformation Transformation
+	debugImage     *DebugImage
+	onChanged      func(name string)
+
+	// loaders copy the current parameter values into the widgets
+	loaders []func(params map[string]interface{})
+	loading bool
+
+	content *fyne.Container
+}
+
+// newParameterForm builds the form; onChanged is called with the parameter
+// name after a valid edit has been applied to transformation.
+func newParameterForm(transformation Transformation, debugImage *DebugImage, onChanged func(name string)) *parameterForm {
+	f := &parameterForm{
+		transformation: transformation,
+		debugImage:     debugImage,
+		onChanged:      onChanged,
+		content:        container.NewVBox(),
+	}
+
+	for _, spec := range transformation.ParameterSpecs() {
+		f.content.Add(f.createField(spec))
+		if spec.Help != "" {
+			help := widget.NewLabel(spec.Help)
+			help.Wrapping = fyne.TextWrapWord
+			help.Importance = widget.LowImportance
+			f.content.Add(help)
+		}
+	}
+
+	f.Refresh()
+	return f
+}
+
+// Refresh reloads every widget from the transformation's current parameters
+func (f *parameterForm) Refresh() {
+	params := f.transformation.GetParameters()
+	f.loading = true
+	for _, load := range f.loaders {
+		load(params)
+	}
+	f.loading = false
+}
+
+func (f *parameterForm) createField(spec ParameterSpec) fyne.CanvasObject {
+	switch spec.Type {
+	case ParameterBool:
+		check := widget.NewCheck(spec.Label, func(checked bool) {
+			f.set(spec, checked)
+		})
+		f.loaders = append(f.loaders, func(params map[string]interface{}) {
+			if value, ok := params[spec.Name].(bool); ok {
+				check.SetChecked(value)
+			}
+		})
+		return check
+
+	case ParameterEnum:
+		selector := widget.NewSelect(spec.Values, func(value string) {
+			f.set(spec, value)
+		})
+		f.loaders = append(f.loaders, func(params map[string]interface{}) {
+			if value, ok := params[spec.Name].(string); ok {
+				selector.SetSelected(value)
+			}
+		})
+		return container.NewVBox(widget.NewLabel(spec.Label+":"), selector)
+
+	default:
+		entry := widget.NewEntry()
+		entry.Validator = func(text string) error {
+			_, err := spec.Parse(text)
+			return err
+		}
+
+		// Why an entry is rejected shows below it until the text is fixed
+		problem := widget.NewLabel("")
+		problem.Wrapping = fyne.TextWrapWord
+		problem.Importance = widget.DangerImportance
+		problem.Hide()
+		entry.SetOnValidationChanged(func(err error) {
+			if err == nil {
+				problem.Hide()
+				return
+			}
+			problem.SetText(err.Error())
+			problem.Show()
+		})
+
+		entry.OnSubmitted = func(text string) {
+			value, err := spec.Parse(text)
+			if err != nil {
+				entry.SetValidationError(err)
+				f.debugImage.LogAlgorithmStep(f.transformation.Name()+" Parameters", err.Error())
+				return
+			}
+			f.set(spec, value)
+		}
+		f.loaders = append(f.loaders, func(params map[string]interface{}) {
+			entry.SetText(spec.Format(params[spec.Name]))
+		})
+		return container.NewVBox(widget.NewLabel(fmt.Sprintf("%s (%s):", spec.Label, spec.Range())), entry, problem)
+	}
+}
+
+func (f *parameterForm) set(spec ParameterSpec, value interface{}) {
+	if f.loading {
+		return
+	}
+
+	oldValue := f.transformation.GetParameters()[spec.Name]
+	f.transformation.SetParameters(map[string]interface{}{spec.Name: value})
+
+	f.debugImage.LogAlgorithmStep(f.transformation.Name()+" Parameters",
+		fmt.Sprintf("%s changed: %v -> %v", spec.Label, oldValue, value))
+	if f.onChanged != nil {
+		f.onChanged(spec.Name)
+	}
+}
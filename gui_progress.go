@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+func (ui *ImageRestorationUI) createProgressIndicator() fyne.CanvasObject {
+	ui.progressLabel = widget.NewLabel("")
+	ui.progressBar = widget.NewProgressBar()
+	ui.progressCancel = widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), ui.cancelJob)
+
+	ui.progressBox = container.NewHBox(
+		ui.progressLabel,
+		container.NewGridWrap(fyne.NewSize(200, ui.progressBar.MinSize().Height), ui.progressBar),
+		ui.progressCancel,
+	)
+	ui.progressBox.Hide()
+	return ui.progressBox
+}
+
+// startJob shows the progress bar for a cancellable processing job. The
+// returned context is cancelled by the Cancel button and reports progress to
+// the bar; finish must be called when the job ends. Only the most recent job
+// is shown.
+func (ui *ImageRestorationUI) startJob(ctx context.Context, name string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	ui.jobMutex.Lock()
+	ui.jobID++
+	id := ui.jobID
+	ui.jobCancel = cancel
+	ui.jobMutex.Unlock()
+
+	ui.debugGUI.LogUIEvent("Job started: " + name)
+
+	// Reports arrive for every region and histogram block, so only
+	// percent-sized steps are forwarded to the UI thread
+	var reportMutex sync.Mutex
+	lastReported := 0.0
+	ctx = WithProgress(ctx, func(fraction float64) {
+		reportMutex.Lock()
+		if fraction-lastReported < 0.01 && fraction < 1 {
+			reportMutex.Unlock()
+			return
+		}
+		lastReported = fraction
+		reportMutex.Unlock()
+
+		fyne.Do(func() {
+			if ui.isCurrentJob(id) {
+				ui.progressBar.SetValue(fraction)
+			}
+		})
+	})
+
+	fyne.Do(func() {
+		ui.progressLabel.SetText(name)
+		ui.progressBar.SetValue(0)
+		ui.progressCancel.Enable()
+		ui.progressBox.Show()
+	})
+
+	finish := func() {
+		cancel()
+
+		ui.jobMutex.Lock()
+		current := ui.jobID == id
+		if current {
+			ui.jobCancel = nil
+		}
+		ui.jobMutex.Unlock()
+
+		if current {
+			fyne.Do(func() {
+				if ui.isCurrentJob(id) {
+					ui.progressBox.Hide()
+				}
+			})
+		}
+	}
+	return ctx, finish
+}
+
+func (ui *ImageRestorationUI) isCurrentJob(id int) bool {
+	ui.jobMutex.Lock()
+	defer ui.jobMutex.Unlock()
+	return ui.jobID == id
+}
+
+func (ui *ImageRestorationUI) cancelJob() {
+	ui.debugGUI.LogButtonClick("Cancel")
+
+	ui.jobMutex.Lock()
+	defer ui.jobMutex.Unlock()
+
+	if ui.jobCancel != nil {
+		ui.jobCancel()
+		ui.progressLabel.SetText("Cancelling...")
+		ui.progressCancel.Disable()
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Preference keys of the last save
+const (
+	preferenceSaveOptions = "saveOptions"
+	preferenceSaveFormat  = "saveFormat"
+)
+
+// savedSaveOptions are the settings of the last save, or the defaults
+func savedSaveOptions() SaveOptions {
+	options := DefaultSaveOptions()
+	data := fyne.CurrentApp().Preferences().String(preferenceSaveOptions)
+	if data == "" {
+		return options
+	}
+	if err := json.Unmarshal([]byte(data), &options); err != nil || options.Validate() != nil {
+		return DefaultSaveOptions()
+	}
+	return options
+}
+
+// savedSaveFormat is the extension of the last save, PNG at first
+func savedSaveFormat() string {
+	ext := fyne.CurrentApp().Preferences().StringWithFallback(preferenceSaveFormat, ".png")
+	if !isSaveFormat(ext) {
+		return ".png"
+	}
+	return ext
+}
+
+func rememberSaveOptions(ext string, options SaveOptions) {
+	preferences := fyne.CurrentApp().Preferences()
+	if data, err := json.Marshal(options); err == nil {
+		preferences.SetString(preferenceSaveOptions, string(data))
+	}
+	preferences.SetString(preferenceSaveFormat, ext)
+}
+
+// showSaveOptions asks for the format and encoder settings of a save,
+// starting from ext and the settings of the last save. done gets the chosen
+// extension and settings, which are remembered for the next save.
+func (ui *ImageRestorationUI) showSaveOptions(ext string, done func(ext string, options SaveOptions)) {
+	options := savedSaveOptions()
+
+	// One group of settings per format, shown while the format is selected
+	groups := map[string]*fyne.Container{
+		".jpg": container.NewVBox(
+			intSlider("Quality", 0, 100, &options.JPEGQuality),
+			labeled("Chroma subsampling", stringSelect([]string{"4:2:0", "4:2:2", "4:4:4"}, &options.JPEGSubsampling)),
+			boolCheck("Progressive", &options.JPEGProgressive),
+		),
+		".png": container.NewVBox(
+			intSlider("Compression level", 0, 9, &options.PNGCompression),
+		),
+		".tif": container.NewVBox(
+			labeled("Compression", stringSelect([]string{"lzw", "deflate", "none"}, &options.TIFFCompression)),
+			boolCheck("CCITT Group 4 for black-and-white images", &options.TIFFGroup4),
+		),
+		".webp": container.NewVBox(
+			intSlider("Quality", 1, 100, &options.WebPQuality),
+		),
+	}
+
+	names := make([]string, len(saveFormats))
+	settings := container.NewVBox()
+	for i, format := range saveFormats {
+		names[i] = format.name
+		settings.Add(groups[format.ext])
+	}
+	formatSelect := widget.NewSelect(names, func(name string) {
+		for _, format := range saveFormats {
+			if format.name == name {
+				// .jpeg and .tiff names are kept
+				if saveFormatName(ext) != name {
+					ext = format.ext
+				}
+				groups[format.ext].Show()
+			} else {
+				groups[format.ext].Hide()
+			}
+		}
+	})
+	formatSelect.SetSelected(saveFormatName(ext))
+
+	content := container.NewVBox(labeled("Format", formatSelect), widget.NewSeparator(), settings)
+	optionsDialog := dialog.NewCustomConfirm("Save Options", "Save", "Cancel", content, func(save bool) {
+		if !save {
+			ui.debugGUI.Log("Save cancelled in options")
+			return
+		}
+		if err := options.Validate(); err != nil {
+			dialog.ShowError(err, ui.window)
+			return
+		}
+		rememberSaveOptions(ext, options)
+		ui.debugGUI.Log("Save options: " + options.Summary(ext))
+		done(ext, options)
+	}, ui.window)
+	optionsDialog.Resize(fyne.NewSize(400, 0))
+	optionsDialog.Show()
+}
+
+// intSlider edits *value in low..high, showing the value beside the label
+func intSlider(label string, low, high int, value *int) fyne.CanvasObject {
+	title := widget.NewLabel("")
+	show := func() {
+		title.SetText(fmt.Sprintf("%s: %d", label, *value))
+	}
+	slider := widget.NewSlider(float64(low), float64(high))
+	slider.Step = 1
+	slider.SetValue(float64(*value))
+	slider.OnChanged = func(v float64) {
+		*value = int(v)
+		show()
+	}
+	show()
+	return container.NewVBox(title, slider)
+}
+
+// stringSelect edits *value, showing the values upper case
+func stringSelect(values []string, value *string) *widget.Select {
+	shown := make([]string, len(values))
+	for i, v := range values {
+		shown[i] = strings.ToUpper(v)
+	}
+	selector := widget.NewSelect(shown, func(selected string) {
+		*value = strings.ToLower(selected)
+	})
+	selector.SetSelected(strings.ToUpper(*value))
+	return selector
+}
+
+func boolCheck(label string, value *bool) *widget.Check {
+	check := widget.NewCheck(label, func(checked bool) {
+		*value = checked
+	})
+	check.SetChecked(*value)
+	return check
+}
+
+func labeled(label string, object fyne.CanvasObject) fyne.CanvasObject {
+	return container.NewVBox(widget.NewLabel(label+":"), object)
+}
@@ -17,12 +17,22 @@ func (ui *ImageRestorationUI) createToolbar() fyne.CanvasObject {
 	resetBtn := widget.NewButtonWithIcon("Reset", theme.ViewRefreshIcon(), ui.resetTransformations)
 	resetBtn.Importance = widget.HighImportance
 
-	leftSection := container.NewHBox(openBtn, saveBtn, resetBtn)
+	ui.undoButton = widget.NewButtonWithIcon("Undo", theme.ContentUndoIcon(), ui.undo)
+	ui.redoButton = widget.NewButtonWithIcon("Redo", theme.ContentRedoIcon(), ui.redo)
+	ui.undoButton.Disable()
+	ui.redoButton.Disable()
+
+	exportPDFBtn := widget.NewButtonWithIcon("EXPORT PDF", theme.DocumentIcon(), ui.exportPDF)
+
+	loadRecipeBtn := widget.NewButtonWithIcon("LOAD RECIPE", theme.FileIcon(), ui.loadRecipe)
+	saveRecipeBtn := widget.NewButtonWithIcon("SAVE RECIPE", theme.DocumentSaveIcon(), ui.saveRecipe)
+
+	leftSection := container.NewHBox(openBtn, saveBtn, exportPDFBtn, resetBtn, widget.NewSeparator(), ui.undoButton, ui.redoButton, widget.NewSeparator(), loadRecipeBtn, saveRecipeBtn, ui.createPageNavigator())
 
 	toolbar := container.NewBorder(
 		nil, nil,
 		leftSection,
-		nil,
+		container.NewHBox(ui.createExportIndicator(), ui.createProgressIndicator()),
 		nil,
 	)
 
@@ -1,23 +1,20 @@
 package main
 
 import (
+	"fmt"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 )
 
 func (ui *ImageRestorationUI) onTransformationSelected(id widget.ListItemID) {
-	var transformationName string
-	switch id {
-	case 0:
-		transformationName = "2D Otsu"
-	case 1:
-		transformationName = "Lanczos4 Scaling"
-	default:
+	if id < 0 || id >= len(ui.availableTransformations) {
 		return
 	}
+	info := ui.availableTransformations[id]
 
-	ui.debugGUI.LogListSelection("available transformations", int(id), transformationName)
+	ui.debugGUI.LogListSelection("available transformations", int(id), info.DisplayName)
 
 	if !ui.pipeline.HasImage() {
 		ui.debugGUI.Log("Cannot apply transformation: no image loaded")
@@ -27,15 +24,7 @@ func (ui *ImageRestorationUI) onTransformationSelected(id widget.ListItemID) {
 	}
 
 	go func() {
-		var transformation Transformation
-		switch id {
-		case 0:
-			transformation = NewTwoDOtsu(&debugConfig)
-		case 1:
-			transformation = NewLanczos4Transform(&debugConfig)
-		default:
-			return
-		}
+		transformation := info.Factory(&debugConfig)
 
 		err := ui.pipeline.AddTransformation(transformation)
 		if err != nil {
@@ -59,6 +48,7 @@ func (ui *ImageRestorationUI) onTransformationSelected(id widget.ListItemID) {
 
 func (ui *ImageRestorationUI) onAppliedTransformationSelected(id widget.ListItemID) {
 	if id < len(ui.pipeline.transformations) {
+		ui.selectedTransformation = id
 		transformation := ui.pipeline.transformations[id]
 		ui.showTransformationParameters(transformation)
 	}
@@ -69,6 +59,10 @@ func (ui *ImageRestorationUI) removeTransformation(id int) {
 		err := ui.pipeline.RemoveTransformation(id)
 		if err != nil {
 			ui.debugGUI.LogError(err)
+			fyne.Do(func() {
+				dialog.ShowError(err, ui.window)
+				ui.updateUI()
+			})
 			return
 		}
 
@@ -81,6 +75,59 @@ func (ui *ImageRestorationUI) removeTransformation(id int) {
 	}()
 }
 
+func (ui *ImageRestorationUI) moveTransformation(from, to int) {
+	last := len(ui.pipeline.transformations) - 1
+	if to < 0 {
+		to = 0
+	}
+	if to > last {
+		to = last
+	}
+	if from == to {
+		return
+	}
+
+	ui.debugGUI.LogUIEvent(fmt.Sprintf("Moving transformation %d to %d", from, to))
+	wasSelected := ui.selectedTransformation == from
+
+	go func() {
+		if err := ui.pipeline.MoveTransformation(from, to); err != nil {
+			ui.debugGUI.LogError(err)
+			fyne.Do(func() {
+				dialog.ShowError(err, ui.window)
+				ui.updateUI()
+			})
+			return
+		}
+
+		fyne.Do(func() {
+			ui.updateUI()
+			if wasSelected {
+				ui.transformationsList.Select(to)
+			} else {
+				ui.transformationsList.UnselectAll()
+			}
+		})
+	}()
+}
+
+func (ui *ImageRestorationUI) setTransformationBypassed(id int, bypassed bool) {
+	ui.debugGUI.LogUIEvent(fmt.Sprintf("Setting transformation %d bypassed: %t", id, bypassed))
+
+	go func() {
+		if err := ui.pipeline.SetTransformationBypassed(id, bypassed); err != nil {
+			ui.debugGUI.LogError(err)
+			fyne.Do(func() {
+				dialog.ShowError(err, ui.window)
+			})
+		}
+
+		fyne.Do(func() {
+			ui.updateUI()
+		})
+	}()
+}
+
 func (ui *ImageRestorationUI) showTransformationParameters(transformation Transformation) {
 	parametersWidget := transformation.GetParametersWidget(ui.onParameterChanged)
 	fyne.Do(func() {
@@ -1,49 +1,40 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"time"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
 	"gocv.io/x/gocv"
 )
 
+// onParameterChanged regenerates the preview after a short debounce. A newer
+// change cancels the preview job still running for an older one.
 func (ui *ImageRestorationUI) onParameterChanged() {
-	ui.debugGUI.LogUIEvent("onParameterChanged called - triggering immediate preview update")
+	ui.debugGUI.LogUIEvent("onParameterChanged called - scheduling preview update")
 
 	ui.updateMutex.Lock()
-	if ui.processingUpdate {
-		ui.updateMutex.Unlock()
-		ui.debugGUI.LogUIEvent("onParameterChanged: update already in progress, skipping")
-		return
+	if ui.previewCancel != nil {
+		ui.debugGUI.LogUIEvent("onParameterChanged: cancelling superseded preview")
+		ui.previewCancel()
 	}
-	ui.processingUpdate = true
+	ctx, cancel := context.WithCancel(context.Background())
+	ui.previewCancel = cancel
 	ui.updateMutex.Unlock()
 
-	now := time.Now()
-	if now.Sub(ui.lastUpdateTime) < ui.parameterDebounce {
-		ui.updateMutex.Lock()
-		ui.processingUpdate = false
-		ui.updateMutex.Unlock()
-		ui.debugGUI.LogUIEvent("onParameterChanged: rate limited, scheduling delayed update")
-
-		go func() {
-			time.Sleep(ui.parameterDebounce - now.Sub(ui.lastUpdateTime))
-			ui.onParameterChanged()
-		}()
-		return
-	}
-
-	ui.lastUpdateTime = now
-
 	go func() {
-		defer func() {
-			ui.updateMutex.Lock()
-			ui.processingUpdate = false
-			ui.updateMutex.Unlock()
-		}()
+		defer cancel()
+
+		select {
+		case <-time.After(ui.parameterDebounce):
+		case <-ctx.Done():
+			return
+		}
 
 		ui.debugGUI.LogUIEvent("onParameterChanged: starting preview regeneration")
 
@@ -52,9 +43,22 @@ func (ui *ImageRestorationUI) onParameterChanged() {
 			return
 		}
 
-		err := ui.pipeline.ForcePreviewRegeneration()
+		jobCtx, finish := ui.startJob(ctx, "Updating preview")
+		defer finish()
+
+		ui.pipeline.CommitParameterChanges()
+
+		err := ui.pipeline.ForcePreviewRegeneration(jobCtx)
+		if errors.Is(err, context.Canceled) {
+			ui.debugGUI.LogUIEvent("onParameterChanged: preview cancelled")
+			return
+		}
 		if err != nil {
-			ui.debugGUI.LogError(fmt.Errorf("failed to regenerate preview: %w", err))
+			err = fmt.Errorf("failed to regenerate preview: %w", err)
+			ui.debugGUI.LogError(err)
+			fyne.Do(func() {
+				dialog.ShowError(err, ui.window)
+			})
 			return
 		}
 
@@ -63,6 +67,7 @@ func (ui *ImageRestorationUI) onParameterChanged() {
 		fyne.Do(func() {
 			ui.updateImageDisplay()
 			ui.updateQualityMetrics()
+			ui.updateHistoryButtons()
 		})
 	}()
 }
@@ -72,6 +77,20 @@ func (ui *ImageRestorationUI) updateUI() {
 	ui.updateImageInfo()
 	ui.updateQualityMetrics()
 	ui.transformationsList.Refresh()
+	ui.updateHistoryButtons()
+}
+
+func (ui *ImageRestorationUI) updateHistoryButtons() {
+	if ui.pipeline.CanUndo() {
+		ui.undoButton.Enable()
+	} else {
+		ui.undoButton.Disable()
+	}
+	if ui.pipeline.CanRedo() {
+		ui.redoButton.Enable()
+	} else {
+		ui.redoButton.Disable()
+	}
 }
 
 func (ui *ImageRestorationUI) updateImageDisplay() {
@@ -80,7 +99,14 @@ func (ui *ImageRestorationUI) updateImageDisplay() {
 	if ui.pipeline.HasImage() && !ui.pipeline.originalImage.Empty() {
 		ui.debugGUI.LogUIEvent("updateImageDisplay: converting original image")
 
-		originalMat := ui.pipeline.originalImage.Clone()
+		// 16-bit and float images are tone-mapped for display only, the
+		// preview with the original's tone so both panes compare
+		tone := toneOf(ui.pipeline.originalImage)
+		originalMat, err := displayMat(ui.pipeline.originalImage, tone)
+		if err != nil {
+			ui.debugGUI.LogImageConversion("original", false, err.Error())
+			return
+		}
 		defer originalMat.Close()
 
 		originalImg, err := originalMat.ToImage()
@@ -91,14 +117,21 @@ func (ui *ImageRestorationUI) updateImageDisplay() {
 		ui.debugGUI.LogImageConversion("original", true, "")
 		ui.debugRender.LogImageProperties("original", originalImg)
 
-		previewMat := ui.pipeline.GetPreviewImage()
-		defer previewMat.Close()
+		previewSource := ui.pipeline.GetPreviewImage()
+		defer previewSource.Close()
 
-		if previewMat.Empty() {
+		if previewSource.Empty() {
 			ui.debugGUI.LogUIEvent("updateImageDisplay: preview image is empty")
 			return
 		}
 
+		previewMat, err := displayMat(previewSource, tone)
+		if err != nil {
+			ui.debugGUI.LogImageConversion("preview", false, err.Error())
+			return
+		}
+		defer previewMat.Close()
+
 		var previewImg image.Image
 		originalChannels := originalMat.Channels()
 		previewChannels := previewMat.Channels()
@@ -176,7 +209,12 @@ func (ui *ImageRestorationUI) updateImageInfo() {
 		size := ui.pipeline.originalImage.Size()
 		channels := ui.pipeline.originalImage.Channels()
 
-		info := fmt.Sprintf("Size: %dx%d\nChannels: %d", size[1], size[0], channels)
+		depth := depthName(matDepth(ui.pipeline.originalImage))
+
+		info := fmt.Sprintf("Size: %dx%d\nChannels: %d (%s)\nResolution: %s", size[1], size[0], channels, depth, ui.pipeline.Resolution())
+		if processed := ui.pipeline.ProcessedResolution(); processed != ui.pipeline.Resolution() {
+			info += fmt.Sprintf(" → %s", processed)
+		}
 		ui.imageInfoLabel.ParseMarkdown(info)
 	}
 }
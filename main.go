@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	_ "net/http/pprof" // Enable pprof profiling server
+	"os"
 	"runtime"
 	"time"
 
@@ -31,6 +32,11 @@ var debugConfig = DebugConfig{
 }
 
 func main() {
+	// Headless batch mode runs the pipeline without opening a window
+	if len(os.Args) > 1 && os.Args[1] == "batch" {
+		os.Exit(runBatch(os.Args[2:]))
+	}
+
 	// pprof server startup with error handling
 	go func() {
 		log.Println("Starting pprof server on :6060")
@@ -17,6 +17,70 @@ func (p *ImagePipeline) GetProcessedImage() gocv.Mat {
 	return p.processedImage.Clone()
 }
 
+// GetOriginalImage is a copy of the image as loaded, empty when none is
+func (p *ImagePipeline) GetOriginalImage() gocv.Mat {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if !p.HasImageUnsafe() {
+		return gocv.NewMat()
+	}
+	return p.originalImage.Clone()
+}
+
+// Metadata is the metadata of the original image
+func (p *ImagePipeline) Metadata() ImageMetadata {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.metadata
+}
+
+// Resolution is the resolution of the original image, zero when unknown
+func (p *ImagePipeline) Resolution() Resolution {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.metadata.Resolution
+}
+
+// ProcessedResolution is the resolution of GetProcessedImage, after the
+// transformations have scaled it
+func (p *ImagePipeline) ProcessedResolution() Resolution {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.processedImage.Empty() {
+		return p.metadata.Resolution
+	}
+	return p.processedRes
+}
+
+// ProcessedMetadata is the metadata to save with GetProcessedImage: that of
+// the original file at the processed resolution, with the recipe that
+// produced it recorded in XMP
+func (p *ImagePipeline) ProcessedMetadata() (ImageMetadata, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	metadata := p.metadata
+	if !p.processedImage.Empty() {
+		metadata.Resolution = p.processedRes
+	}
+	if len(p.transformations) == 0 {
+		return metadata, nil
+	}
+
+	recipe, err := p.recipeUnsafe()
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+	data, err := EncodeRecipe(recipe, true)
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+	metadata.XMP = withRecipe(metadata.XMP, string(data))
+	return metadata, nil
+}
+
 func (p *ImagePipeline) GetPreviewImage() gocv.Mat {
 	p.mutex.RLock()
 	defer p.mutex.RUnlock()
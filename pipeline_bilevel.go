@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+
+	"gocv.io/x/gocv"
+)
+
+// Bilevel images, which the binarizations produce, are saved with one bit
+// per pixel: PNG as 1-bit grayscale and TIFF with CCITT Group 4 compression.
+
+// isBilevel reports whether mat is one 8-bit channel of only 0 and 255
+func isBilevel(mat gocv.Mat) bool {
+	if mat.Empty() || mat.Channels() != 1 || matDepth(mat) != gocv.MatTypeCV8U {
+		return false
+	}
+	grays := gocv.NewMat()
+	defer grays.Close()
+	if err := gocv.InRangeWithScalar(mat, gocv.NewScalar(1, 0, 0, 0), gocv.NewScalar(254, 0, 0, 0), &grays); err != nil {
+		return false
+	}
+	return gocv.CountNonZero(grays) == 0
+}
+
+// isLossyFormat reports whether files of type ext are compressed lossily
+func isLossyFormat(ext string) bool {
+	return ext == ".jpg" || ext == ".jpeg" || ext == ".webp"
+}
+
+// bilevelWarning explains why mat should not be saved as ext, or is empty
+// when nothing speaks against it
+func bilevelWarning(ext string, mat gocv.Mat) string {
+	if !isLossyFormat(ext) || !isBilevel(mat) {
+		return ""
+	}
+	return saveFormatName(ext) + " blurs the edges of black-and-white images and stores 8 bits per pixel. " +
+		"PNG and TIFF keep them sharp with 1 bit per pixel."
+}
+
+// encodeBilevel encodes a bilevel mat with one bit per pixel. It returns
+// nil for formats without a 1-bit form and for TIFF when options ask for
+// another compression than Group 4.
+func encodeBilevel(ext string, mat gocv.Mat, resolution Resolution, options SaveOptions) []byte {
+	if !mat.IsContinuous() {
+		mat = mat.Clone()
+		defer mat.Close()
+	}
+	switch ext {
+	case ".png":
+		return encodeBilevelPNG(mat, options.PNGCompression)
+	case ".tif", ".tiff":
+		if !options.TIFFGroup4 {
+			return nil
+		}
+		return encodeG4TIFF(mat, resolution)
+	}
+	return nil
+}
+
+// encodeBilevelPNG writes a 1-bit grayscale PNG compressed at zlib level.
+// The resolution is left to setPNGMetadata.
+func encodeBilevelPNG(mat gocv.Mat, level int) []byte {
+	width, height := mat.Cols(), mat.Rows()
+	pixels := mat.ToBytes()
+	stride := (width + 7) / 8
+
+	// Every row starts with filter type 0; set bits are white
+	raw := make([]byte, height*(stride+1))
+	for y := 0; y < height; y++ {
+		row := raw[y*(stride+1)+1 : (y+1)*(stride+1)]
+		for x, value := range pixels[y*width : (y+1)*width] {
+			if value != 0 {
+				row[x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+
+	var compressed bytes.Buffer
+	writer, _ := zlib.NewWriterLevel(&compressed, level)
+	writer.Write(raw)
+	writer.Close()
+
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], uint32(width))
+	binary.BigEndian.PutUint32(header[4:], uint32(height))
+	header[8] = 1 // bit depth, colour type 0 (gray)
+
+	var png bytes.Buffer
+	png.Write(pngSignature)
+	writePNGChunk(&png, "IHDR", header)
+	writePNGChunk(&png, "IDAT", compressed.Bytes())
+	writePNGChunk(&png, "IEND", nil)
+	return png.Bytes()
+}
+
+// encodeG4TIFF writes a TIFF compressed with CCITT Group 4
+func encodeG4TIFF(mat gocv.Mat, resolution Resolution) []byte {
+	width, height := mat.Cols(), mat.Rows()
+	entries := []tiffEntry{shortEntry(tagCompression, compressionCCITTGroup4), longEntry(tagT6Options, 0)}
+	if resolution.Known() {
+		entries = append(entries, tiffIFD(nil).withResolution(resolution)...)
+	}
+	return faxTIFF(encodeG4(faxPixels(mat), width, height), width, height, entries...)
+}
+
+// faxPixels turns a continuous bilevel mat into the pixels encodeG4 takes
+func faxPixels(mat gocv.Mat) []byte {
+	pixels := mat.ToBytes()
+	for i, value := range pixels {
+		pixels[i] = 0
+		if value == 0 {
+			pixels[i] = 1 // black
+		}
+	}
+	return pixels
+}
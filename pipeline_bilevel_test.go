@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// bilevelMat is bitmap as an 8-bit gray image, 0 for black and 255 for white
+func bilevelMat(t *testing.T, bitmap faxBitmap) gocv.Mat {
+	t.Helper()
+	gray := make([]byte, len(bitmap.pixels))
+	for i, black := range bitmap.pixels {
+		if black == 0 {
+			gray[i] = 255
+		}
+	}
+	mat, err := gocv.NewMatFromBytes(bitmap.height, bitmap.width, gocv.MatTypeCV8UC1, gray)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mat
+}
+
+func TestEncodeBilevelPNGRoundTrip(t *testing.T) {
+	for _, bitmap := range faxTestBitmaps() {
+		t.Run(bitmap.name, func(t *testing.T) {
+			mat := bilevelMat(t, bitmap)
+			defer mat.Close()
+
+			decoded, err := png.Decode(bytes.NewReader(encodeBilevelPNG(mat, 6)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := decoded.Bounds().Size(); size.X != bitmap.width || size.Y != bitmap.height {
+				t.Fatalf("decoded %v, want %dx%d", size, bitmap.width, bitmap.height)
+			}
+
+			for y := 0; y < bitmap.height; y++ {
+				for x := 0; x < bitmap.width; x++ {
+					black := bitmap.pixels[y*bitmap.width+x] == 1
+					gray := color.GrayModel.Convert(decoded.At(x, y)).(color.Gray).Y
+					if (gray == 0) != black || (gray != 0 && gray != 255) {
+						t.Fatalf("pixel (%d, %d) decoded as %d, want black %v", x, y, gray, black)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeG4TIFFRoundTrip(t *testing.T) {
+	for _, bitmap := range faxTestBitmaps() {
+		t.Run(bitmap.name, func(t *testing.T) {
+			mat := bilevelMat(t, bitmap)
+			defer mat.Close()
+
+			decoded, err := gocv.IMDecode(encodeG4TIFF(mat, Resolution{X: 300, Y: 300}), gocv.IMReadGrayScale)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer decoded.Close()
+			if decoded.Cols() != bitmap.width || decoded.Rows() != bitmap.height {
+				t.Fatalf("decoded %dx%d, want %dx%d", decoded.Cols(), decoded.Rows(), bitmap.width, bitmap.height)
+			}
+
+			difference := gocv.NewMat()
+			defer difference.Close()
+			gocv.AbsDiff(mat, decoded, &difference)
+			if count := gocv.CountNonZero(difference); count != 0 {
+				t.Errorf("%d pixels differ after decoding", count)
+			}
+		})
+	}
+}
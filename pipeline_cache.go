@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"gocv.io/x/gocv"
+)
+
+// defaultCacheBudget bounds the memory held by cached step results. A 600 DPI
+// A4 colour scan is roughly 100 MB per intermediate Mat.
+const defaultCacheBudget int64 = 1 << 30
+
+// stepCache holds the output of every step of one processing pass, so a
+// change at step i only reruns steps i onward. Entries record the
+// transformation instance, its parameters and bypass flag at the time the
+// output was produced; the first mismatch invalidates the rest of the cache.
+type stepCache struct {
+	entries []stepCacheEntry
+}
+
+type stepCacheEntry struct {
+	transformation Transformation
+	params         map[string]interface{}
+	bypassed       bool
+	output         gocv.Mat // empty for bypassed or evicted steps
+	resolution     Resolution
+}
+
+func (e *stepCacheEntry) matches(transformation Transformation, bypassed bool) bool {
+	if e.transformation != transformation || e.bypassed != bypassed {
+		return false
+	}
+	return bypassed || reflect.DeepEqual(e.params, transformation.GetParameters())
+}
+
+// resume drops entries that no longer match transformations and returns the
+// index of the first step to run together with a clone of that step's input
+// and its resolution.
+func (c *stepCache) resume(transformations []Transformation, bypassed map[Transformation]bool, original gocv.Mat, resolution Resolution) (int, gocv.Mat, Resolution) {
+	valid := 0
+	for valid < len(c.entries) && valid < len(transformations) &&
+		c.entries[valid].matches(transformations[valid], bypassed[transformations[valid]]) {
+		valid++
+	}
+	c.truncate(valid)
+
+	for i := valid - 1; i >= 0; i-- {
+		if !c.entries[i].output.Empty() {
+			return i + 1, c.entries[i].output.Clone(), c.entries[i].resolution
+		}
+	}
+	return 0, original.Clone(), resolution
+}
+
+// store records the result of step index, produced with params, and its
+// resolution. output is cloned, so the caller keeps ownership. Outputs over
+// budget bytes are not kept; their entry stays valid like an evicted one.
+func (c *stepCache) store(index int, transformation Transformation, params map[string]interface{}, output gocv.Mat, resolution Resolution, budget int64) {
+	entry := stepCacheEntry{transformation: transformation, params: params, resolution: resolution}
+	if matBytes(output) <= budget {
+		entry.output = output.Clone()
+	} else {
+		entry.output = gocv.NewMat()
+	}
+	c.put(index, entry)
+}
+
+func (c *stepCache) storeBypassed(index int, transformation Transformation) {
+	c.put(index, stepCacheEntry{transformation: transformation, bypassed: true, output: gocv.NewMat()})
+}
+
+func (c *stepCache) put(index int, entry stepCacheEntry) {
+	if index < len(c.entries) {
+		c.entries[index].output.Close()
+		c.entries[index] = entry
+		return
+	}
+	c.entries = append(c.entries, entry)
+}
+
+func (c *stepCache) truncate(length int) {
+	for i := length; i < len(c.entries); i++ {
+		c.entries[i].output.Close()
+	}
+	c.entries = c.entries[:length]
+}
+
+func (c *stepCache) clear() {
+	c.truncate(0)
+}
+
+func (c *stepCache) bytes() int64 {
+	var total int64
+	for _, entry := range c.entries {
+		total += matBytes(entry.output)
+	}
+	return total
+}
+
+// firstCached returns the lowest step index that still holds an output, or -1
+func (c *stepCache) firstCached() int {
+	for i, entry := range c.entries {
+		if !entry.output.Empty() {
+			return i
+		}
+	}
+	return -1
+}
+
+// evict releases the output of step index but keeps its entry valid, so
+// later cached steps can still be reused. It returns the bytes released.
+func (c *stepCache) evict(index int) int64 {
+	released := matBytes(c.entries[index].output)
+	c.entries[index].output.Close()
+	c.entries[index].output = gocv.NewMat()
+	return released
+}
+
+func matBytes(mat gocv.Mat) int64 {
+	if mat.Empty() {
+		return 0
+	}
+	return int64(mat.Total()) * int64(mat.ElemSize())
+}
+
+// SetCacheBudget sets the memory limit in bytes for cached step results.
+// A budget of zero or less disables caching.
+func (p *ImagePipeline) SetCacheBudget(budget int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if budget < 0 {
+		budget = 0
+	}
+	p.cacheBudget = budget
+	p.debugPipeline.Log(fmt.Sprintf("Step cache budget set to %d MB", budget>>20))
+	p.enforceCacheBudgetUnsafe()
+}
+
+// storeStepUnsafe records the output of step index in cache and evicts at
+// once, so a pass never holds more than the budget in cached outputs
+func (p *ImagePipeline) storeStepUnsafe(cache *stepCache, index int, transformation Transformation, params map[string]interface{}, output gocv.Mat, resolution Resolution) {
+	cache.store(index, transformation, params, output, resolution, p.cacheBudget)
+	p.enforceCacheBudgetUnsafe()
+}
+
+// enforceCacheBudgetUnsafe evicts cached outputs, earliest steps first, until
+// both caches fit the budget. Late steps are kept since tuning usually
+// happens at the end of the stack.
+func (p *ImagePipeline) enforceCacheBudgetUnsafe() {
+	total := p.imageCache.bytes() + p.previewCache.bytes()
+	for total > p.cacheBudget {
+		cache := &p.imageCache
+		index := cache.firstCached()
+		if previewIndex := p.previewCache.firstCached(); previewIndex >= 0 && (index < 0 || previewIndex < index) {
+			cache = &p.previewCache
+			index = previewIndex
+		}
+		if index < 0 {
+			break
+		}
+
+		released := cache.evict(index)
+		p.debugPipeline.Log(fmt.Sprintf("Step cache over budget: evicted step %d (%d KB)", index, released>>10))
+		total -= released
+	}
+}
+
+func (p *ImagePipeline) clearCachesUnsafe() {
+	p.imageCache.clear()
+	p.previewCache.clear()
+}
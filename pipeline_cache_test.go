@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"gocv.io/x/gocv"
+)
+
+// countingTransformation passes its input through and counts the full
+// resolution passes it takes part in
+type countingTransformation struct {
+	name    string
+	level   int
+	applied int
+}
+
+var countingParameters = []ParameterSpec{
+	{Name: "level", Label: "Level", Type: ParameterInt, Min: 0, Max: 10, Default: 0},
+}
+
+func (c *countingTransformation) Name() string { return c.name }
+
+func (c *countingTransformation) Apply(ctx context.Context, input gocv.Mat) (gocv.Mat, error) {
+	c.applied++
+	return input.Clone(), nil
+}
+
+func (c *countingTransformation) ApplyPreview(ctx context.Context, input gocv.Mat) (gocv.Mat, error) {
+	return input.Clone(), nil
+}
+
+func (c *countingTransformation) GetParametersWidget(onParameterChanged func()) fyne.CanvasObject {
+	return nil
+}
+
+func (c *countingTransformation) ParameterSpecs() []ParameterSpec {
+	return countingParameters
+}
+
+func (c *countingTransformation) GetParameters() map[string]interface{} {
+	return map[string]interface{}{"level": c.level}
+}
+
+func (c *countingTransformation) SetParameters(params map[string]interface{}) {
+	if level, ok := validParameters(countingParameters, params)["level"]; ok {
+		c.level = level.(int)
+	}
+}
+
+func (c *countingTransformation) Close() {}
+
+// newCountingPipeline loads a small gray image into a pipeline running
+// count counting steps
+func newCountingPipeline(t *testing.T, budget int64, count int) (*ImagePipeline, []*countingTransformation) {
+	t.Helper()
+
+	pipeline := NewImagePipeline(&DebugConfig{})
+	t.Cleanup(pipeline.Close)
+	pipeline.SetCacheBudget(budget)
+
+	steps := make([]*countingTransformation, count)
+	transformations := make([]Transformation, count)
+	for i := range steps {
+		steps[i] = &countingTransformation{name: "Counting"}
+		transformations[i] = steps[i]
+	}
+	if err := pipeline.SetTransformations(transformations); err != nil {
+		t.Fatal(err)
+	}
+
+	img := gocv.NewMatWithSize(16, 16, gocv.MatTypeCV8UC1)
+	defer img.Close()
+	if err := pipeline.SetOriginalImage(img, ImageMetadata{}); err != nil {
+		t.Fatal(err)
+	}
+	return pipeline, steps
+}
+
+func TestStepCacheZeroBudgetKeepsNoOutputs(t *testing.T) {
+	pipeline, _ := newCountingPipeline(t, 0, 3)
+
+	for name, cache := range map[string]*stepCache{"image": &pipeline.imageCache, "preview": &pipeline.previewCache} {
+		if len(cache.entries) != 3 {
+			t.Errorf("%s cache has %d entries, want 3", name, len(cache.entries))
+		}
+		if index := cache.firstCached(); index >= 0 {
+			t.Errorf("%s cache holds the output of step %d with a zero budget", name, index)
+		}
+		if bytes := cache.bytes(); bytes != 0 {
+			t.Errorf("%s cache holds %d bytes with a zero budget", name, bytes)
+		}
+	}
+}
+
+func TestStepCacheStaysWithinBudget(t *testing.T) {
+	// Each 16x16 output takes 256 bytes, so only one fits
+	pipeline, _ := newCountingPipeline(t, 300, 3)
+
+	bytes := pipeline.imageCache.bytes() + pipeline.previewCache.bytes()
+	if bytes != 256 {
+		t.Errorf("caches hold %d bytes, want the one output that fits a budget of 300", bytes)
+	}
+}
+
+func TestStepCacheChangeInvalidatesFromStep(t *testing.T) {
+	for changed := 0; changed < 3; changed++ {
+		pipeline, steps := newCountingPipeline(t, defaultCacheBudget, 3)
+		for i, step := range steps {
+			if step.applied != 1 {
+				t.Fatalf("step %d applied %d times on load, want 1", i, step.applied)
+			}
+		}
+
+		if err := pipeline.SetTransformationParameters(changed, map[string]interface{}{"level": 5}); err != nil {
+			t.Fatal(err)
+		}
+		for i, step := range steps {
+			want := 1
+			if i >= changed {
+				want = 2
+			}
+			if step.applied != want {
+				t.Errorf("change at step %d: step %d applied %d times, want %d", changed, i, step.applied, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+// CCITT Group 4 (ITU-T T.6) coding of bilevel images, which OpenCV does
+// not write. Rows are coded against the row above, so text pages compress
+// far better than with any general purpose method.
+
+// TIFF tags of a bilevel image
+const (
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagPhotometric     = 262
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagT4Options       = 292
+	tagT6Options       = 293
+)
+
+// TIFF compression schemes for fax data
+const (
+	compressionCCITTGroup3 = 3
+	compressionCCITTGroup4 = 4
+)
+
+// faxCode is a code of up to 13 bits, read from the left
+type faxCode struct {
+	bits   uint32
+	length uint
+}
+
+func parseFaxCodes(codes ...string) []faxCode {
+	parsed := make([]faxCode, len(codes))
+	for i, code := range codes {
+		bits, _ := strconv.ParseUint(code, 2, 32)
+		parsed[i] = faxCode{bits: uint32(bits), length: uint(len(code))}
+	}
+	return parsed
+}
+
+// Run length codes: terminating codes for 0-63, then make-up codes for
+// multiples of 64 up to 1728, then the make-up codes both colours share
+// for 1792-2560
+var (
+	faxWhiteCodes = parseFaxCodes(
+		"00110101", "000111", "0111", "1000", "1011", "1100", "1110", "1111",
+		"10011", "10100", "00111", "01000", "001000", "000011", "110100", "110101",
+		"101010", "101011", "0100111", "0001100", "0001000", "0010111", "0000011", "0000100",
+		"0101000", "0101011", "0010011", "0100100", "0011000", "00000010", "00000011", "00011010",
+		"00011011", "00010010", "00010011", "00010100", "00010101", "00010110", "00010111", "00101000",
+		"00101001", "00101010", "00101011", "00101100", "00101101", "00000100", "00000101", "00001010",
+		"00001011", "01010010", "01010011", "01010100", "01010101", "00100100", "00100101", "01011000",
+		"01011001", "01011010", "01011011", "01001010", "01001011", "00110010", "00110011", "00110100",
+		// 64-1728
+		"11011", "10010", "010111", "0110111", "00110110", "00110111", "01100100", "01100101",
+		"01101000", "01100111", "011001100", "011001101", "011010010", "011010011", "011010100", "011010101",
+		"011010110", "011010111", "011011000", "011011001", "011011010", "011011011", "010011000", "010011001",
+		"010011010", "011000", "010011011",
+	)
+	faxBlackCodes = parseFaxCodes(
+		"0000110111", "010", "11", "10", "011", "0011", "0010", "00011",
+		"000101", "000100", "0000100", "0000101", "0000111", "00000100", "00000111", "000011000",
+		"0000010111", "0000011000", "0000001000", "00001100111", "00001101000", "00001101100", "00000110111", "00000101000",
+		"00000010111", "00000011000", "000011001010", "000011001011", "000011001100", "000011001101", "000001101000", "000001101001",
+		"000001101010", "000001101011", "000011010010", "000011010011", "000011010100", "000011010101", "000011010110", "000011010111",
+		"000001101100", "000001101101", "000011011010", "000011011011", "000001010100", "000001010101", "000001010110", "000001010111",
+		"000001100100", "000001100101", "000001010010", "000001010011", "000000100100", "000000110111", "000000111000", "000000100111",
+		"000000101000", "000001011000", "000001011001", "000000101011", "000000101100", "000001011010", "000001100110", "000001100111",
+		// 64-1728
+		"0000001111", "000011001000", "000011001001", "000001011011", "000000110011", "000000110100", "000000110101", "0000001101100",
+		"0000001101101", "0000001001010", "0000001001011", "0000001001100", "0000001001101", "0000001110010", "0000001110011", "0000001110100",
+		"0000001110101", "0000001110110", "0000001110111", "0000001010010", "0000001010011", "0000001010100", "0000001010101", "0000001011010",
+		"0000001011011", "0000001100100", "0000001100101",
+	)
+	faxSharedMakeup = parseFaxCodes(
+		"00000001000", "00000001100", "00000001101", "000000010010", "000000010011", "000000010100", "000000010101",
+		"000000010110", "000000010111", "000000011100", "000000011101", "000000011110", "000000011111",
+	)
+
+	faxPass       = parseFaxCodes("0001")[0]
+	faxHorizontal = parseFaxCodes("001")[0]
+	faxEOL        = parseFaxCodes("000000000001")[0]
+	// Vertical codes by b1 - a1 + 3: VR3 down to VL3
+	faxVertical = parseFaxCodes("0000011", "000011", "011", "1", "010", "000010", "0000010")
+)
+
+// faxWriter packs codes most significant bit first
+type faxWriter struct {
+	data   []byte
+	buffer uint64
+	count  uint
+}
+
+func (w *faxWriter) put(code faxCode) {
+	w.buffer = w.buffer<<code.length | uint64(code.bits)
+	w.count += code.length
+	for w.count >= 8 {
+		w.count -= 8
+		w.data = append(w.data, byte(w.buffer>>w.count))
+	}
+}
+
+// run writes a run of length pixels of one colour
+func (w *faxWriter) run(length int, black bool) {
+	codes := faxWhiteCodes
+	if black {
+		codes = faxBlackCodes
+	}
+	for length >= 2560+64 {
+		w.put(faxSharedMakeup[len(faxSharedMakeup)-1])
+		length -= 2560
+	}
+	if length >= 1792 {
+		w.put(faxSharedMakeup[(length-1792)/64])
+		length %= 64
+	} else if length >= 64 {
+		w.put(codes[63+length/64])
+		length %= 64
+	}
+	w.put(codes[length])
+}
+
+func (w *faxWriter) bytes() []byte {
+	if w.count > 0 {
+		w.data = append(w.data, byte(w.buffer<<(8-w.count)))
+		w.count = 0
+	}
+	return w.data
+}
+
+// encodeG4 codes a bilevel image given as one byte per pixel, 1 for black,
+// row after row
+func encodeG4(pixels []byte, width, height int) []byte {
+	w := &faxWriter{data: make([]byte, 0, len(pixels)/32)}
+	// The row above the first is white
+	reference := make([]byte, width)
+
+	// next is the first position from start on whose colour is not color,
+	// or width
+	next := func(row []byte, start int, color byte) int {
+		for start < width && row[start] == color {
+			start++
+		}
+		return start
+	}
+	colorAt := func(row []byte, position int) byte {
+		if position >= width {
+			return 0
+		}
+		return row[position]
+	}
+
+	for y := 0; y < height; y++ {
+		row := pixels[y*width : (y+1)*width]
+
+		a0 := 0
+		a1 := next(row, 0, 0)
+		b1 := next(reference, 0, 0)
+		for {
+			b2 := next(reference, b1, colorAt(reference, b1))
+			if b2 >= a1 {
+				if d := b1 - a1; d >= -3 && d <= 3 {
+					w.put(faxVertical[d+3])
+					a0 = a1
+				} else {
+					// At the start of the row a0 is the imaginary white pixel before it
+					color := colorAt(row, a0)
+					if a0 == 0 && a1 == 0 {
+						color = 0
+					}
+					a2 := next(row, a1, colorAt(row, a1))
+					w.put(faxHorizontal)
+					w.run(a1-a0, color == 1)
+					w.run(a2-a1, color != 1)
+					a0 = a2
+				}
+			} else {
+				w.put(faxPass)
+				a0 = b2
+			}
+			if a0 >= width {
+				break
+			}
+
+			color := colorAt(row, a0)
+			a1 = next(row, a0, color)
+			b1 = next(reference, a0, 1-color)
+			b1 = next(reference, b1, color)
+		}
+		reference = row
+	}
+
+	// End of facsimile block
+	w.put(faxEOL)
+	w.put(faxEOL)
+	return w.bytes()
+}
+
+// faxTIFF wraps fax coded data in a one-strip TIFF of a bilevel image.
+// entries add the compression and its options.
+func faxTIFF(data []byte, width, height int, entries ...tiffEntry) []byte {
+	ifd := tiffIFD{
+		longEntry(tagImageWidth, uint32(width)),
+		longEntry(tagImageLength, uint32(height)),
+		shortEntry(tagBitsPerSample, 1),
+		shortEntry(tagPhotometric, 0), // WhiteIsZero: fax coding gives 1 for black
+		shortEntry(tagSamplesPerPixel, 1),
+		longEntry(tagRowsPerStrip, uint32(height)),
+		longEntry(tagStripOffsets, 8),
+		longEntry(tagStripByteCounts, uint32(len(data))),
+	}
+	for _, entry := range entries {
+		ifd = ifd.set(entry)
+	}
+
+	tiff := append([]byte{'I', 'I', 42, 0, 0, 0, 0, 0}, data...)
+	tiff, offset := appendIFD(tiff, 0, binary.LittleEndian, ifd, 0)
+	binary.LittleEndian.PutUint32(tiff[4:], offset)
+	return tiff
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// faxBitmap is a bilevel test image, one byte per pixel with 1 for black
+type faxBitmap struct {
+	name          string
+	width, height int
+	pixels        []byte
+}
+
+func newFaxBitmap(name string, width, height int, black func(x, y int) bool) faxBitmap {
+	pixels := make([]byte, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if black(x, y) {
+				pixels[y*width+x] = 1
+			}
+		}
+	}
+	return faxBitmap{name: name, width: width, height: height, pixels: pixels}
+}
+
+// faxTestBitmaps cover blank pages, every make-up code boundary of
+// faxWriter.run in both colours, odd widths and a row starting black
+func faxTestBitmaps() []faxBitmap {
+	bitmaps := []faxBitmap{
+		newFaxBitmap("all white", 40, 5, func(x, y int) bool { return false }),
+		newFaxBitmap("all black", 40, 5, func(x, y int) bool { return true }),
+		newFaxBitmap("width not a multiple of 8", 13, 7, func(x, y int) bool { return (x+y)%3 == 0 }),
+		newFaxBitmap("first row starts black", 20, 4, func(x, y int) bool { return x < 5 || y == 2 }),
+		newFaxBitmap("single column", 1, 6, func(x, y int) bool { return y%2 == 0 }),
+	}
+
+	for _, length := range []int{63, 64, 65, 1727, 1728, 1729, 1791, 1792, 1793, 2560, 2560 + 63, 2560 + 64, 2560 + 65, 5000} {
+		// A white run of length then a black run of length, and the same
+		// starting black on the next rows, shifted so vertical and
+		// horizontal coding both occur
+		width := 2*length + 10
+		bitmaps = append(bitmaps, newFaxBitmap(fmt.Sprintf("runs of %d", length), width, 4, func(x, y int) bool {
+			switch y {
+			case 0:
+				return x >= length && x < 2*length
+			case 1:
+				return x < length
+			case 2:
+				return x < length+2
+			}
+			return x >= 5 && x < length+5
+		}))
+	}
+
+	random := rand.New(rand.NewSource(1))
+	bitmaps = append(bitmaps, newFaxBitmap("noise", 101, 37, func(x, y int) bool { return random.Intn(3) == 0 }))
+	return bitmaps
+}
+
+func TestEncodeG4RoundTrip(t *testing.T) {
+	for _, bitmap := range faxTestBitmaps() {
+		t.Run(bitmap.name, func(t *testing.T) {
+			data := encodeG4(bitmap.pixels, bitmap.width, bitmap.height)
+			tiff := faxTIFF(data, bitmap.width, bitmap.height, shortEntry(tagCompression, compressionCCITTGroup4))
+
+			mat, err := gocv.IMDecode(tiff, gocv.IMReadGrayScale)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mat.Close()
+			if mat.Cols() != bitmap.width || mat.Rows() != bitmap.height {
+				t.Fatalf("decoded %dx%d, want %dx%d", mat.Cols(), mat.Rows(), bitmap.width, bitmap.height)
+			}
+
+			for y := 0; y < bitmap.height; y++ {
+				for x := 0; x < bitmap.width; x++ {
+					black := bitmap.pixels[y*bitmap.width+x] == 1
+					if got := mat.GetUCharAt(y, x); (got == 0) != black {
+						t.Fatalf("pixel (%d, %d) decoded as %d, want black %v", x, y, got, black)
+					}
+				}
+			}
+		})
+	}
+}
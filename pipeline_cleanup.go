@@ -3,6 +3,8 @@ package main
 import "gocv.io/x/gocv"
 
 func (p *ImagePipeline) cleanupResourcesUnsafe() {
+	p.clearCachesUnsafe()
+
 	if !p.originalImage.Empty() {
 		p.debugPipeline.LogResourceCleanup("originalImage", true)
 		p.originalImage.Close()
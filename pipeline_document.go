@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// Document is an input file opened page by page: the pages of a multi-page
+// TIFF or a PDF, or a single image as a document of one page. Pages are
+// loaded when asked for, so large documents are never held in memory.
+type Document struct {
+	Path  string
+	pages []documentPage
+}
+
+// documentPage loads one page with its metadata
+type documentPage func() (gocv.Mat, ImageMetadata, error)
+
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// Page loads page index, counting from 0. Damaged metadata does not fail
+// the page; whatever could be read is kept.
+func (d *Document) Page(index int) (gocv.Mat, ImageMetadata, error) {
+	if index < 0 || index >= len(d.pages) {
+		return gocv.NewMat(), ImageMetadata{}, fmt.Errorf("%s has no page %d", filepath.Base(d.Path), index+1)
+	}
+	return d.pages[index]()
+}
+
+// openDocument finds the pages of the file at path by its content
+func openDocument(path string) (*Document, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 8)
+	n, _ := io.ReadFull(file, header)
+	header = header[:n]
+
+	document := &Document{Path: path}
+	switch {
+	case bytes.HasPrefix(header, []byte("%PDF-")):
+		pages, err := openPDF(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open PDF %s: %w", filepath.Base(path), err)
+		}
+		for i, page := range pages {
+			document.pages = append(document.pages, func() (gocv.Mat, ImageMetadata, error) {
+				return page.load(path, i+1)
+			})
+		}
+	case bytes.HasPrefix(header, []byte("II*\x00")) || bytes.HasPrefix(header, []byte("MM\x00*")):
+		// A damaged chain still gives the pages before the damage
+		offsets, _ := tiffPageOffsets(file)
+		if len(offsets) > 1 {
+			for i := range offsets {
+				document.pages = append(document.pages, tiffPage(path, i))
+			}
+		}
+	}
+
+	if len(document.pages) == 0 {
+		document.pages = []documentPage{func() (gocv.Mat, ImageMetadata, error) {
+			mat, err := readImage(path)
+			if err != nil {
+				return mat, ImageMetadata{}, err
+			}
+			metadata, _ := readMetadata(path)
+			return mat, metadata, nil
+		}}
+	}
+	return document, nil
+}
+
+// imageDocument is a document of one page, mat with metadata, named path.
+// mat must stay open while the document is used.
+func imageDocument(path string, mat gocv.Mat, metadata ImageMetadata) *Document {
+	return &Document{Path: path, pages: []documentPage{func() (gocv.Mat, ImageMetadata, error) {
+		return mat.Clone(), metadata, nil
+	}}}
+}
+
+func tiffPage(path string, page int) documentPage {
+	return func() (gocv.Mat, ImageMetadata, error) {
+		mat, err := readImagePage(path, page)
+		if err != nil {
+			return mat, ImageMetadata{}, err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return mat, ImageMetadata{}, nil
+		}
+		defer file.Close()
+		metadata, _ := readTIFFPageMetadata(file, page)
+		return mat, metadata, nil
+	}
+}
+
+// documentOutputPaths lists the files exportDocument writes for doc: the
+// output path itself for a single page, a multi-page TIFF or a PDF, and
+// otherwise one file per page numbered after the output name
+func documentOutputPaths(doc *Document, outputPath string) []string {
+	ext := filepath.Ext(outputPath)
+	if isTIFFPath(outputPath) || isPDFPath(outputPath) || doc.PageCount() == 1 {
+		return []string{outputPath}
+	}
+
+	width := max(3, len(fmt.Sprint(doc.PageCount())))
+	base := strings.TrimSuffix(outputPath, ext)
+	paths := make([]string, doc.PageCount())
+	for i := range paths {
+		paths[i] = fmt.Sprintf("%s_%0*d%s", base, width, i+1, ext)
+	}
+	return paths
+}
+
+func isTIFFPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".tif" || ext == ".tiff"
+}
+
+func isPDFPath(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".pdf"
+}
+
+// ExportOptions adjust how exportDocument writes pages
+type ExportOptions struct {
+	// Save are the encoder settings, such as DefaultSaveOptions
+	Save SaveOptions
+	// Warn, if not nil, is told once when bilevel pages go to a lossy format
+	Warn func(string)
+	// OriginalLayer adds each page as loaded to PDF output, in a layer
+	// hidden until switched on
+	OriginalLayer bool
+	// Recognizer, if not nil, supplies the invisible text of PDF output
+	Recognizer TextRecognizer
+}
+
+// exportDocument runs every page of doc through pipeline, which must have
+// its transformations set, and writes the results to the files
+// documentOutputPaths names. It stops when ctx is done, and reports the page
+// and transformation it is working on as stages.
+func exportDocument(ctx context.Context, pipeline *ImagePipeline, doc *Document, outputPath string, options ExportOptions) ([]string, error) {
+	paths := documentOutputPaths(doc, outputPath)
+	warn := options.Warn
+	if warn != nil {
+		once := warn
+		warn = func(message string) {
+			if once != nil {
+				once(message)
+				once = nil
+			}
+		}
+	}
+
+	var pages *tiffPageWriter
+	var pdf *pdfPageWriter
+	var err error
+	if isPDFPath(outputPath) {
+		pdf, err = createPDF(outputPath, options.Save)
+	} else if isTIFFPath(outputPath) && doc.PageCount() > 1 {
+		pages, err = createTIFFPages(outputPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", outputPath, err)
+	}
+	// A multi-page file left unfinished is removed
+	fail := func(err error) ([]string, error) {
+		if pages != nil {
+			pages.Close()
+			os.Remove(outputPath)
+		}
+		if pdf != nil {
+			pdf.abort()
+			os.Remove(outputPath)
+		}
+		return nil, err
+	}
+
+	for i := 0; i < doc.PageCount(); i++ {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		ReportProgress(ctx, float64(i)/float64(doc.PageCount()))
+		pageCtx := ProgressRange(ctx, float64(i)/float64(doc.PageCount()), float64(i+1)/float64(doc.PageCount()))
+		if doc.PageCount() > 1 {
+			pageCtx = SubStage(pageCtx, fmt.Sprintf("Page %d/%d", i+1, doc.PageCount()))
+		}
+
+		if pdf != nil {
+			if err := addPDFPage(pageCtx, pdf, pipeline, doc, i, options); err != nil {
+				return fail(fmt.Errorf("page %d: %w", i+1, err))
+			}
+			pipeline.debugPipeline.Log(fmt.Sprintf("Exported page %d of %d", i+1, doc.PageCount()))
+			continue
+		}
+
+		data, err := processPage(pageCtx, pipeline, doc, i, filepath.Ext(outputPath), options.Save, warn)
+		if err != nil {
+			return fail(fmt.Errorf("page %d: %w", i+1, err))
+		}
+		if pages != nil {
+			err = pages.add(data)
+		} else {
+			err = os.WriteFile(paths[i], data, 0o644)
+		}
+		if err != nil {
+			return fail(fmt.Errorf("page %d: failed to write: %w", i+1, err))
+		}
+		pipeline.debugPipeline.Log(fmt.Sprintf("Exported page %d of %d", i+1, doc.PageCount()))
+	}
+	ReportProgress(ctx, 1)
+
+	if pages != nil {
+		if err := pages.Close(); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", outputPath, err)
+		}
+	}
+	if pdf != nil {
+		if err := pdf.Close(); err != nil {
+			os.Remove(outputPath)
+			return nil, fmt.Errorf("failed to write %s: %w", outputPath, err)
+		}
+	}
+	return paths, nil
+}
+
+// processPage loads page index of doc, processes it and encodes the result
+// for ext
+func processPage(ctx context.Context, pipeline *ImagePipeline, doc *Document, index int, ext string, save SaveOptions, warn func(string)) ([]byte, error) {
+	original, processed, outputMetadata, err := runPage(ctx, pipeline, doc, index)
+	defer original.Close()
+	defer processed.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	ext = strings.ToLower(ext)
+	if warning := bilevelWarning(ext, processed); warning != "" && warn != nil {
+		warn(warning)
+	}
+	return encodeImage(ext, processed, outputMetadata, save)
+}
+
+// addPDFPage loads page index of doc, processes it and adds it to pdf with
+// the layers options ask for
+func addPDFPage(ctx context.Context, pdf *pdfPageWriter, pipeline *ImagePipeline, doc *Document, index int, options ExportOptions) error {
+	original, processed, metadata, err := runPage(ctx, pipeline, doc, index)
+	defer original.Close()
+	defer processed.Close()
+	if err != nil {
+		return err
+	}
+
+	page := pdfOutputPage{image: processed, original: gocv.NewMat(), metadata: metadata}
+	defer page.original.Close()
+	if options.OriginalLayer {
+		page.original = original.Clone()
+	}
+	if options.Recognizer != nil {
+		ReportStage(ctx, "Text recognition")
+		if page.words, err = options.Recognizer.Recognize(ctx, processed, metadata.Resolution); err != nil {
+			return fmt.Errorf("text recognition: %w", err)
+		}
+		pipeline.debugPipeline.Log(fmt.Sprintf("Recognized %d words on page %d", len(page.words), index+1))
+	}
+	return pdf.add(page)
+}
+
+// runPage loads page index of doc and runs it through pipeline. It returns
+// the page as loaded and the result with the metadata to save it with; the
+// caller closes both mats, even on error.
+func runPage(ctx context.Context, pipeline *ImagePipeline, doc *Document, index int) (gocv.Mat, gocv.Mat, ImageMetadata, error) {
+	original, metadata, err := doc.Page(index)
+	if err != nil {
+		return original, gocv.NewMat(), ImageMetadata{}, err
+	}
+	if err := pipeline.SetOriginalImageContext(ctx, original, metadata); err != nil {
+		return original, gocv.NewMat(), ImageMetadata{}, err
+	}
+
+	processed := pipeline.GetProcessedImage()
+	if processed.Empty() {
+		return original, processed, ImageMetadata{}, fmt.Errorf("no processed image available")
+	}
+	outputMetadata, err := pipeline.ProcessedMetadata()
+	return original, processed, outputMetadata, err
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+const defaultHistoryLimit = 100
+
+// historyCommand is one undoable pipeline edit. Commands store transformation
+// identifiers and parameter values rather than instances or Mats, so a long
+// history stays small. undo and redo run with the pipeline mutex held.
+type historyCommand interface {
+	Description() string
+	undo(p *ImagePipeline) error
+	redo(p *ImagePipeline) error
+}
+
+type PipelineHistory struct {
+	undoStack []historyCommand
+	redoStack []historyCommand
+	limit     int
+}
+
+func NewPipelineHistory(limit int) *PipelineHistory {
+	return &PipelineHistory{limit: limit}
+}
+
+func (h *PipelineHistory) record(command historyCommand) {
+	h.undoStack = append(h.undoStack, command)
+	if len(h.undoStack) > h.limit {
+		h.undoStack = h.undoStack[len(h.undoStack)-h.limit:]
+	}
+	h.redoStack = nil
+}
+
+func (h *PipelineHistory) reset() {
+	h.undoStack = nil
+	h.redoStack = nil
+}
+
+// stepState is the serializable state of one transformation in the stack
+type stepState struct {
+	id       string
+	params   map[string]interface{}
+	bypassed bool
+}
+
+func (p *ImagePipeline) captureStep(transformation Transformation) (stepState, error) {
+	id, err := transformationRegistry.IDOf(transformation)
+	if err != nil {
+		return stepState{}, err
+	}
+	return stepState{id: id, params: transformation.GetParameters(), bypassed: p.bypassed[transformation]}, nil
+}
+
+func (p *ImagePipeline) captureSteps(transformations []Transformation) ([]stepState, error) {
+	steps := make([]stepState, 0, len(transformations))
+	for _, transformation := range transformations {
+		step, err := p.captureStep(transformation)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func (p *ImagePipeline) restoreStep(step stepState) (Transformation, error) {
+	transformation, err := transformationRegistry.New(step.id, p.config)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyParameters(transformation, step.params); err != nil {
+		transformation.Close()
+		return nil, err
+	}
+	if step.bypassed {
+		p.bypassed[transformation] = true
+	}
+	return transformation, nil
+}
+
+// insertRestoredUnsafe inserts a step made by restoreStep, releasing it
+// when the history no longer matches the stack
+func (p *ImagePipeline) insertRestoredUnsafe(index int, transformation Transformation) error {
+	if err := p.insertTransformationUnsafe(index, transformation); err != nil {
+		delete(p.bypassed, transformation)
+		transformation.Close()
+		return err
+	}
+	return nil
+}
+
+func (p *ImagePipeline) restoreSteps(steps []stepState) ([]Transformation, error) {
+	transformations := make([]Transformation, 0, len(steps))
+	for _, step := range steps {
+		transformation, err := p.restoreStep(step)
+		if err != nil {
+			for _, created := range transformations {
+				delete(p.bypassed, created)
+				created.Close()
+			}
+			return nil, err
+		}
+		transformations = append(transformations, transformation)
+	}
+	return transformations, nil
+}
+
+// recordUnsafe adds command to the history. Edits that cannot be captured
+// invalidate the history instead, since later commands rely on stack indices.
+func (p *ImagePipeline) recordUnsafe(command historyCommand, err error) {
+	if err != nil {
+		p.debugPipeline.Log(fmt.Sprintf("History cleared, edit cannot be recorded: %v", err))
+		p.history.reset()
+		return
+	}
+	p.debugPipeline.Log(fmt.Sprintf("History: recorded '%s'", command.Description()))
+	p.history.record(command)
+}
+
+func (p *ImagePipeline) snapshotParametersUnsafe(transformation Transformation) {
+	p.paramSnapshots[transformation] = transformation.GetParameters()
+}
+
+// CommitParameterChanges records parameter edits made directly on
+// transformations, for example through their parameter widgets, as undoable
+// deltas. It returns true if any change was recorded.
+func (p *ImagePipeline) CommitParameterChanges() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.commitParameterChangesUnsafe()
+}
+
+func (p *ImagePipeline) commitParameterChangesUnsafe() bool {
+	recorded := false
+	for i, transformation := range p.transformations {
+		snapshot, ok := p.paramSnapshots[transformation]
+		if !ok {
+			p.snapshotParametersUnsafe(transformation)
+			continue
+		}
+
+		before, after := parameterDelta(snapshot, transformation.GetParameters())
+		if len(after) == 0 {
+			continue
+		}
+		p.recordUnsafe(&parameterCommand{index: i, name: transformation.Name(), before: before, after: after}, nil)
+		p.snapshotParametersUnsafe(transformation)
+		recorded = true
+	}
+	return recorded
+}
+
+// SetTransformationParameters applies params to the transformation at index
+// and records the change in the history.
+func (p *ImagePipeline) SetTransformationParameters(index int, params map[string]interface{}) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if index < 0 || index >= len(p.transformations) {
+		return fmt.Errorf("transformation index %d out of range", index)
+	}
+
+	p.commitParameterChangesUnsafe()
+
+	transformation := p.transformations[index]
+	if err := applyParameters(transformation, params); err != nil {
+		transformation.SetParameters(p.paramSnapshots[transformation])
+		return fmt.Errorf("%s: %w", transformation.Name(), err)
+	}
+	p.commitParameterChangesUnsafe()
+
+	return p.reprocessUnsafe()
+}
+
+func (p *ImagePipeline) CanUndo() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return len(p.history.undoStack) > 0
+}
+
+func (p *ImagePipeline) CanRedo() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return len(p.history.redoStack) > 0
+}
+
+// Undo reverts the most recent edit and returns its description
+func (p *ImagePipeline) Undo() (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.commitParameterChangesUnsafe()
+
+	if len(p.history.undoStack) == 0 {
+		return "", fmt.Errorf("nothing to undo")
+	}
+
+	last := len(p.history.undoStack) - 1
+	command := p.history.undoStack[last]
+	p.history.undoStack = p.history.undoStack[:last]
+
+	p.debugPipeline.Log(fmt.Sprintf("History: undo '%s'", command.Description()))
+	if err := command.undo(p); err != nil {
+		p.history.reset()
+		return "", fmt.Errorf("undo %s: %w", command.Description(), err)
+	}
+	p.history.redoStack = append(p.history.redoStack, command)
+
+	return command.Description(), p.reprocessUnsafe()
+}
+
+// Redo reapplies the most recently undone edit and returns its description
+func (p *ImagePipeline) Redo() (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// A pending parameter edit starts a new branch and clears the redo stack
+	p.commitParameterChangesUnsafe()
+
+	if len(p.history.redoStack) == 0 {
+		return "", fmt.Errorf("nothing to redo")
+	}
+
+	last := len(p.history.redoStack) - 1
+	command := p.history.redoStack[last]
+	p.history.redoStack = p.history.redoStack[:last]
+
+	p.debugPipeline.Log(fmt.Sprintf("History: redo '%s'", command.Description()))
+	if err := command.redo(p); err != nil {
+		p.history.reset()
+		return "", fmt.Errorf("redo %s: %w", command.Description(), err)
+	}
+	p.history.undoStack = append(p.history.undoStack, command)
+
+	return command.Description(), p.reprocessUnsafe()
+}
+
+func (p *ImagePipeline) reprocessUnsafe() error {
+	if !p.HasImageUnsafe() {
+		return nil
+	}
+	if err := p.processImageUnsafe(context.Background()); err != nil {
+		return fmt.Errorf("failed to process image: %w", err)
+	}
+	if err := p.processPreviewUnsafe(context.Background()); err != nil {
+		return fmt.Errorf("failed to process preview: %w", err)
+	}
+	return nil
+}
+
+// parameterDelta returns the old and new values of the parameters that differ
+func parameterDelta(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	oldValues := make(map[string]interface{})
+	newValues := make(map[string]interface{})
+	for name, value := range after {
+		if old, ok := before[name]; ok && !reflect.DeepEqual(old, value) {
+			oldValues[name] = old
+			newValues[name] = value
+		}
+	}
+	return oldValues, newValues
+}
+
+type addCommand struct {
+	index int
+	step  stepState
+}
+
+func (c *addCommand) Description() string {
+	return fmt.Sprintf("add %s", c.step.id)
+}
+
+func (c *addCommand) undo(p *ImagePipeline) error {
+	return p.removeTransformationUnsafe(c.index)
+}
+
+func (c *addCommand) redo(p *ImagePipeline) error {
+	transformation, err := p.restoreStep(c.step)
+	if err != nil {
+		return err
+	}
+	return p.insertRestoredUnsafe(c.index, transformation)
+}
+
+type removeCommand struct {
+	index int
+	step  stepState
+}
+
+func (c *removeCommand) Description() string {
+	return fmt.Sprintf("remove %s", c.step.id)
+}
+
+func (c *removeCommand) undo(p *ImagePipeline) error {
+	transformation, err := p.restoreStep(c.step)
+	if err != nil {
+		return err
+	}
+	return p.insertRestoredUnsafe(c.index, transformation)
+}
+
+func (c *removeCommand) redo(p *ImagePipeline) error {
+	return p.removeTransformationUnsafe(c.index)
+}
+
+// replaceCommand covers Reset, recipe loading and other whole-stack edits
+type replaceCommand struct {
+	description string
+	before      []stepState
+	after       []stepState
+}
+
+func (c *replaceCommand) Description() string {
+	return c.description
+}
+
+func (c *replaceCommand) undo(p *ImagePipeline) error {
+	transformations, err := p.restoreSteps(c.before)
+	if err != nil {
+		return err
+	}
+	p.replaceTransformationsUnsafe(transformations)
+	return nil
+}
+
+func (c *replaceCommand) redo(p *ImagePipeline) error {
+	transformations, err := p.restoreSteps(c.after)
+	if err != nil {
+		return err
+	}
+	p.replaceTransformationsUnsafe(transformations)
+	return nil
+}
+
+type moveCommand struct {
+	from int
+	to   int
+}
+
+func (c *moveCommand) Description() string {
+	return fmt.Sprintf("move step %d to %d", c.from+1, c.to+1)
+}
+
+func (c *moveCommand) undo(p *ImagePipeline) error {
+	return p.moveTransformationUnsafe(c.to, c.from)
+}
+
+func (c *moveCommand) redo(p *ImagePipeline) error {
+	return p.moveTransformationUnsafe(c.from, c.to)
+}
+
+type bypassCommand struct {
+	index    int
+	bypassed bool
+}
+
+func (c *bypassCommand) Description() string {
+	if c.bypassed {
+		return fmt.Sprintf("bypass step %d", c.index+1)
+	}
+	return fmt.Sprintf("enable step %d", c.index+1)
+}
+
+func (c *bypassCommand) undo(p *ImagePipeline) error {
+	return p.setBypassedUnsafe(c.index, !c.bypassed)
+}
+
+func (c *bypassCommand) redo(p *ImagePipeline) error {
+	return p.setBypassedUnsafe(c.index, c.bypassed)
+}
+
+type parameterCommand struct {
+	index  int
+	name   string
+	before map[string]interface{}
+	after  map[string]interface{}
+}
+
+func (c *parameterCommand) Description() string {
+	return fmt.Sprintf("change %s parameters", c.name)
+}
+
+func (c *parameterCommand) undo(p *ImagePipeline) error {
+	return p.setParametersUnsafe(c.index, c.before)
+}
+
+func (c *parameterCommand) redo(p *ImagePipeline) error {
+	return p.setParametersUnsafe(c.index, c.after)
+}
+
+func (p *ImagePipeline) setParametersUnsafe(index int, params map[string]interface{}) error {
+	if index < 0 || index >= len(p.transformations) {
+		return fmt.Errorf("transformation index %d out of range", index)
+	}
+	transformation := p.transformations[index]
+	if err := applyParameters(transformation, params); err != nil {
+		return err
+	}
+	p.snapshotParametersUnsafe(transformation)
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func newHistoryPipeline(t *testing.T) *ImagePipeline {
+	t.Helper()
+
+	pipeline := NewImagePipeline(&DebugConfig{})
+	t.Cleanup(pipeline.Close)
+
+	gray := make([]byte, 16*16)
+	for i := range gray {
+		gray[i] = byte(i)
+	}
+	img, err := gocv.NewMatFromBytes(16, 16, gocv.MatTypeCV8UC1, gray)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer img.Close()
+	if err := pipeline.SetOriginalImage(img, ImageMetadata{}); err != nil {
+		t.Fatal(err)
+	}
+	return pipeline
+}
+
+func newRegistered(t *testing.T, id string) Transformation {
+	t.Helper()
+	transformation, err := transformationRegistry.New(id, &DebugConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transformation
+}
+
+func pipelineSteps(t *testing.T, pipeline *ImagePipeline) []stepState {
+	t.Helper()
+	steps, err := pipeline.captureSteps(pipeline.transformations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return steps
+}
+
+func TestHistoryUndoRedoSequence(t *testing.T) {
+	pipeline := newHistoryPipeline(t)
+
+	// Each edit is one command; states[i] is the stack after i commands
+	states := [][]stepState{pipelineSteps(t, pipeline)}
+	edits := []struct {
+		name string
+		edit func() error
+	}{
+		{"add grayscale", func() error { return pipeline.AddTransformation(newRegistered(t, "grayscale")) }},
+		{"add 2d-otsu", func() error { return pipeline.AddTransformation(newRegistered(t, "2d-otsu")) }},
+		{"set parameters", func() error {
+			return pipeline.SetTransformationParameters(1, map[string]interface{}{
+				"epsilon": 0.037, "classes": 3, "regionMode": regionModeTiles,
+			})
+		}},
+		{"edit parameters directly", func() error {
+			pipeline.transformations[0].SetParameters(map[string]interface{}{"background": 200})
+			if !pipeline.CommitParameterChanges() {
+				t.Error("direct parameter edit not recorded")
+			}
+			return nil
+		}},
+		{"bypass", func() error { return pipeline.SetTransformationBypassed(0, true) }},
+		{"move", func() error { return pipeline.MoveTransformation(0, 1) }},
+		{"remove", func() error { return pipeline.RemoveTransformation(0) }},
+		{"replace", func() error {
+			return pipeline.SetTransformations([]Transformation{newRegistered(t, "grayscale"), newRegistered(t, "2d-otsu")})
+		}},
+	}
+	for _, edit := range edits {
+		if err := edit.edit(); err != nil {
+			t.Fatalf("%s: %v", edit.name, err)
+		}
+		states = append(states, pipelineSteps(t, pipeline))
+		if pipeline.CanRedo() {
+			t.Fatalf("%s: redo available after a new command", edit.name)
+		}
+	}
+
+	for i := len(edits) - 1; i >= 0; i-- {
+		if _, err := pipeline.Undo(); err != nil {
+			t.Fatalf("undo %s: %v", edits[i].name, err)
+		}
+		if got := pipelineSteps(t, pipeline); !reflect.DeepEqual(got, states[i]) {
+			t.Fatalf("undo %s: stack %+v, want %+v", edits[i].name, got, states[i])
+		}
+	}
+	if pipeline.CanUndo() {
+		t.Errorf("undo available before the first command")
+	}
+
+	for i := range edits {
+		if _, err := pipeline.Redo(); err != nil {
+			t.Fatalf("redo %s: %v", edits[i].name, err)
+		}
+		if got := pipelineSteps(t, pipeline); !reflect.DeepEqual(got, states[i+1]) {
+			t.Fatalf("redo %s: stack %+v, want %+v", edits[i].name, got, states[i+1])
+		}
+	}
+	if pipeline.CanRedo() {
+		t.Errorf("redo available after the last command")
+	}
+}
+
+func TestHistoryNewCommandClearsRedo(t *testing.T) {
+	pipeline := newHistoryPipeline(t)
+	for _, id := range []string{"grayscale", "2d-otsu", "grayscale"} {
+		if err := pipeline.AddTransformation(newRegistered(t, id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := pipeline.Undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !pipeline.CanRedo() {
+		t.Fatal("no redo after undo")
+	}
+
+	if err := pipeline.SetTransformationBypassed(0, true); err != nil {
+		t.Fatal(err)
+	}
+	if pipeline.CanRedo() {
+		t.Error("redo available after a new command")
+	}
+	if _, err := pipeline.Redo(); err == nil {
+		t.Error("redo succeeded after a new command")
+	}
+
+	// A direct parameter edit is a new command too, committed by Redo itself
+	if _, err := pipeline.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	pipeline.transformations[0].SetParameters(map[string]interface{}{"method": grayscaleAverage})
+	if _, err := pipeline.Redo(); err == nil {
+		t.Error("redo succeeded after a pending parameter edit")
+	}
+	if steps := pipelineSteps(t, pipeline); steps[0].bypassed || steps[0].params["method"] != grayscaleAverage {
+		t.Errorf("step %+v, want enabled with the edited method", steps[0])
+	}
+}
+
+func TestRestoreStepParameters(t *testing.T) {
+	pipeline := NewImagePipeline(&DebugConfig{})
+	defer pipeline.Close()
+
+	steps := []stepState{{
+		id: "2d-otsu",
+		params: map[string]interface{}{
+			"windowRadius": 7, "epsilon": 0.0371, "morphKernelSize": 5, "noiseReduction": false,
+			"useIntegralImage": false, "adaptiveRegions": 3, "regionMode": regionModeTiles,
+			"regionOverlap": 0.35, "classes": 4, "multiLevelOutput": multiLevelLabels,
+			"manualThresholds": true, "manualS": 17, "manualT": 201,
+		},
+		bypassed: true,
+	}}
+	// Every registered transformation comes back with its default parameters
+	for _, id := range transformationRegistry.IDs() {
+		transformation := newRegistered(t, id)
+		steps = append(steps, stepState{id: id, params: transformation.GetParameters()})
+		transformation.Close()
+	}
+
+	for _, step := range steps {
+		t.Run(step.id, func(t *testing.T) {
+			transformation, err := pipeline.restoreStep(step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer transformation.Close()
+
+			captured, err := pipeline.captureStep(transformation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(captured, step) {
+				t.Errorf("restored as %+v, want %+v", captured, step)
+			}
+		})
+	}
+}
+
+// Commands that no longer match the stack fail without leaving their
+// restored step behind
+func TestHistoryRestoreOutOfRange(t *testing.T) {
+	pipeline := NewImagePipeline(&DebugConfig{})
+	defer pipeline.Close()
+
+	step := stepState{id: "grayscale", params: map[string]interface{}{}, bypassed: true}
+	add := &addCommand{index: 3, step: step}
+	remove := &removeCommand{index: 3, step: step}
+	restores := map[string]func(p *ImagePipeline) error{"redo add": add.redo, "undo remove": remove.undo}
+
+	for name, restore := range restores {
+		if err := restore(pipeline); err == nil {
+			t.Errorf("%s: inserted at index 3 of an empty stack", name)
+		}
+		if len(pipeline.transformations) != 0 || len(pipeline.bypassed) != 0 {
+			t.Errorf("%s: %d steps and %d bypass flags left behind", name, len(pipeline.transformations), len(pipeline.bypassed))
+		}
+	}
+}
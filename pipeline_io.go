@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// OpenCV's write flags for JPEG subsampling and TIFF, which gocv does not
+// name
+const (
+	imwriteJpegSamplingFactor = 7
+	imwriteTiffResUnit        = 256
+	imwriteTiffXDPI           = 257
+	imwriteTiffYDPI           = 258
+	imwriteTiffCompression    = 259
+)
+
+// readImage loads path as gray, BGR or BGRA, keeping an alpha channel when
+// the file has one. 8-bit, 16-bit and 32-bit float samples are kept as they
+// are; double precision becomes float and other depths 8 bits.
+func readImage(path string) (gocv.Mat, error) {
+	return decodeImage(filepath.Base(path), func(flags gocv.IMReadFlag) gocv.Mat {
+		return gocv.IMRead(path, flags)
+	})
+}
+
+// readImagePage is readImage for one page of a multi-page file
+func readImagePage(path string, page int) (gocv.Mat, error) {
+	name := fmt.Sprintf("%s page %d", filepath.Base(path), page+1)
+	return decodeImage(name, func(flags gocv.IMReadFlag) gocv.Mat {
+		mats := gocv.IMReadMulti_WithParams(path, page, 1, flags)
+		if len(mats) == 0 {
+			return gocv.NewMat()
+		}
+		for _, extra := range mats[1:] {
+			extra.Close()
+		}
+		return mats[0]
+	})
+}
+
+// decodeImage brings the image read returns to the layouts and depths
+// readImage promises. name identifies the image in errors.
+func decodeImage(name string, read func(flags gocv.IMReadFlag) gocv.Mat) (gocv.Mat, error) {
+	mat := read(gocv.IMReadUnchanged)
+	if mat.Empty() {
+		mat.Close()
+		return gocv.NewMat(), fmt.Errorf("failed to load image %s", name)
+	}
+
+	// Layouts other than 1, 3 or 4 channels are read again as plain colour
+	if channels := mat.Channels(); channels != 1 && channels != 3 && channels != 4 {
+		mat.Close()
+		mat = read(gocv.IMReadColor)
+		if mat.Empty() {
+			mat.Close()
+			return gocv.NewMat(), fmt.Errorf("failed to load image %s", name)
+		}
+	}
+
+	depth := matDepth(mat)
+	if slices.Contains(allDepths, depth) {
+		return mat, nil
+	}
+
+	target := gocv.MatTypeCV8U
+	if depth == gocv.MatTypeCV64F {
+		target = gocv.MatTypeCV32F
+	}
+	converted, err := convertDepth(mat, target)
+	mat.Close()
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	return converted, nil
+}
+
+// writeImage encodes mat in the format of the path's extension with the
+// encoder settings of options and stores metadata, adapted to mat, in TIFF,
+// PNG and JPEG files. Samples deeper than the format holds are converted
+// first.
+func writeImage(path string, mat gocv.Mat, metadata ImageMetadata, options SaveOptions) error {
+	data, err := encodeImage(strings.ToLower(filepath.Ext(path)), mat, metadata, options)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write image to %s: %w", path, err)
+	}
+	return nil
+}
+
+// encodeImage is writeImage without the file: it returns the encoded image
+// for ext, a lower-case extension with its dot
+func encodeImage(ext string, mat gocv.Mat, metadata ImageMetadata, options SaveOptions) ([]byte, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	metadata = metadata.forImage(mat)
+	resolution := metadata.Resolution
+
+	if depth := encodableDepth(ext, matDepth(mat)); depth != matDepth(mat) {
+		converted, err := convertDepth(mat, depth)
+		if err != nil {
+			return nil, err
+		}
+		defer converted.Close()
+		mat = converted
+	}
+
+	// Black-and-white images are written with one bit per pixel where the
+	// format allows, which OpenCV's encoders do not do
+	var data []byte
+	if isBilevel(mat) {
+		data = encodeBilevel(ext, mat, resolution, options)
+	}
+	if data == nil {
+		var err error
+		if data, err = encodeWithOpenCV(ext, mat, resolution, options); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := setMetadata(data, ext, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("store metadata: %w", err)
+	}
+	return data, nil
+}
+
+func encodeWithOpenCV(ext string, mat gocv.Mat, resolution Resolution, options SaveOptions) ([]byte, error) {
+	params := options.openCVParams(ext)
+	if resolution.Known() && (ext == ".tif" || ext == ".tiff") {
+		params = append(params,
+			imwriteTiffResUnit, 2,
+			imwriteTiffXDPI, int(math.Round(resolution.X)),
+			imwriteTiffYDPI, int(math.Round(resolution.Y)))
+	}
+
+	buffer, err := gocv.IMEncodeWithParams(gocv.FileExt(ext), mat, params)
+	if err != nil {
+		return nil, openCVError("encode "+ext, err)
+	}
+	defer buffer.Close()
+	// The encoder's bytes live in native memory freed with the buffer
+	return bytes.Clone(buffer.GetBytes()), nil
+}
+
+// encodableDepth is the depth closest to depth that a file of type ext can
+// hold: TIFF keeps every depth, PNG up to 16 bits and other formats 8 bits
+func encodableDepth(ext string, depth gocv.MatType) gocv.MatType {
+	switch ext {
+	case ".tif", ".tiff":
+		return depth
+	case ".png":
+		if depth == gocv.MatTypeCV8U {
+			return depth
+		}
+		return gocv.MatTypeCV16U
+	}
+	return gocv.MatTypeCV8U
+}
+
+// matDepth is the element type of mat without its channel count
+func matDepth(mat gocv.Mat) gocv.MatType {
+	return mat.Type() & 7
+}
+
+// matType combines a depth with a channel count, which OpenCV keeps above
+// the three depth bits
+func matType(depth gocv.MatType, channels int) gocv.MatType {
+	return depth + gocv.MatType((channels-1)<<3)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"sync/atomic"
 
@@ -17,7 +18,15 @@ func (p *ImagePipeline) HasImageUnsafe() bool {
 	return atomic.LoadInt32(&p.initialized) == 1 && !p.originalImage.Empty()
 }
 
-func (p *ImagePipeline) SetOriginalImage(img gocv.Mat) (err error) {
+// SetOriginalImage replaces the image being processed. metadata is what
+// was read from its file, or the zero ImageMetadata when it had none.
+func (p *ImagePipeline) SetOriginalImage(img gocv.Mat, metadata ImageMetadata) error {
+	return p.SetOriginalImageContext(context.Background(), img, metadata)
+}
+
+// SetOriginalImageContext is SetOriginalImage with a context for the
+// processing of the new image, which a cancelled ctx stops with an error
+func (p *ImagePipeline) SetOriginalImageContext(ctx context.Context, img gocv.Mat, metadata ImageMetadata) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("panic in SetOriginalImage: %v", r)
@@ -64,14 +73,18 @@ func (p *ImagePipeline) SetOriginalImage(img gocv.Mat) (err error) {
 		return fmt.Errorf("failed to clone preview image")
 	}
 
+	p.metadata = metadata
+	p.processedRes = metadata.Resolution
+
 	atomic.StoreInt32(&p.initialized, 1)
 	p.debugPipeline.LogImageStats("original", p.originalImage)
+	p.debugPipeline.Log(fmt.Sprintf("Original metadata: %s", metadata))
 
 	if len(p.transformations) > 0 {
-		if err := p.processImageUnsafe(); err != nil {
+		if err := p.processImageUnsafe(ctx); err != nil {
 			return fmt.Errorf("failed to process image: %w", err)
 		}
-		if err := p.processPreviewUnsafe(); err != nil {
+		if err := p.processPreviewUnsafe(ctx); err != nil {
 			return fmt.Errorf("failed to process preview: %w", err)
 		}
 	}
@@ -91,6 +104,9 @@ func (p *ImagePipeline) Close() {
 			}
 		}
 		p.transformations = nil
+		p.paramSnapshots = make(map[Transformation]map[string]interface{})
+		p.bypassed = make(map[Transformation]bool)
+		p.history.reset()
 		atomic.StoreInt32(&p.initialized, 0)
 	}
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+const applicationName = "Image Restoration Suite"
+
+// ImageMetadata is what an image file records besides its pixels. EXIF is
+// a TIFF structure as stored in a JPEG APP1 segment after "Exif\0\0", ICC
+// the raw colour profile and XMP the serialized packet. The zero value means
+// the file had none.
+type ImageMetadata struct {
+	Resolution Resolution
+	EXIF       []byte
+	ICC        []byte
+	XMP        []byte
+}
+
+func (m ImageMetadata) String() string {
+	parts := []string{m.Resolution.String()}
+	if len(m.EXIF) > 0 {
+		parts = append(parts, fmt.Sprintf("EXIF %d bytes", len(m.EXIF)))
+	}
+	if len(m.ICC) > 0 {
+		parts = append(parts, fmt.Sprintf("ICC %d bytes", len(m.ICC)))
+	}
+	if len(m.XMP) > 0 {
+		parts = append(parts, fmt.Sprintf("XMP %d bytes", len(m.XMP)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// readMetadata reads the metadata of a TIFF, PNG or JPEG file. Other formats
+// have none as far as the suite is concerned. A damaged block is reported
+// along with whatever was read before it.
+func readMetadata(path string) (ImageMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return ImageMetadata{}, nil
+	}
+
+	switch {
+	case bytes.Equal(header, pngSignature):
+		return readPNGMetadata(file)
+	case header[0] == 0xFF && header[1] == 0xD8:
+		return readJPEGMetadata(file)
+	case string(header[:4]) == "II*\x00" || string(header[:4]) == "MM\x00*":
+		return readTIFFMetadata(file)
+	}
+	return ImageMetadata{}, nil
+}
+
+// forImage adapts m to the pixels being saved: the EXIF pixel dimensions and
+// resolution, the copies XMP keeps of them, and the ICC profile, which is
+// dropped once its colour space no longer matches the channels. An EXIF
+// block too damaged to rewrite is dropped as well.
+func (m ImageMetadata) forImage(mat gocv.Mat) ImageMetadata {
+	width, height := mat.Cols(), mat.Rows()
+
+	if len(m.EXIF) > 0 {
+		exif, err := updateEXIF(m.EXIF, width, height, m.Resolution)
+		if err != nil {
+			exif = nil
+		}
+		m.EXIF = exif
+	}
+	if len(m.XMP) > 0 {
+		m.XMP = updateXMPDimensions(m.XMP, width, height, m.Resolution)
+	}
+	if !iccMatches(m.ICC, mat.Channels()) {
+		m.ICC = nil
+	}
+	return m
+}
+
+// iccMatches reports whether profile describes images with channels
+// channels, going by the colour space in its header
+func iccMatches(profile []byte, channels int) bool {
+	if len(profile) < 20 {
+		return false
+	}
+	switch string(profile[16:20]) {
+	case "GRAY":
+		return channels == 1
+	case "RGB ":
+		return channels == 3 || channels == 4
+	}
+	return false
+}
+
+// setMetadata stores metadata in data encoded as ext. Formats without
+// support are returned unchanged.
+func setMetadata(data []byte, ext string, metadata ImageMetadata) ([]byte, error) {
+	switch ext {
+	case ".png":
+		return setPNGMetadata(data, metadata)
+	case ".jpg", ".jpeg":
+		return setJPEGMetadata(data, metadata)
+	case ".tif", ".tiff":
+		return setTIFFMetadata(data, metadata)
+	}
+	return data, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Identifiers at the start of the JPEG application segments that carry
+// metadata
+var (
+	jfifID = []byte("JFIF\x00")
+	exifID = []byte("Exif\x00\x00")
+	xmpID  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	iccID  = []byte("ICC_PROFILE\x00")
+)
+
+// maxJPEGSegment is the most payload one segment holds after its length
+const maxJPEGSegment = 65533
+
+// readJPEGMetadata reads the JFIF, EXIF, XMP and ICC segments before the
+// image data. The resolution is the first one found, JFIF coming first.
+func readJPEGMetadata(r io.ReadSeeker) (ImageMetadata, error) {
+	if _, err := r.Seek(2, io.SeekStart); err != nil {
+		return ImageMetadata{}, err
+	}
+
+	var metadata ImageMetadata
+	var profile [][]byte // ICC chunks by sequence number
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil || marker[0] != 0xFF {
+			return metadata, fmt.Errorf("malformed JPEG segment")
+		}
+		// Start of scan, end of image or a marker without a length
+		if marker[1] == 0xDA || marker[1] == 0xD9 || marker[1] == 0x01 || (marker[1] >= 0xD0 && marker[1] <= 0xD7) {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if length < 0 {
+			return metadata, fmt.Errorf("malformed JPEG segment")
+		}
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return metadata, fmt.Errorf("truncated JPEG segment")
+		}
+
+		switch {
+		case marker[1] == 0xE0 && bytes.HasPrefix(segment, jfifID) && len(segment) >= 12:
+			x := float64(binary.BigEndian.Uint16(segment[8:10]))
+			y := float64(binary.BigEndian.Uint16(segment[10:12]))
+			switch segment[7] {
+			case 1:
+				metadata.Resolution = Resolution{X: x, Y: y}
+			case 2:
+				metadata.Resolution = Resolution{X: x * 2.54, Y: y * 2.54}
+			}
+		case marker[1] == 0xE1 && bytes.HasPrefix(segment, exifID):
+			metadata.EXIF = segment[len(exifID):]
+			if !metadata.Resolution.Known() {
+				if ifd, err := decodeEXIF(metadata.EXIF); err == nil {
+					metadata.Resolution = ifdResolution(ifd)
+				}
+			}
+		case marker[1] == 0xE1 && bytes.HasPrefix(segment, xmpID):
+			metadata.XMP = segment[len(xmpID):]
+		case marker[1] == 0xE2 && bytes.HasPrefix(segment, iccID) && len(segment) > len(iccID)+2:
+			sequence, count := int(segment[len(iccID)]), int(segment[len(iccID)+1])
+			if profile == nil {
+				profile = make([][]byte, count)
+			}
+			if sequence >= 1 && sequence <= len(profile) {
+				profile[sequence-1] = segment[len(iccID)+2:]
+			}
+		}
+	}
+
+	// A profile missing a chunk is useless, so it is left out
+	for _, chunk := range profile {
+		if chunk == nil {
+			return metadata, fmt.Errorf("incomplete ICC profile")
+		}
+		metadata.ICC = append(metadata.ICC, chunk...)
+	}
+	return metadata, nil
+}
+
+// setJPEGMetadata sets the density of the JFIF header that libjpeg writes
+// first, or inserts one, and follows it with EXIF, XMP and ICC segments in
+// place of any the encoder wrote.
+func setJPEGMetadata(data []byte, metadata ImageMetadata) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("not a JPEG stream")
+	}
+
+	var inserted [][]byte
+	if len(metadata.EXIF) > 0 {
+		if len(exifID)+len(metadata.EXIF) > maxJPEGSegment {
+			return nil, fmt.Errorf("EXIF block of %d bytes does not fit a JPEG segment", len(metadata.EXIF))
+		}
+		inserted = append(inserted, jpegSegment(0xE1, exifID, metadata.EXIF))
+	}
+	if len(metadata.XMP) > 0 {
+		if len(xmpID)+len(metadata.XMP) > maxJPEGSegment {
+			return nil, fmt.Errorf("XMP packet of %d bytes does not fit a JPEG segment", len(metadata.XMP))
+		}
+		inserted = append(inserted, jpegSegment(0xE1, xmpID, metadata.XMP))
+	}
+	if len(metadata.ICC) > 0 {
+		chunkSize := maxJPEGSegment - len(iccID) - 2
+		count := (len(metadata.ICC) + chunkSize - 1) / chunkSize
+		if count > 255 {
+			return nil, fmt.Errorf("ICC profile of %d bytes does not fit JPEG segments", len(metadata.ICC))
+		}
+		for i := 0; i < count; i++ {
+			chunk := metadata.ICC[i*chunkSize : min((i+1)*chunkSize, len(metadata.ICC))]
+			header := append(bytes.Clone(iccID), byte(i+1), byte(count))
+			inserted = append(inserted, jpegSegment(0xE2, header, chunk))
+		}
+	}
+
+	// Sort the segments before the image data into the JFIF header, the
+	// metadata segments being replaced and the rest
+	var jfif []byte
+	var kept [][]byte
+	offset := 2
+	for offset+4 <= len(data) && data[offset] == 0xFF && data[offset+1] != 0xDA {
+		end := offset + 2 + int(binary.BigEndian.Uint16(data[offset+2:]))
+		if end > len(data) || end < offset+4 {
+			return nil, fmt.Errorf("truncated JPEG segment")
+		}
+		segment := data[offset:end]
+		switch marker := segment[1]; {
+		case marker == 0xE0 && bytes.HasPrefix(segment[4:], jfifID) && len(segment) >= 18:
+			jfif = bytes.Clone(segment)
+		case marker == 0xE1 || marker == 0xE2:
+		default:
+			kept = append(kept, segment)
+		}
+		offset = end
+	}
+
+	if metadata.Resolution.Known() {
+		if jfif == nil {
+			jfif = []byte{0xFF, 0xE0, 0, 16, 'J', 'F', 'I', 'F', 0, 1, 1, 0, 0, 1, 0, 1, 0, 0}
+		}
+		jfif[11] = 1 // dots per inch
+		binary.BigEndian.PutUint16(jfif[12:14], uint16(math.Min(math.Round(metadata.Resolution.X), math.MaxUint16)))
+		binary.BigEndian.PutUint16(jfif[14:16], uint16(math.Min(math.Round(metadata.Resolution.Y), math.MaxUint16)))
+	}
+
+	output := make([]byte, 0, len(data)+len(metadata.EXIF)+len(metadata.XMP)+len(metadata.ICC)+1024)
+	output = append(output, data[:2]...)
+	output = append(output, jfif...)
+	for _, segment := range append(inserted, kept...) {
+		output = append(output, segment...)
+	}
+	return append(output, data[offset:]...), nil
+}
+
+// jpegSegment builds an application segment of header followed by payload
+func jpegSegment(marker byte, header, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(header)+len(payload)))
+	segment = append(segment, header...)
+	return append(segment, payload...)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+// xmpKeyword names the iTXt chunk that carries an XMP packet
+const xmpKeyword = "XML:com.adobe.xmp"
+
+// readPNGMetadata reads the pHYs, iCCP, eXIf and XMP iTXt chunks
+func readPNGMetadata(r io.ReadSeeker) (ImageMetadata, error) {
+	if _, err := r.Seek(int64(len(pngSignature)), io.SeekStart); err != nil {
+		return ImageMetadata{}, err
+	}
+
+	var metadata ImageMetadata
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return metadata, fmt.Errorf("truncated PNG chunk")
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		chunkType := string(header[4:])
+
+		switch chunkType {
+		case "IEND":
+			return metadata, nil
+		case "pHYs", "iCCP", "eXIf", "iTXt":
+			if length > maxTIFFValue {
+				return metadata, fmt.Errorf("%s chunk of %d bytes", chunkType, length)
+			}
+			data := make([]byte, length+4)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return metadata, fmt.Errorf("truncated %s chunk", chunkType)
+			}
+			if err := readPNGChunk(&metadata, chunkType, data[:length]); err != nil {
+				return metadata, err
+			}
+			continue
+		}
+
+		if _, err := r.Seek(int64(length)+4, io.SeekCurrent); err != nil {
+			return metadata, err
+		}
+	}
+}
+
+func readPNGChunk(metadata *ImageMetadata, chunkType string, data []byte) error {
+	switch chunkType {
+	case "pHYs":
+		// Unit 1 is pixels per metre; 0 only gives the aspect ratio
+		if len(data) == 9 && data[8] == 1 {
+			metadata.Resolution = Resolution{
+				X: float64(binary.BigEndian.Uint32(data[0:4])) * 0.0254,
+				Y: float64(binary.BigEndian.Uint32(data[4:8])) * 0.0254,
+			}
+		}
+	case "eXIf":
+		metadata.EXIF = data
+	case "iCCP":
+		// Profile name, NUL, compression method, zlib stream
+		name := bytes.IndexByte(data, 0)
+		if name < 0 || name+2 > len(data) {
+			return fmt.Errorf("malformed iCCP chunk")
+		}
+		profile, err := inflate(data[name+2:])
+		if err != nil {
+			return fmt.Errorf("iCCP: %w", err)
+		}
+		metadata.ICC = profile
+	case "iTXt":
+		// Keyword, NUL, compression flag and method, language tag, NUL,
+		// translated keyword, NUL, text
+		fields := bytes.SplitN(data, []byte{0}, 2)
+		if len(fields) != 2 || string(fields[0]) != xmpKeyword || len(fields[1]) < 2 {
+			return nil
+		}
+		compressed := fields[1][0] == 1
+		rest := bytes.SplitN(fields[1][2:], []byte{0}, 3)
+		if len(rest) != 3 {
+			return fmt.Errorf("malformed XMP iTXt chunk")
+		}
+		text := rest[2]
+		if compressed {
+			var err error
+			if text, err = inflate(text); err != nil {
+				return fmt.Errorf("XMP iTXt: %w", err)
+			}
+		}
+		metadata.XMP = text
+	}
+	return nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, maxTIFFValue))
+}
+
+// setPNGMetadata replaces the metadata chunks of an encoded PNG with those
+// of metadata, placed right after IHDR. sRGB goes too when there is a
+// profile, as the two must not both be present.
+func setPNGMetadata(data []byte, metadata ImageMetadata) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("not a PNG stream")
+	}
+
+	var chunks bytes.Buffer
+	if len(metadata.ICC) > 0 {
+		var profile bytes.Buffer
+		profile.WriteString("ICC Profile\x00\x00")
+		writer := zlib.NewWriter(&profile)
+		writer.Write(metadata.ICC)
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("compress ICC profile: %w", err)
+		}
+		writePNGChunk(&chunks, "iCCP", profile.Bytes())
+	}
+	if metadata.Resolution.Known() {
+		var physical [9]byte
+		binary.BigEndian.PutUint32(physical[0:4], uint32(math.Round(metadata.Resolution.X/0.0254)))
+		binary.BigEndian.PutUint32(physical[4:8], uint32(math.Round(metadata.Resolution.Y/0.0254)))
+		physical[8] = 1
+		writePNGChunk(&chunks, "pHYs", physical[:])
+	}
+	if len(metadata.EXIF) > 0 {
+		writePNGChunk(&chunks, "eXIf", metadata.EXIF)
+	}
+	if len(metadata.XMP) > 0 {
+		text := append([]byte(xmpKeyword+"\x00\x00\x00\x00\x00"), metadata.XMP...)
+		writePNGChunk(&chunks, "iTXt", text)
+	}
+
+	output := bytes.NewBuffer(make([]byte, 0, len(data)+chunks.Len()))
+	output.Write(pngSignature)
+	for offset := len(pngSignature); offset < len(data); {
+		if offset+12 > len(data) {
+			return nil, fmt.Errorf("truncated PNG chunk")
+		}
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		end := offset + 12 + length
+		if end > len(data) {
+			return nil, fmt.Errorf("truncated PNG chunk")
+		}
+
+		chunk := data[offset:end]
+		switch string(chunk[4:8]) {
+		case "iCCP", "pHYs", "eXIf":
+		case "sRGB":
+			if len(metadata.ICC) == 0 {
+				output.Write(chunk)
+			}
+		case "iTXt":
+			if !bytes.HasPrefix(chunk[8:], []byte(xmpKeyword+"\x00")) {
+				output.Write(chunk)
+			}
+		case "IHDR":
+			output.Write(chunk)
+			output.Write(chunks.Bytes())
+		default:
+			output.Write(chunk)
+		}
+		offset = end
+	}
+	return output.Bytes(), nil
+}
+
+func writePNGChunk(w *bytes.Buffer, chunkType string, data []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	w.Write(length[:])
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(data)
+	w.WriteString(chunkType)
+	w.Write(data)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// testField is a TIFF field for testTIFF, its value already in the byte
+// order of the structure. Fields with sub point to that IFD.
+type testField struct {
+	tag, kind uint16
+	count     uint32
+	value     []byte
+	sub       []testField
+}
+
+func testShort(order binary.ByteOrder, tag, value uint16) testField {
+	field := testField{tag: tag, kind: tiffShort, count: 1, value: make([]byte, 2)}
+	order.PutUint16(field.value, value)
+	return field
+}
+
+func testLong(order binary.ByteOrder, tag uint16, value uint32) testField {
+	field := testField{tag: tag, kind: tiffLong, count: 1, value: make([]byte, 4)}
+	order.PutUint32(field.value, value)
+	return field
+}
+
+func testRational(order binary.ByteOrder, tag uint16, numerator, denominator uint32) testField {
+	field := testField{tag: tag, kind: tiffRational, count: 1, value: make([]byte, 8)}
+	order.PutUint32(field.value, numerator)
+	order.PutUint32(field.value[4:], denominator)
+	return field
+}
+
+func testASCII(tag uint16, value string) testField {
+	return testField{tag: tag, kind: tiffASCII, count: uint32(len(value) + 1), value: append([]byte(value), 0)}
+}
+
+func testBytes(tag, kind uint16, value []byte) testField {
+	return testField{tag: tag, kind: kind, count: uint32(len(value)), value: value}
+}
+
+// testTIFF writes a TIFF structure with data right after the header and
+// one IFD of fields, independently of appendIFD
+func testTIFF(order binary.ByteOrder, data []byte, fields []testField) []byte {
+	out := []byte{'I', 'I', 42, 0, 0, 0, 0, 0}
+	if order == binary.BigEndian {
+		out = []byte{'M', 'M', 0, 42, 0, 0, 0, 0}
+	}
+	out = append(out, data...)
+	out, offset := writeTestIFD(out, order, fields)
+	order.PutUint32(out[4:], offset)
+	return out
+}
+
+func writeTestIFD(out []byte, order binary.ByteOrder, fields []testField) ([]byte, uint32) {
+	// Sub-IFDs and values longer than four bytes go before the IFD
+	inline := make([][]byte, len(fields))
+	for i, field := range fields {
+		value := field.value
+		if field.sub != nil {
+			var offset uint32
+			out, offset = writeTestIFD(out, order, field.sub)
+			value = make([]byte, 4)
+			order.PutUint32(value, offset)
+		}
+		if len(value) > 4 {
+			if len(out)%2 == 1 {
+				out = append(out, 0)
+			}
+			pointer := make([]byte, 4)
+			order.PutUint32(pointer, uint32(len(out)))
+			out = append(out, value...)
+			value = pointer
+		}
+		inline[i] = append(bytes.Clone(value), make([]byte, 4-len(value))...)
+	}
+
+	if len(out)%2 == 1 {
+		out = append(out, 0)
+	}
+	offset := uint32(len(out))
+	entry := make([]byte, 12)
+	order.PutUint16(entry, uint16(len(fields)))
+	out = append(out, entry[:2]...)
+	for i, field := range fields {
+		order.PutUint16(entry[0:], field.tag)
+		order.PutUint16(entry[2:], field.kind)
+		order.PutUint32(entry[4:], field.count)
+		copy(entry[8:], inline[i])
+		out = append(out, entry...)
+	}
+	return append(out, 0, 0, 0, 0), offset
+}
+
+// testEXIF is an EXIF block as a scanner writes it, with fields of the
+// pixel layout that must not move between files
+func testEXIF(order binary.ByteOrder) []byte {
+	return testTIFF(order, nil, []testField{
+		testASCII(271, "Scanner Co"),
+		testShort(order, 274, 6),
+		testLong(order, tagStripOffsets, 1234),
+		testRational(order, tagXResolution, 150, 1),
+		testRational(order, tagYResolution, 150, 1),
+		testShort(order, tagResolutionUnit, 2),
+		testASCII(tagSoftware, "Scanner firmware"),
+		{tag: tagExifIFD, kind: tiffLong, count: 1, sub: []testField{
+			testASCII(36867, "2024:01:02 03:04:05"),
+			testLong(order, tagPixelXDimension, 2000),
+			testLong(order, tagPixelYDimension, 3000),
+		}},
+	})
+}
+
+func entryText(t *testing.T, ifd tiffIFD, tag uint16) string {
+	t.Helper()
+	entry := ifd.find(tag)
+	if entry == nil {
+		t.Fatalf("tag %d missing", tag)
+	}
+	return strings.TrimRight(string(entry.value), "\x00")
+}
+
+func TestDecodeEXIFByteOrders(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			ifd, err := decodeEXIF(testEXIF(order))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := entryText(t, ifd, 271); got != "Scanner Co" {
+				t.Errorf("Make %q", got)
+			}
+			if orientation := ifd.find(274); orientation == nil || orientation.uint() != 6 {
+				t.Errorf("Orientation %v, want 6", orientation)
+			}
+			if resolution := ifdResolution(ifd); resolution != (Resolution{X: 150, Y: 150}) {
+				t.Errorf("resolution %v, want 150 DPI", resolution)
+			}
+			exif := ifd.find(tagExifIFD)
+			if exif == nil || exif.sub == nil {
+				t.Fatal("EXIF IFD missing")
+			}
+			if width := exif.sub.find(tagPixelXDimension); width == nil || width.uint() != 2000 {
+				t.Errorf("PixelXDimension %v, want 2000", width)
+			}
+			if got := entryText(t, exif.sub, 36867); got != "2024:01:02 03:04:05" {
+				t.Errorf("DateTimeOriginal %q", got)
+			}
+		})
+	}
+}
+
+func TestUpdateEXIF(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			data, err := updateEXIF(testEXIF(order), 640, 480, Resolution{X: 600, Y: 300.5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(data, []byte("II")) {
+				t.Errorf("updated EXIF is not little-endian")
+			}
+			ifd, err := decodeEXIF(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := entryText(t, ifd, 271); got != "Scanner Co" {
+				t.Errorf("Make %q, want it kept", got)
+			}
+			if ifd.find(tagStripOffsets) != nil {
+				t.Errorf("StripOffsets kept")
+			}
+			if got := entryText(t, ifd, tagSoftware); got != applicationName {
+				t.Errorf("Software %q, want %q", got, applicationName)
+			}
+			if resolution := ifdResolution(ifd); resolution != (Resolution{X: 600, Y: 300.5}) {
+				t.Errorf("resolution %v, want 600x300.5 DPI", resolution)
+			}
+			exif := ifd.find(tagExifIFD)
+			if exif == nil || exif.sub == nil {
+				t.Fatal("EXIF IFD missing")
+			}
+			if width := exif.sub.find(tagPixelXDimension); width == nil || width.uint() != 640 {
+				t.Errorf("PixelXDimension %v, want 640", width)
+			}
+			if height := exif.sub.find(tagPixelYDimension); height == nil || height.uint() != 480 {
+				t.Errorf("PixelYDimension %v, want 480", height)
+			}
+			if got := entryText(t, exif.sub, 36867); got != "2024:01:02 03:04:05" {
+				t.Errorf("DateTimeOriginal %q, want it kept", got)
+			}
+		})
+	}
+}
+
+// testICC is a made-up gray profile: only the colour space in its header
+// matters to the suite
+func testICC(size int) []byte {
+	profile := make([]byte, size)
+	for i := range profile {
+		profile[i] = byte(i * 7)
+	}
+	copy(profile[16:20], "GRAY")
+	return profile
+}
+
+const testXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+	`<rdf:Description rdf:about="" xmlns:tiff="http://ns.adobe.com/tiff/1.0/" tiff:ImageWidth="2000" tiff:ImageLength="3000"/>` +
+	`</rdf:RDF></x:xmpmeta>`
+
+func TestSetTIFFMetadataOverlappingTags(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			pixels := []byte{1, 2, 3, 4, 5, 6}
+			original := testTIFF(order, pixels, []testField{
+				testLong(order, tagImageWidth, 3),
+				testLong(order, tagImageLength, 2),
+				testShort(order, tagBitsPerSample, 8),
+				testShort(order, tagPhotometric, 1),
+				testASCII(271, "Old make"),
+				testLong(order, tagStripOffsets, 8),
+				testShort(order, tagSamplesPerPixel, 1),
+				testLong(order, tagRowsPerStrip, 2),
+				testLong(order, tagStripByteCounts, 6),
+				testRational(order, tagXResolution, 72, 1),
+				testRational(order, tagYResolution, 72, 1),
+				testShort(order, tagResolutionUnit, 2),
+				testASCII(tagSoftware, "Encoder"),
+				testBytes(tagXMP, tiffByte, []byte("<old/>")),
+				testBytes(tagICC, tiffUndefined, []byte("old profile")),
+			})
+
+			exif, err := updateEXIF(testEXIF(binary.LittleEndian), 3, 2, Resolution{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			metadata := ImageMetadata{EXIF: exif, ICC: testICC(300), XMP: []byte(testXMP)}
+			data, err := setTIFFMetadata(original, metadata)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The image data stays in place
+			if !bytes.Equal(data[8:8+len(pixels)], pixels) {
+				t.Errorf("pixel data moved or changed")
+			}
+
+			r := bytes.NewReader(data)
+			readOrder, offset, err := readTIFFHeader(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if readOrder != order {
+				t.Errorf("byte order changed to %v", readOrder)
+			}
+			ifd, next, err := readIFD(r, readOrder, offset, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next != 0 {
+				t.Errorf("next IFD %d, want 0", next)
+			}
+
+			seen := map[uint16]bool{}
+			for i, entry := range ifd {
+				if seen[entry.tag] {
+					t.Errorf("tag %d appears twice", entry.tag)
+				}
+				seen[entry.tag] = true
+				if i > 0 && ifd[i-1].tag > entry.tag {
+					t.Errorf("tag %d follows tag %d", entry.tag, ifd[i-1].tag)
+				}
+			}
+
+			if got := entryText(t, ifd, 271); got != "Scanner Co" {
+				t.Errorf("Make %q, want the EXIF value", got)
+			}
+			if got := entryText(t, ifd, tagSoftware); got != applicationName {
+				t.Errorf("Software %q, want %q", got, applicationName)
+			}
+			if ifd.find(tagExifIFD) == nil {
+				t.Errorf("EXIF IFD missing")
+			}
+			if width := ifd.find(tagImageWidth); width == nil || width.uint() != 3 {
+				t.Errorf("ImageWidth %v, want the encoder's 3", width)
+			}
+			if strips := ifd.find(tagStripOffsets); strips == nil || strips.uint() != 8 {
+				t.Errorf("StripOffsets %v, want the encoder's 8", strips)
+			}
+			if resolution := ifdResolution(ifd); resolution != (Resolution{X: 72, Y: 72}) {
+				t.Errorf("resolution %v, want the encoder's 72 DPI", resolution)
+			}
+
+			read, err := readTIFFMetadata(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(read.ICC, metadata.ICC) {
+				t.Errorf("ICC profile not replaced")
+			}
+			if string(read.XMP) != testXMP {
+				t.Errorf("XMP %q, want %q", read.XMP, testXMP)
+			}
+		})
+	}
+}
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewGray(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// pngChunkCount counts the chunks of type chunkType
+func pngChunkCount(data []byte, chunkType string) int {
+	count := 0
+	for offset := len(pngSignature); offset+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		if string(data[offset+4:offset+8]) == chunkType {
+			count++
+		}
+		offset += 12 + length
+	}
+	return count
+}
+
+func TestSetPNGMetadataRoundTrip(t *testing.T) {
+	metadata := ImageMetadata{
+		Resolution: Resolution{X: 300, Y: 150},
+		EXIF:       testEXIF(binary.LittleEndian),
+		ICC:        testICC(500),
+		XMP:        []byte(testXMP),
+	}
+
+	// Metadata set twice replaces the first
+	data, err := setPNGMetadata(testPNG(t), ImageMetadata{XMP: []byte("<old/>"), ICC: testICC(40)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = setPNGMetadata(data, metadata); err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range []string{"iCCP", "pHYs", "eXIf", "iTXt"} {
+		if count := pngChunkCount(data, chunk); count != 1 {
+			t.Errorf("%d %s chunks, want 1", count, chunk)
+		}
+	}
+
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("PNG no longer decodes: %v", err)
+	}
+	read, err := readPNGMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pHYs holds whole pixels per metre, 0.0254 DPI apart
+	checkMetadata(t, read, metadata, 0.0254)
+}
+
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestSetJPEGMetadataRoundTrip(t *testing.T) {
+	metadata := ImageMetadata{
+		Resolution: Resolution{X: 300, Y: 150},
+		EXIF:       testEXIF(binary.BigEndian),
+		// Large enough to be split over several segments
+		ICC: testICC(2*maxJPEGSegment + 100),
+		XMP: []byte(testXMP),
+	}
+
+	data, err := setJPEGMetadata(testJPEG(t), ImageMetadata{XMP: []byte("<old/>"), ICC: testICC(40)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = setJPEGMetadata(data, metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("JPEG no longer decodes: %v", err)
+	}
+	read, err := readJPEGMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMetadata(t, read, metadata, 0)
+}
+
+func checkMetadata(t *testing.T, got, want ImageMetadata, tolerance float64) {
+	t.Helper()
+	if math.Abs(got.Resolution.X-want.Resolution.X) > tolerance || math.Abs(got.Resolution.Y-want.Resolution.Y) > tolerance {
+		t.Errorf("resolution %v, want %v", got.Resolution, want.Resolution)
+	}
+	if !bytes.Equal(got.EXIF, want.EXIF) {
+		t.Errorf("EXIF of %d bytes, want the %d written", len(got.EXIF), len(want.EXIF))
+	}
+	if !bytes.Equal(got.ICC, want.ICC) {
+		t.Errorf("ICC profile of %d bytes, want the %d written", len(got.ICC), len(want.ICC))
+	}
+	if !bytes.Equal(got.XMP, want.XMP) {
+		t.Errorf("XMP %q, want %q", got.XMP, want.XMP)
+	}
+}
+
+// Saving keeps EXIF, ICC and XMP, brought up to date with the saved pixels
+func TestEncodeImageKeepsMetadata(t *testing.T) {
+	gray := make([]byte, 40*30)
+	for i := range gray {
+		gray[i] = byte(i)
+	}
+	mat, err := gocv.NewMatFromBytes(30, 40, gocv.MatTypeCV8UC1, gray)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mat.Close()
+
+	metadata := ImageMetadata{
+		Resolution: Resolution{X: 300, Y: 300},
+		EXIF:       testEXIF(binary.BigEndian),
+		ICC:        testICC(500),
+		XMP:        []byte(testXMP),
+	}
+	readers := map[string]func(*bytes.Reader) (ImageMetadata, error){
+		".png": func(r *bytes.Reader) (ImageMetadata, error) { return readPNGMetadata(r) },
+		".jpg": func(r *bytes.Reader) (ImageMetadata, error) { return readJPEGMetadata(r) },
+		".tif": func(r *bytes.Reader) (ImageMetadata, error) { return readTIFFMetadata(r) },
+	}
+
+	for ext, read := range readers {
+		t.Run(ext, func(t *testing.T) {
+			data, err := encodeImage(ext, mat, metadata, DefaultSaveOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := gocv.IMDecode(data, gocv.IMReadUnchanged)
+			if err != nil || decoded.Empty() {
+				t.Fatalf("saved image does not decode: %v", err)
+			}
+			decoded.Close()
+
+			got, err := read(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got.Resolution.X-300) > 0.01 || math.Abs(got.Resolution.Y-300) > 0.01 {
+				t.Errorf("resolution %v, want 300 DPI", got.Resolution)
+			}
+			if !bytes.Equal(got.ICC, metadata.ICC) {
+				t.Errorf("ICC profile not kept")
+			}
+			if !strings.Contains(string(got.XMP), `tiff:ImageWidth="40"`) || !strings.Contains(string(got.XMP), `tiff:ImageLength="30"`) {
+				t.Errorf("XMP %q does not have the saved dimensions", got.XMP)
+			}
+
+			ifd, err := decodeEXIF(got.EXIF)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := entryText(t, ifd, 271); got != "Scanner Co" {
+				t.Errorf("Make %q, want it kept", got)
+			}
+			exif := ifd.find(tagExifIFD)
+			if exif == nil || exif.sub == nil {
+				t.Fatal("EXIF IFD missing")
+			}
+			if width := exif.sub.find(tagPixelXDimension); width == nil || width.uint() != 40 {
+				t.Errorf("PixelXDimension %v, want 40", width)
+			}
+		})
+	}
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// TIFF field types
+const (
+	tiffByte      = 1
+	tiffASCII     = 2
+	tiffShort     = 3
+	tiffLong      = 4
+	tiffRational  = 5
+	tiffSByte     = 6
+	tiffUndefined = 7
+	tiffSShort    = 8
+	tiffSLong     = 9
+	tiffSRational = 10
+	tiffFloat     = 11
+	tiffDouble    = 12
+	tiffIFDType   = 13
+)
+
+// TIFF and EXIF tags the metadata code reads or writes
+const (
+	tagXResolution     = 282
+	tagYResolution     = 283
+	tagResolutionUnit  = 296
+	tagSoftware        = 305
+	tagXMP             = 700
+	tagExifIFD         = 34665
+	tagICC             = 34675
+	tagGPSIFD          = 34853
+	tagPixelXDimension = 40962
+	tagPixelYDimension = 40963
+	tagInteropIFD      = 40965
+)
+
+// tiffPointerTags point to sub-IFDs, which are read and written with the
+// IFD that holds them
+var tiffPointerTags = map[uint16]bool{
+	tagExifIFD:    true,
+	tagGPSIFD:     true,
+	tagInteropIFD: true,
+}
+
+// exifIFD0Tags are the fields of the first IFD that describe the capture
+// rather than the pixel layout. Only these move between files; strip
+// offsets, sample formats and the like belong to the encoder.
+var exifIFD0Tags = map[uint16]bool{
+	269:         true, // DocumentName
+	270:         true, // ImageDescription
+	271:         true, // Make
+	272:         true, // Model
+	274:         true, // Orientation
+	285:         true, // PageName
+	tagSoftware: true,
+	306:         true, // DateTime
+	315:         true, // Artist
+	316:         true, // HostComputer
+	33432:       true, // Copyright
+	tagExifIFD:  true,
+	tagGPSIFD:   true,
+}
+
+// maxTIFFValue bounds a single field so a corrupt count cannot exhaust memory
+const maxTIFFValue = 64 << 20
+
+// tiffEntry is one field of an IFD with its values in little-endian order.
+// Pointer fields carry the IFD they point to in sub instead of a value.
+type tiffEntry struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	value []byte
+	sub   tiffIFD
+}
+
+type tiffIFD []tiffEntry
+
+func (ifd tiffIFD) find(tag uint16) *tiffEntry {
+	for i := range ifd {
+		if ifd[i].tag == tag {
+			return &ifd[i]
+		}
+	}
+	return nil
+}
+
+// set replaces the field with the tag of entry, or adds it
+func (ifd tiffIFD) set(entry tiffEntry) tiffIFD {
+	if existing := ifd.find(entry.tag); existing != nil {
+		*existing = entry
+		return ifd
+	}
+	return append(ifd, entry)
+}
+
+func (ifd tiffIFD) filter(keep func(tag uint16) bool) tiffIFD {
+	var result tiffIFD
+	for _, entry := range ifd {
+		if keep(entry.tag) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// uint is the first value of a SHORT or LONG field
+func (e *tiffEntry) uint() uint32 {
+	return e.uintAt(0)
+}
+
+func (e *tiffEntry) uintAt(i int) uint32 {
+	switch {
+	case e.kind == tiffShort && len(e.value) >= 2*(i+1):
+		return uint32(binary.LittleEndian.Uint16(e.value[2*i:]))
+	case e.kind == tiffLong && len(e.value) >= 4*(i+1):
+		return binary.LittleEndian.Uint32(e.value[4*i:])
+	}
+	return 0
+}
+
+// rational is the first value of a RATIONAL field
+func (e *tiffEntry) rational() float64 {
+	if e.kind != tiffRational || len(e.value) < 8 {
+		return 0
+	}
+	denominator := binary.LittleEndian.Uint32(e.value[4:])
+	if denominator == 0 {
+		return 0
+	}
+	return float64(binary.LittleEndian.Uint32(e.value)) / float64(denominator)
+}
+
+func shortEntry(tag uint16, value uint16) tiffEntry {
+	return tiffEntry{tag: tag, kind: tiffShort, count: 1, value: binary.LittleEndian.AppendUint16(nil, value)}
+}
+
+func longEntry(tag uint16, value uint32) tiffEntry {
+	return tiffEntry{tag: tag, kind: tiffLong, count: 1, value: binary.LittleEndian.AppendUint32(nil, value)}
+}
+
+// rationalEntry stores value to two decimals, or exactly when it is whole
+func rationalEntry(tag uint16, value float64) tiffEntry {
+	numerator, denominator := math.Round(value*100), uint32(100)
+	if value == math.Trunc(value) {
+		numerator, denominator = value, 1
+	}
+	data := binary.LittleEndian.AppendUint32(nil, uint32(math.Min(numerator, math.MaxUint32)))
+	return tiffEntry{tag: tag, kind: tiffRational, count: 1, value: binary.LittleEndian.AppendUint32(data, denominator)}
+}
+
+func asciiEntry(tag uint16, value string) tiffEntry {
+	return tiffEntry{tag: tag, kind: tiffASCII, count: uint32(len(value) + 1), value: append([]byte(value), 0)}
+}
+
+// tiffTypeSize is the size of one value of kind and the width that byte
+// swapping works on, or 0 for kinds this code does not know
+func tiffTypeSize(kind uint16) (size, width int) {
+	switch kind {
+	case tiffByte, tiffASCII, tiffSByte, tiffUndefined:
+		return 1, 1
+	case tiffShort, tiffSShort:
+		return 2, 2
+	case tiffLong, tiffSLong, tiffFloat, tiffIFDType:
+		return 4, 4
+	case tiffRational, tiffSRational:
+		return 8, 4
+	case tiffDouble:
+		return 8, 8
+	}
+	return 0, 0
+}
+
+// swapTIFFValue reverses the byte order of every width byte word of value
+func swapTIFFValue(value []byte, width int) {
+	for i := 0; i+width <= len(value); i += width {
+		slices.Reverse(value[i : i+width])
+	}
+}
+
+// readTIFFHeader returns the byte order and first IFD offset of a classic
+// TIFF structure
+func readTIFFHeader(r io.ReadSeeker) (binary.ByteOrder, uint32, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, fmt.Errorf("truncated TIFF header")
+	}
+
+	var order binary.ByteOrder
+	switch string(header[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, fmt.Errorf("not a TIFF structure")
+	}
+	if version := order.Uint16(header[2:4]); version != 42 {
+		return nil, 0, fmt.Errorf("unsupported TIFF version %d", version)
+	}
+	return order, order.Uint32(header[4:]), nil
+}
+
+// readIFD reads the IFD at offset and the sub-IFDs it points to, returning
+// it with the offset of the next IFD. Fields of unknown type or with values
+// outside the structure are skipped, as EXIF readers commonly do.
+func readIFD(r io.ReadSeeker, order binary.ByteOrder, offset uint32, depth int) (tiffIFD, uint32, error) {
+	if _, err := r.Seek(int64(offset), io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	var count [2]byte
+	if _, err := io.ReadFull(r, count[:]); err != nil {
+		return nil, 0, fmt.Errorf("truncated IFD")
+	}
+	fields := make([]byte, 12*int(order.Uint16(count[:]))+4)
+	if _, err := io.ReadFull(r, fields); err != nil {
+		return nil, 0, fmt.Errorf("truncated IFD")
+	}
+	next := order.Uint32(fields[len(fields)-4:])
+
+	var ifd tiffIFD
+	for i := 0; i+12 <= len(fields)-4; i += 12 {
+		field := fields[i : i+12]
+		entry := tiffEntry{
+			tag:   order.Uint16(field[0:2]),
+			kind:  order.Uint16(field[2:4]),
+			count: order.Uint32(field[4:8]),
+		}
+		size, width := tiffTypeSize(entry.kind)
+		length := uint64(size) * uint64(entry.count)
+		if size == 0 || length > maxTIFFValue {
+			continue
+		}
+
+		if length <= 4 {
+			entry.value = bytes.Clone(field[8 : 8+length])
+		} else {
+			entry.value = make([]byte, length)
+			if _, err := r.Seek(int64(order.Uint32(field[8:12])), io.SeekStart); err != nil {
+				continue
+			}
+			if _, err := io.ReadFull(r, entry.value); err != nil {
+				continue
+			}
+		}
+		if order == binary.BigEndian {
+			swapTIFFValue(entry.value, width)
+		}
+
+		if tiffPointerTags[entry.tag] {
+			if depth >= 3 || len(entry.value) < 4 {
+				continue
+			}
+			sub, _, err := readIFD(r, order, binary.LittleEndian.Uint32(entry.value), depth+1)
+			if err != nil {
+				continue
+			}
+			entry.value, entry.sub = nil, sub
+		}
+		ifd = append(ifd, entry)
+	}
+	return ifd, next, nil
+}
+
+// appendIFD writes ifd and its sub-IFDs at the end of data, which holds the
+// part of a TIFF structure from offset base on, and returns the IFD's
+// offset. Values that do not fit a field follow it.
+func appendIFD(data []byte, base uint32, order binary.ByteOrder, ifd tiffIFD, next uint32) ([]byte, uint32) {
+	entries := slices.Clone(ifd)
+	slices.SortFunc(entries, func(a, b tiffEntry) int { return int(a.tag) - int(b.tag) })
+
+	if len(data)%2 == 1 {
+		data = append(data, 0)
+	}
+	start := len(data)
+	position := func(index int) uint32 { return base + uint32(index) }
+	data = append(data, make([]byte, 2+12*len(entries)+4)...)
+	order.PutUint16(data[start:], uint16(len(entries)))
+
+	for i, entry := range entries {
+		kind, count, value := entry.kind, entry.count, entry.value
+		if entry.sub != nil {
+			var offset uint32
+			data, offset = appendIFD(data, base, order, entry.sub, 0)
+			kind, count, value = tiffLong, 1, binary.LittleEndian.AppendUint32(nil, offset)
+		}
+		if order == binary.BigEndian {
+			value = bytes.Clone(value)
+			_, width := tiffTypeSize(kind)
+			swapTIFFValue(value, width)
+		}
+
+		field := data[start+2+12*i : start+2+12*(i+1)]
+		order.PutUint16(field[0:2], entry.tag)
+		order.PutUint16(field[2:4], kind)
+		order.PutUint32(field[4:8], count)
+		if len(value) <= 4 {
+			copy(field[8:12], value)
+			continue
+		}
+
+		if len(data)%2 == 1 {
+			data = append(data, 0)
+		}
+		order.PutUint32(data[start+2+12*i+8:], position(len(data)))
+		data = append(data, value...)
+	}
+
+	order.PutUint32(data[start+2+12*len(entries):], next)
+	return data, position(start)
+}
+
+// decodeEXIF reads the first IFD of an EXIF block with its sub-IFDs. The
+// thumbnail IFD is left out, as it no longer shows the processed image.
+func decodeEXIF(data []byte) (tiffIFD, error) {
+	r := bytes.NewReader(data)
+	order, offset, err := readTIFFHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	ifd, _, err := readIFD(r, order, offset, 0)
+	return ifd, err
+}
+
+// encodeEXIF writes ifd as a little-endian EXIF block
+func encodeEXIF(ifd tiffIFD) []byte {
+	data := []byte{'I', 'I', 42, 0, 0, 0, 0, 0}
+	data, offset := appendIFD(data, 0, binary.LittleEndian, ifd, 0)
+	binary.LittleEndian.PutUint32(data[4:], offset)
+	return data
+}
+
+// updateEXIF rewrites an EXIF block for an image of width x height at
+// resolution, recording this suite as the software that wrote it
+func updateEXIF(data []byte, width, height int, resolution Resolution) ([]byte, error) {
+	ifd, err := decodeEXIF(data)
+	if err != nil {
+		return nil, err
+	}
+	ifd = ifd.filter(func(tag uint16) bool {
+		return exifIFD0Tags[tag] || tag == tagXResolution || tag == tagYResolution || tag == tagResolutionUnit
+	})
+
+	if resolution.Known() {
+		ifd = ifd.withResolution(resolution)
+	}
+	ifd = ifd.set(asciiEntry(tagSoftware, applicationName))
+	if exif := ifd.find(tagExifIFD); exif != nil {
+		exif.sub = exif.sub.
+			set(longEntry(tagPixelXDimension, uint32(width))).
+			set(longEntry(tagPixelYDimension, uint32(height)))
+	}
+	return encodeEXIF(ifd), nil
+}
+
+// maxTIFFPages bounds the IFD chain, which a damaged file can make circular
+const maxTIFFPages = 10000
+
+// tiffPageOffsets lists the IFD offset of every page
+func tiffPageOffsets(r io.ReadSeeker) ([]uint32, error) {
+	order, offset, err := readTIFFHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var offsets []uint32
+	seen := make(map[uint32]bool)
+	for offset != 0 && !seen[offset] && len(offsets) < maxTIFFPages {
+		seen[offset] = true
+		if _, err := r.Seek(int64(offset), io.SeekStart); err != nil {
+			return offsets, err
+		}
+		var count [2]byte
+		if _, err := io.ReadFull(r, count[:]); err != nil {
+			return offsets, fmt.Errorf("truncated IFD")
+		}
+		if _, err := r.Seek(12*int64(order.Uint16(count[:])), io.SeekCurrent); err != nil {
+			return offsets, err
+		}
+		var next [4]byte
+		if _, err := io.ReadFull(r, next[:]); err != nil {
+			return offsets, fmt.Errorf("truncated IFD")
+		}
+		offsets = append(offsets, offset)
+		offset = order.Uint32(next[:])
+	}
+	return offsets, nil
+}
+
+// readTIFFMetadata reads the resolution, ICC profile and XMP packet of the
+// first page, and turns its descriptive fields into an EXIF block
+func readTIFFMetadata(r io.ReadSeeker) (ImageMetadata, error) {
+	return readTIFFPageMetadata(r, 0)
+}
+
+// readTIFFPageMetadata is readTIFFMetadata for any page
+func readTIFFPageMetadata(r io.ReadSeeker, page int) (ImageMetadata, error) {
+	offsets, err := tiffPageOffsets(r)
+	if page >= len(offsets) {
+		if err == nil {
+			err = fmt.Errorf("TIFF has no page %d", page+1)
+		}
+		return ImageMetadata{}, err
+	}
+	order, _, err := readTIFFHeader(r)
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+	ifd, _, err := readIFD(r, order, offsets[page], 0)
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+
+	metadata := ImageMetadata{Resolution: ifdResolution(ifd)}
+	if entry := ifd.find(tagICC); entry != nil {
+		metadata.ICC = entry.value
+	}
+	if entry := ifd.find(tagXMP); entry != nil {
+		metadata.XMP = entry.value
+	}
+	if exif := ifd.filter(func(tag uint16) bool { return exifIFD0Tags[tag] }); len(exif) > 0 {
+		metadata.EXIF = encodeEXIF(exif)
+	}
+	return metadata, nil
+}
+
+// setTIFFMetadata adds the EXIF fields, ICC profile and XMP packet of
+// metadata to the first page of an encoded TIFF. The page's IFD is written
+// again at the end of the file, so the image data stays where it is.
+// Resolution is left to the encoder.
+func setTIFFMetadata(data []byte, metadata ImageMetadata) ([]byte, error) {
+	if len(metadata.EXIF) == 0 && len(metadata.ICC) == 0 && len(metadata.XMP) == 0 {
+		return data, nil
+	}
+
+	r := bytes.NewReader(data)
+	order, offset, err := readTIFFHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	ifd, next, err := readIFD(r, order, offset, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metadata.EXIF) > 0 {
+		exif, err := decodeEXIF(metadata.EXIF)
+		if err != nil {
+			return nil, fmt.Errorf("EXIF: %w", err)
+		}
+		for _, entry := range exif {
+			if exifIFD0Tags[entry.tag] {
+				ifd = ifd.set(entry)
+			}
+		}
+	}
+	if len(metadata.ICC) > 0 {
+		ifd = ifd.set(tiffEntry{tag: tagICC, kind: tiffUndefined, count: uint32(len(metadata.ICC)), value: metadata.ICC})
+	}
+	if len(metadata.XMP) > 0 {
+		ifd = ifd.set(tiffEntry{tag: tagXMP, kind: tiffByte, count: uint32(len(metadata.XMP)), value: metadata.XMP})
+	}
+
+	output, offset := appendIFD(bytes.Clone(data), 0, order, ifd, next)
+	order.PutUint32(output[4:8], offset)
+	return output, nil
+}
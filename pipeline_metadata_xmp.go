@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// xmpRecipeNamespace holds the processing recipe the suite embeds in XMP
+const xmpRecipeNamespace = "https://github.com/resoltico/image-restoration-suite/ns/recipe/1.0/"
+
+const (
+	xmpPacketStart = "<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" +
+		"<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n" +
+		"<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n"
+	xmpPacketEnd = "</rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>"
+)
+
+var xmlText = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// withRecipe adds recipe, YAML text, to an XMP packet in a description of
+// its own, replacing the one an earlier save left. Without a packet a new
+// one is made.
+func withRecipe(xmp []byte, recipe string) []byte {
+	description := fmt.Sprintf("<rdf:Description rdf:about=\"\" xmlns:irs=\"%s\">\n<irs:Software>%s</irs:Software>\n<irs:Recipe>%s</irs:Recipe>\n</rdf:Description>\n",
+		xmpRecipeNamespace, applicationName, xmlText.Replace(recipe))
+
+	packet := string(xmp)
+	if i := strings.Index(packet, "xmlns:irs=\""+xmpRecipeNamespace); i >= 0 {
+		start := strings.LastIndex(packet[:i], "<rdf:Description")
+		end := strings.Index(packet[i:], "</rdf:Description>")
+		if start >= 0 && end >= 0 {
+			end = i + end + len("</rdf:Description>")
+			packet = packet[:start] + strings.TrimLeft(packet[end:], "\n")
+		}
+	}
+
+	if i := strings.LastIndex(packet, "</rdf:RDF>"); i >= 0 {
+		return []byte(packet[:i] + description + packet[i:])
+	}
+	return []byte(xmpPacketStart + description + xmpPacketEnd)
+}
+
+// updateXMPDimensions rewrites the TIFF and EXIF properties that repeat the
+// pixel size and resolution, in attribute or element form. Properties the
+// packet does not have are not added.
+func updateXMPDimensions(xmp []byte, width, height int, resolution Resolution) []byte {
+	values := map[string]string{
+		"tiff:ImageWidth":      strconv.Itoa(width),
+		"tiff:ImageLength":     strconv.Itoa(height),
+		"exif:PixelXDimension": strconv.Itoa(width),
+		"exif:PixelYDimension": strconv.Itoa(height),
+	}
+	if resolution.Known() {
+		values["tiff:XResolution"] = xmpRational(resolution.X)
+		values["tiff:YResolution"] = xmpRational(resolution.Y)
+		values["tiff:ResolutionUnit"] = "2"
+	}
+
+	for name, value := range values {
+		pattern := regexp.MustCompile(`([<\s]` + regexp.QuoteMeta(name) + `(?:\s*=\s*"|>))[^"<]*`)
+		xmp = pattern.ReplaceAll(xmp, []byte("${1}"+value))
+	}
+	return xmp
+}
+
+func xmpRational(value float64) string {
+	if value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f/1", value)
+	}
+	return fmt.Sprintf("%.0f/100", math.Round(value*100))
+}
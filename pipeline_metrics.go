@@ -24,8 +24,8 @@ func (p *ImagePipeline) CalculatePSNR() float64 {
 		return 0.0
 	}
 
-	if orig.Type() != proc.Type() {
-		if proc.Type() == gocv.MatTypeCV8U && orig.Channels() == 3 {
+	if orig.Channels() != proc.Channels() {
+		if proc.Channels() == 1 && orig.Channels() == 3 {
 			temp := gocv.NewMat()
 			defer temp.Close()
 			err := gocv.CvtColor(proc, &temp, gocv.ColorGrayToBGR)
@@ -34,15 +34,15 @@ func (p *ImagePipeline) CalculatePSNR() float64 {
 			}
 			proc.Close()
 			proc = temp.Clone()
-		} else if orig.Type() == gocv.MatTypeCV8U && proc.Channels() == 3 {
+		} else if orig.Channels() == 1 && proc.Channels() == 3 {
 			temp := gocv.NewMat()
 			defer temp.Close()
-			err := gocv.CvtColor(orig, &temp, gocv.ColorBGRToGray)
+			err := gocv.CvtColor(proc, &temp, gocv.ColorBGRToGray)
 			if err != nil {
 				return 0.0
 			}
-			orig.Close()
-			orig = temp.Clone()
+			proc.Close()
+			proc = temp.Clone()
 		}
 	}
 
@@ -51,8 +51,10 @@ func (p *ImagePipeline) CalculatePSNR() float64 {
 	procFloat := gocv.NewMat()
 	defer procFloat.Close()
 
-	orig.ConvertTo(&origFloat, gocv.MatTypeCV64F)
-	proc.ConvertTo(&procFloat, gocv.MatTypeCV64F)
+	// Samples are compared in 0..1, so a 16-bit original and its 8-bit
+	// binarization measure against the same peak
+	orig.ConvertToWithParams(&origFloat, gocv.MatTypeCV64F, float32(1/depthMax(matDepth(orig))), 0)
+	proc.ConvertToWithParams(&procFloat, gocv.MatTypeCV64F, float32(1/depthMax(matDepth(proc))), 0)
 
 	diff := gocv.NewMat()
 	defer diff.Close()
@@ -83,7 +85,7 @@ func (p *ImagePipeline) CalculatePSNR() float64 {
 		return 0.0
 	}
 
-	maxI := 255.0
+	maxI := 1.0
 	psnr := 20*math.Log10(maxI) - 10*math.Log10(mse)
 
 	if math.IsInf(psnr, 0) || math.IsNaN(psnr) {
@@ -143,11 +145,8 @@ func (p *ImagePipeline) CalculateSSIM() float64 {
 	procF := gocv.NewMat()
 	defer procF.Close()
 
-	orig.ConvertTo(&origF, gocv.MatTypeCV64F)
-	proc.ConvertTo(&procF, gocv.MatTypeCV64F)
-
-	origF.DivideFloat(255.0)
-	procF.DivideFloat(255.0)
+	orig.ConvertToWithParams(&origF, gocv.MatTypeCV64F, float32(1/depthMax(matDepth(orig))), 0)
+	proc.ConvertToWithParams(&procF, gocv.MatTypeCV64F, float32(1/depthMax(matDepth(proc))), 0)
 
 	c1 := 0.01 * 0.01
 	c2 := 0.03 * 0.03
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// TextWord is a recognized word with its box in pixels of the page image
+type TextWord struct {
+	Text   string
+	Bounds image.Rectangle
+}
+
+// TextRecognizer finds the words of a processed page for the invisible text
+// layer of PDF export
+type TextRecognizer interface {
+	Recognize(ctx context.Context, page gocv.Mat, resolution Resolution) ([]TextWord, error)
+}
+
+// commandRecognizer runs an OCR program on each page. The page is passed as
+// a PNG file appended to the command, and the program prints its words as
+// Tesseract TSV: level, page, block, paragraph, line and word numbers, then
+// left, top, width, height, confidence and text, separated by tabs.
+type commandRecognizer struct {
+	command []string
+}
+
+func newCommandRecognizer(command string) (*commandRecognizer, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty OCR command")
+	}
+	return &commandRecognizer{command: fields}, nil
+}
+
+func (r *commandRecognizer) Recognize(ctx context.Context, page gocv.Mat, resolution Resolution) ([]TextWord, error) {
+	// The resolution helps the program pick its text size
+	data, err := encodeImage(".png", page, ImageMetadata{Resolution: resolution}, DefaultSaveOptions())
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp("", "ocr-page-*.png")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.command[0], append(r.command[1:], file.Name())...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s: %w: %s", r.command[0], err, message)
+		}
+		return nil, fmt.Errorf("%s: %w", r.command[0], err)
+	}
+	return parseTSVWords(stdout.String()), nil
+}
+
+// parseTSVWords reads the words of Tesseract TSV output. The header, rows of
+// pages, blocks and lines and rows without text are skipped.
+func parseTSVWords(tsv string) []TextWord {
+	var words []TextWord
+	for _, line := range strings.Split(tsv, "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(fields) < 12 || fields[0] != "5" {
+			continue
+		}
+		text := strings.TrimSpace(strings.Join(fields[11:], "\t"))
+		if text == "" {
+			continue
+		}
+
+		var box [4]int
+		valid := true
+		for i := range box {
+			value, err := strconv.Atoi(fields[6+i])
+			if err != nil {
+				valid = false
+				break
+			}
+			box[i] = value
+		}
+		if !valid || box[2] <= 0 || box[3] <= 0 {
+			continue
+		}
+		words = append(words, TextWord{
+			Text:   text,
+			Bounds: image.Rect(box[0], box[1], box[0]+box[2], box[1]+box[3]),
+		})
+	}
+	return words
+}
@@ -0,0 +1,1120 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// The PDF reader takes the scanned image from each page of a PDF. It reads
+// objects, the page tree and image XObjects; page content is not
+// interpreted, so the largest image of a page is taken to cover the page.
+
+type pdfName string
+
+type pdfRef struct {
+	number, generation int
+}
+
+type pdfDict map[pdfName]any
+
+// pdfStream is a stream object whose data starts at offset start
+type pdfStream struct {
+	dict  pdfDict
+	start int
+}
+
+const (
+	maxPDFDepth          = 64 // nesting of objects, references and the page tree
+	maxPDFPages          = 10000
+	maxPDFImageDimension = 32768
+)
+
+// pdfLexer reads PDF objects from data
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) peek(offset int) byte {
+	if l.pos+offset < len(l.data) {
+		return l.data[l.pos+offset]
+	}
+	return 0
+}
+
+// skipSpace skips white space and comments
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch c := l.data[l.pos]; {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token reads a run of regular characters: a number or keyword
+func (l *pdfLexer) token() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+func isPDFInteger(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, c := range []byte(token) {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// object reads a direct object. Numbers are float64, strings []byte and
+// arrays []any.
+func (l *pdfLexer) object(depth int) (any, error) {
+	if depth > maxPDFDepth {
+		return nil, fmt.Errorf("objects nested too deep")
+	}
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		l.pos++
+		return pdfName(unescapePDFName(l.token())), nil
+	case c == '<' && l.peek(1) == '<':
+		l.pos += 2
+		dict := pdfDict{}
+		for {
+			l.skipSpace()
+			if l.peek(0) == '>' && l.peek(1) == '>' {
+				l.pos += 2
+				return dict, nil
+			}
+			key, err := l.object(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				return nil, fmt.Errorf("dictionary key %v is not a name", key)
+			}
+			value, err := l.object(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			dict[name] = value
+		}
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		value := decodePDFHex(l.data[l.pos+1 : l.pos+end])
+		l.pos += end + 1
+		return value, nil
+	case c == '(':
+		return l.literalString()
+	case c == '[':
+		l.pos++
+		array := []any{}
+		for {
+			l.skipSpace()
+			if l.peek(0) == ']' {
+				l.pos++
+				return array, nil
+			}
+			value, err := l.object(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+	}
+
+	token := l.token()
+	switch token {
+	case "":
+		return nil, fmt.Errorf("unexpected %q", l.data[l.pos])
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	number, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+
+	// An integer may start a reference: number generation R
+	if isPDFInteger(token) {
+		save := l.pos
+		l.skipSpace()
+		if generation := l.token(); isPDFInteger(generation) {
+			l.skipSpace()
+			if l.token() == "R" {
+				gen, _ := strconv.Atoi(generation)
+				return pdfRef{number: int(number), generation: gen}, nil
+			}
+		}
+		l.pos = save
+	}
+	return number, nil
+}
+
+func (l *pdfLexer) literalString() ([]byte, error) {
+	l.pos++
+	var value []byte
+	nesting := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			nesting++
+		case ')':
+			nesting--
+			if nesting == 0 {
+				return value, nil
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			c = l.data[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// A line continuation
+				if c == '\r' && l.peek(0) == '\n' {
+					l.pos++
+				}
+				continue
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				code := int(c - '0')
+				for i := 0; i < 2 && l.peek(0) >= '0' && l.peek(0) <= '7'; i++ {
+					code = code*8 + int(l.data[l.pos]-'0')
+					l.pos++
+				}
+				c = byte(code)
+			}
+		}
+		value = append(value, c)
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// indirect reads the object after "n g obj", which may be a stream
+func (l *pdfLexer) indirect() (any, error) {
+	value, err := l.object(0)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := value.(pdfDict)
+	if !ok {
+		return value, nil
+	}
+
+	save := l.pos
+	l.skipSpace()
+	if l.token() != "stream" {
+		l.pos = save
+		return value, nil
+	}
+	// The keyword ends with CRLF or LF
+	if l.peek(0) == '\r' {
+		l.pos++
+	}
+	if l.peek(0) == '\n' {
+		l.pos++
+	}
+	return pdfStream{dict: dict, start: l.pos}, nil
+}
+
+func unescapePDFName(name string) string {
+	if !strings.Contains(name, "#") {
+		return name
+	}
+	var unescaped []byte
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if value, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				unescaped = append(unescaped, byte(value))
+				i += 2
+				continue
+			}
+		}
+		unescaped = append(unescaped, name[i])
+	}
+	return string(unescaped)
+}
+
+// decodePDFHex decodes hex digits, skipping white space. A missing last
+// digit is 0.
+func decodePDFHex(data []byte) []byte {
+	digits := make([]byte, 0, len(data)+1)
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	value := make([]byte, len(digits)/2)
+	n, _ := hex.Decode(value, digits)
+	return value[:n]
+}
+
+// pdfReader holds the objects of a PDF, found by scanning the file rather
+// than trusting its cross-reference table, so damaged files open too
+type pdfReader struct {
+	data     []byte
+	objects  map[int]any
+	trailers []pdfDict // in file order
+}
+
+var (
+	pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfTrailer      = regexp.MustCompile(`trailer\s*<<`)
+)
+
+func newPDFReader(data []byte) (*pdfReader, error) {
+	r := &pdfReader{data: data, objects: make(map[int]any)}
+
+	// Trailers and cross-reference streams, which may hold one, are ordered
+	// by position so the last update wins
+	type positioned struct {
+		pos  int
+		dict pdfDict
+	}
+	var trailers []positioned
+	for _, match := range pdfTrailer.FindAllIndex(data, -1) {
+		lexer := &pdfLexer{data: data, pos: match[1] - 2}
+		if dict, err := lexer.object(0); err == nil {
+			trailers = append(trailers, positioned{match[0], dict.(pdfDict)})
+		}
+	}
+
+	// Objects are read in file order so later updates replace earlier ones.
+	// Stream data is skipped, so nothing inside it passes for an object.
+	var objectStreams []pdfStream
+	for pos := 0; pos < len(data); {
+		match := pdfObjectHeader.FindSubmatchIndex(data[pos:])
+		if match == nil {
+			break
+		}
+		number, _ := strconv.Atoi(string(data[pos+match[2] : pos+match[3]]))
+		lexer := &pdfLexer{data: data, pos: pos + match[1]}
+		start := pos + match[0]
+		pos += match[1]
+
+		value, err := lexer.indirect()
+		if err != nil {
+			continue
+		}
+		r.objects[number] = value
+		pos = lexer.pos
+		if stream, ok := value.(pdfStream); ok {
+			if end := bytes.Index(data[stream.start:], []byte("endstream")); end >= 0 {
+				pos = stream.start + end
+			}
+			switch stream.dict["Type"] {
+			case pdfName("ObjStm"):
+				objectStreams = append(objectStreams, stream)
+			case pdfName("XRef"):
+				trailers = append(trailers, positioned{start, stream.dict})
+			}
+		}
+	}
+
+	sort.SliceStable(trailers, func(i, j int) bool { return trailers[i].pos < trailers[j].pos })
+	for _, trailer := range trailers {
+		if _, ok := trailer.dict["Encrypt"]; ok {
+			return nil, fmt.Errorf("encrypted PDFs are not supported")
+		}
+		r.trailers = append(r.trailers, trailer.dict)
+	}
+
+	// Objects in object streams come after those stored plainly
+	for _, stream := range objectStreams {
+		r.readObjectStream(stream)
+	}
+	return r, nil
+}
+
+// readObjectStream adds the objects a compressed object stream holds.
+// Damaged streams are skipped.
+func (r *pdfReader) readObjectStream(stream pdfStream) {
+	data, err := r.decodeStream(stream)
+	if err != nil {
+		return
+	}
+	count, first := r.int(stream.dict, "N", 0), r.int(stream.dict, "First", 0)
+	header := &pdfLexer{data: data}
+	for i := 0; i < count; i++ {
+		number, err1 := header.object(0)
+		offset, err2 := header.object(0)
+		if err1 != nil || err2 != nil {
+			return
+		}
+		n, okNumber := number.(float64)
+		o, okOffset := offset.(float64)
+		if !okNumber || !okOffset {
+			return
+		}
+		if _, ok := r.objects[int(n)]; ok {
+			continue
+		}
+		lexer := &pdfLexer{data: data, pos: first + int(o)}
+		if lexer.pos < 0 || lexer.pos >= len(data) {
+			continue
+		}
+		if value, err := lexer.object(0); err == nil {
+			r.objects[int(n)] = value
+		}
+	}
+}
+
+// resolve follows references to the object they name. Missing objects
+// are nil, as the PDF specification has it.
+func (r *pdfReader) resolve(value any) any {
+	for depth := 0; depth < maxPDFDepth; depth++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		value = r.objects[ref.number]
+	}
+	return nil
+}
+
+func (r *pdfReader) int(dict pdfDict, key pdfName, fallback int) int {
+	if value, ok := r.resolve(dict[key]).(float64); ok && math.Abs(value) < math.MaxInt32 {
+		return int(value)
+	}
+	return fallback
+}
+
+// streamRange is where a stream's data lies in the file: as long as
+// Length says when that ends at endstream, up to endstream otherwise
+func (r *pdfReader) streamRange(stream pdfStream) (start, end int) {
+	start = stream.start
+	if length := r.int(stream.dict, "Length", -1); length >= 0 && start+length <= len(r.data) {
+		rest := bytes.TrimLeft(r.data[start+length:], "\r\n \t")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			return start, start + length
+		}
+	}
+	if i := bytes.Index(r.data[start:], []byte("endstream")); i >= 0 {
+		return start, start + len(bytes.TrimRight(r.data[start:start+i], "\r\n"))
+	}
+	return start, len(r.data)
+}
+
+// filters lists a stream's filters with their parameters, resolved
+func (r *pdfReader) filters(dict pdfDict) ([]pdfName, []pdfDict) {
+	var names []pdfName
+	switch filter := r.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		names = []pdfName{filter}
+	case []any:
+		for _, value := range filter {
+			if name, ok := r.resolve(value).(pdfName); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	params := make([]pdfDict, len(names))
+	switch value := r.resolve(dict["DecodeParms"]).(type) {
+	case pdfDict:
+		if len(names) > 0 {
+			params[0] = value
+		}
+	case []any:
+		for i := range min(len(names), len(value)) {
+			params[i], _ = r.resolve(value[i]).(pdfDict)
+		}
+	}
+	for i, param := range params {
+		resolved := pdfDict{}
+		for key, value := range param {
+			resolved[key] = r.resolve(value)
+		}
+		params[i] = resolved
+	}
+	return names, params
+}
+
+// decodeStream returns the data of a stream that only uses general filters
+func (r *pdfReader) decodeStream(stream pdfStream) ([]byte, error) {
+	start, end := r.streamRange(stream)
+	data := r.data[start:end]
+	names, params := r.filters(stream.dict)
+	for i, name := range names {
+		var err error
+		if data, err = pdfFilter(name, params[i], data, maxTIFFValue); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// pdfFilter undoes one of the general stream filters. Decoded data is cut
+// off at limit bytes.
+func pdfFilter(name pdfName, params pdfDict, data []byte, limit int) ([]byte, error) {
+	switch name {
+	case "FlateDecode":
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("FlateDecode: %w", err)
+		}
+		decoded, err := io.ReadAll(io.LimitReader(reader, int64(limit)))
+		// Streams cut short or with a bad checksum are common and kept
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, zlib.ErrChecksum) {
+			return nil, fmt.Errorf("FlateDecode: %w", err)
+		}
+		return pdfPredict(decoded, params)
+	case "ASCIIHexDecode":
+		return decodePDFHex(data), nil
+	case "ASCII85Decode":
+		data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+		if end := bytes.Index(data, []byte("~>")); end >= 0 {
+			data = data[:end]
+		}
+		return io.ReadAll(io.LimitReader(ascii85.NewDecoder(bytes.NewReader(data)), int64(limit)))
+	case "RunLengthDecode":
+		var decoded []byte
+		for i := 0; i < len(data) && data[i] != 128 && len(decoded) < limit; {
+			length := int(data[i])
+			if length < 128 {
+				end := min(i+2+length, len(data))
+				decoded = append(decoded, data[i+1:end]...)
+				i = end
+				continue
+			}
+			if i+1 < len(data) {
+				decoded = append(decoded, bytes.Repeat(data[i+1:i+2], 257-length)...)
+			}
+			i += 2
+		}
+		return decoded, nil
+	}
+	return nil, fmt.Errorf("unsupported PDF filter %s", name)
+}
+
+// pdfPredict undoes the PNG or TIFF predictor a Flate stream was
+// compressed with
+func pdfPredict(data []byte, params pdfDict) ([]byte, error) {
+	param := func(key pdfName, fallback int) int {
+		if value, ok := params[key].(float64); ok {
+			return int(value)
+		}
+		return fallback
+	}
+	predictor := param("Predictor", 1)
+	if predictor <= 1 {
+		return data, nil
+	}
+	colors, bits, columns := param("Colors", 1), param("BitsPerComponent", 8), param("Columns", 1)
+	rowLength := (columns*colors*bits + 7) / 8
+	if colors <= 0 || bits <= 0 || columns <= 0 || rowLength <= 0 || rowLength > 1<<28 {
+		return nil, fmt.Errorf("invalid predictor parameters")
+	}
+	pixelLength := max(1, colors*bits/8)
+
+	if predictor == 2 {
+		if bits != 8 {
+			return nil, fmt.Errorf("TIFF predictor with %d bits is not supported", bits)
+		}
+		for row := data; len(row) >= rowLength; row = row[rowLength:] {
+			for i := pixelLength; i < rowLength; i++ {
+				row[i] += row[i-pixelLength]
+			}
+		}
+		return data, nil
+	}
+
+	// PNG predictors prefix every row with its filter type
+	decoded := make([]byte, 0, len(data)/(rowLength+1)*rowLength)
+	previous := make([]byte, rowLength)
+	for len(data) >= rowLength+1 {
+		filter, row := data[0], data[1:rowLength+1]
+		data = data[rowLength+1:]
+		for i := range row {
+			var left, upLeft byte
+			if i >= pixelLength {
+				left, upLeft = row[i-pixelLength], previous[i-pixelLength]
+			}
+			up := previous[i]
+			switch filter {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		decoded = append(decoded, row...)
+		previous = row
+	}
+	return decoded, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// pdfColorSpace is what decoding needs of an image's colour space
+type pdfColorSpace struct {
+	components int     // per sample
+	family     pdfName // DeviceGray, DeviceRGB or DeviceCMYK, of the palette for Indexed
+	palette    []byte  // colours of an Indexed space
+	icc        []byte
+}
+
+func (s pdfColorSpace) familyComponents() int {
+	switch s.family {
+	case "DeviceRGB":
+		return 3
+	case "DeviceCMYK":
+		return 4
+	}
+	return 1
+}
+
+func (r *pdfReader) colorSpace(value any, depth int) (pdfColorSpace, error) {
+	value = r.resolve(value)
+	switch space := value.(type) {
+	case pdfName:
+		switch space {
+		case "DeviceGray", "CalGray", "G":
+			return pdfColorSpace{components: 1, family: "DeviceGray"}, nil
+		case "DeviceRGB", "CalRGB", "RGB":
+			return pdfColorSpace{components: 3, family: "DeviceRGB"}, nil
+		case "DeviceCMYK", "CMYK":
+			return pdfColorSpace{components: 4, family: "DeviceCMYK"}, nil
+		}
+	case []any:
+		if len(space) == 0 || depth > 4 {
+			break
+		}
+		switch family, _ := r.resolve(space[0]).(pdfName); {
+		case family == "CalGray" || family == "CalRGB":
+			return r.colorSpace(family, depth+1)
+		case family == "ICCBased" && len(space) > 1:
+			stream, ok := r.resolve(space[1]).(pdfStream)
+			if !ok {
+				break
+			}
+			families := map[int]pdfName{1: "DeviceGray", 3: "DeviceRGB", 4: "DeviceCMYK"}
+			result, err := r.colorSpace(families[r.int(stream.dict, "N", 0)], depth+1)
+			if err != nil {
+				return result, err
+			}
+			// A profile that does not decode only costs colour accuracy
+			if profile, err := r.decodeStream(stream); err == nil {
+				result.icc = profile
+			}
+			return result, nil
+		case family == "Indexed" && len(space) == 4:
+			base, err := r.colorSpace(space[1], depth+1)
+			if err != nil || base.palette != nil {
+				break
+			}
+			var lookup []byte
+			switch table := r.resolve(space[3]).(type) {
+			case []byte:
+				lookup = table
+			case pdfStream:
+				if lookup, err = r.decodeStream(table); err != nil {
+					return pdfColorSpace{}, err
+				}
+			}
+			return pdfColorSpace{components: 1, family: base.family, palette: lookup, icc: base.icc}, nil
+		}
+	}
+	return pdfColorSpace{}, fmt.Errorf("unsupported PDF colour space %v", value)
+}
+
+// pdfImage locates an image XObject in the file and says how to decode it,
+// so pages load without keeping the PDF in memory
+type pdfImage struct {
+	start, end    int
+	width, height int
+	bits          int
+	filters       []pdfName
+	params        []pdfDict
+	space         pdfColorSpace
+	invert        bool // Decode maps samples from 1 down to 0
+}
+
+// pdfPage is a page's image with the page geometry that places it
+type pdfPage struct {
+	image    *pdfImage
+	err      error // why a page has no image
+	mediaBox [4]float64
+	rotate   int
+}
+
+// openPDF reads the page tree of a PDF and finds every page's image
+func openPDF(path string) ([]pdfPage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newPDFReader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var catalog pdfDict
+	for _, trailer := range r.trailers {
+		if root, ok := r.resolve(trailer["Root"]).(pdfDict); ok {
+			catalog = root
+		}
+	}
+	if catalog == nil {
+		// Without a usable trailer the catalog is found by its type
+		numbers := slices.Sorted(maps.Keys(r.objects))
+		for _, number := range numbers {
+			if dict, ok := r.objects[number].(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+				catalog = dict
+			}
+		}
+	}
+	root, ok := r.resolve(catalog["Pages"]).(pdfDict)
+	if !ok {
+		return nil, fmt.Errorf("no page tree")
+	}
+
+	var pages []pdfPage
+	if err := r.walkPages(root, pdfDict{}, make(map[pdfRef]bool), 0, &pages); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages")
+	}
+	return pages, nil
+}
+
+// walkPages collects the pages below node in order. Resources, MediaBox and
+// Rotate are inherited from the nodes above.
+func (r *pdfReader) walkPages(node, inherited pdfDict, visited map[pdfRef]bool, depth int, pages *[]pdfPage) error {
+	if depth > maxPDFDepth {
+		return fmt.Errorf("page tree nested too deep")
+	}
+	inherited = maps.Clone(inherited)
+	for _, key := range []pdfName{"Resources", "MediaBox", "Rotate"} {
+		if value, ok := node[key]; ok {
+			inherited[key] = value
+		}
+	}
+
+	if kids, ok := r.resolve(node["Kids"]).([]any); ok && node["Type"] != pdfName("Page") {
+		for _, kid := range kids {
+			if ref, ok := kid.(pdfRef); ok {
+				if visited[ref] {
+					continue
+				}
+				visited[ref] = true
+			}
+			child, ok := r.resolve(kid).(pdfDict)
+			if !ok {
+				continue
+			}
+			if err := r.walkPages(child, inherited, visited, depth+1, pages); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(*pages) >= maxPDFPages {
+		return fmt.Errorf("more than %d pages", maxPDFPages)
+	}
+	page := pdfPage{mediaBox: [4]float64{0, 0, 612, 792}, rotate: r.int(inherited, "Rotate", 0)}
+	if box, ok := r.resolve(inherited["MediaBox"]).([]any); ok && len(box) == 4 {
+		for i, value := range box {
+			page.mediaBox[i], _ = r.resolve(value).(float64)
+		}
+	}
+	resources, _ := r.resolve(inherited["Resources"]).(pdfDict)
+	page.image, page.err = r.largestImage(resources, 0)
+	*pages = append(*pages, page)
+	return nil
+}
+
+// largestImage finds the image XObject with the most pixels, looking into
+// form XObjects too since some scanners wrap the page image in one
+func (r *pdfReader) largestImage(resources pdfDict, depth int) (*pdfImage, error) {
+	xobjects, _ := r.resolve(resources["XObject"]).(pdfDict)
+	var largest *pdfImage
+	var firstErr error
+	consider := func(image *pdfImage, err error) {
+		switch {
+		case err != nil:
+			if firstErr == nil {
+				firstErr = err
+			}
+		case largest == nil || image.width*image.height > largest.width*largest.height:
+			largest = image
+		}
+	}
+
+	names := slices.Sorted(maps.Keys(xobjects))
+	for _, name := range names {
+		stream, ok := r.resolve(xobjects[name]).(pdfStream)
+		if !ok {
+			continue
+		}
+		switch r.resolve(stream.dict["Subtype"]) {
+		case pdfName("Image"):
+			consider(r.image(stream))
+		case pdfName("Form"):
+			if nested, ok := r.resolve(stream.dict["Resources"]).(pdfDict); ok && depth < 4 {
+				if image, err := r.largestImage(nested, depth+1); image != nil || err != nil {
+					consider(image, err)
+				}
+			}
+		}
+	}
+
+	if largest != nil {
+		return largest, nil
+	}
+	if firstErr == nil && depth == 0 {
+		firstErr = fmt.Errorf("page has no image; only scanned PDFs are supported")
+	}
+	return nil, firstErr
+}
+
+func (r *pdfReader) image(stream pdfStream) (*pdfImage, error) {
+	dict := stream.dict
+	image := &pdfImage{
+		width:  r.int(dict, "Width", 0),
+		height: r.int(dict, "Height", 0),
+		bits:   r.int(dict, "BitsPerComponent", 8),
+	}
+	if err := checkDimensions("PDF image", image.width, image.height, maxPDFImageDimension); err != nil {
+		return nil, err
+	}
+	image.start, image.end = r.streamRange(stream)
+	image.filters, image.params = r.filters(dict)
+
+	var codec pdfName
+	if len(image.filters) > 0 {
+		codec = image.filters[len(image.filters)-1]
+	}
+	var err error
+	if mask, _ := r.resolve(dict["ImageMask"]).(bool); mask {
+		// Stencil masks paint where samples are 0, like black in gray
+		image.bits = 1
+		image.space, _ = r.colorSpace(pdfName("DeviceGray"), 0)
+	} else if image.space, err = r.colorSpace(dict["ColorSpace"], 0); err != nil && codec != "DCTDecode" && codec != "JPXDecode" {
+		// JPEG and JPEG 2000 data name their own colours
+		return nil, err
+	}
+	if decode, ok := r.resolve(dict["Decode"]).([]any); ok && len(decode) >= 2 {
+		low, _ := r.resolve(decode[0]).(float64)
+		high, _ := r.resolve(decode[1]).(float64)
+		image.invert = low > high
+	}
+	return image, nil
+}
+
+// load decodes the page image and turns it as the page is shown. The
+// resolution follows from the image filling the media box.
+func (p pdfPage) load(path string, number int) (gocv.Mat, ImageMetadata, error) {
+	if p.image == nil {
+		return gocv.NewMat(), ImageMetadata{}, fmt.Errorf("page %d: %w", number, p.err)
+	}
+	mat, err := p.image.decode(path)
+	if err != nil {
+		return gocv.NewMat(), ImageMetadata{}, fmt.Errorf("page %d: %w", number, err)
+	}
+
+	metadata := ImageMetadata{ICC: p.image.space.icc}
+	boxWidth := math.Abs(p.mediaBox[2] - p.mediaBox[0])
+	boxHeight := math.Abs(p.mediaBox[3] - p.mediaBox[1])
+	if boxWidth > 0 && boxHeight > 0 {
+		width, height := float64(mat.Cols()), float64(mat.Rows())
+		// An image drawn turned on the page fits the box the other way round
+		aspect := math.Log(width / height)
+		if math.Abs(aspect-math.Log(boxHeight/boxWidth)) < math.Abs(aspect-math.Log(boxWidth/boxHeight)) {
+			boxWidth, boxHeight = boxHeight, boxWidth
+		}
+		metadata.Resolution = Resolution{X: math.Round(width * 72 / boxWidth), Y: math.Round(height * 72 / boxHeight)}
+	}
+
+	rotations := map[int]gocv.RotateFlag{90: gocv.Rotate90Clockwise, 180: gocv.Rotate180Clockwise, 270: gocv.Rotate90CounterClockwise}
+	if flag, ok := rotations[((p.rotate%360)+360)%360]; ok {
+		rotated := gocv.NewMat()
+		err := gocv.Rotate(mat, &rotated, flag)
+		mat.Close()
+		if err != nil {
+			rotated.Close()
+			return gocv.NewMat(), ImageMetadata{}, openCVError("page rotation", err)
+		}
+		mat = rotated
+		if flag != gocv.Rotate180Clockwise {
+			metadata.Resolution.X, metadata.Resolution.Y = metadata.Resolution.Y, metadata.Resolution.X
+		}
+	}
+	return mat, metadata, nil
+}
+
+// decode reads the image's data from the PDF at path and decodes it to the
+// layouts readImage gives
+func (image *pdfImage) decode(path string) (gocv.Mat, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	data := make([]byte, image.end-image.start)
+	_, err = file.ReadAt(data, int64(image.start))
+	file.Close()
+	if err != nil {
+		return gocv.NewMat(), fmt.Errorf("read image data: %w", err)
+	}
+
+	rowLength := (image.width*image.space.components*image.bits + 7) / 8
+	limit := image.height * (rowLength + 1)
+	for i, filter := range image.filters {
+		last := i == len(image.filters)-1
+		switch filter {
+		case "DCTDecode", "JPXDecode", "CCITTFaxDecode", "JBIG2Decode":
+			if !last {
+				return gocv.NewMat(), fmt.Errorf("%s must be the last filter", filter)
+			}
+			return image.decodeCodec(filter, image.params[i], data)
+		}
+		if data, err = pdfFilter(filter, image.params[i], data, limit); err != nil {
+			return gocv.NewMat(), err
+		}
+	}
+	return image.samples(data)
+}
+
+// decodeCodec decodes image data in one of the formats OpenCV reads,
+// wrapping fax data in a TIFF to do so
+func (image *pdfImage) decodeCodec(filter pdfName, params pdfDict, data []byte) (gocv.Mat, error) {
+	invert := false
+	switch filter {
+	case "JBIG2Decode":
+		return gocv.NewMat(), fmt.Errorf("JBIG2 images are not supported")
+	case "CCITTFaxDecode":
+		// Decoded fax data has 1 for black when BlackIs1 is set, which
+		// gray reads as white unless Decode turns it round again
+		blackIs1, _ := params["BlackIs1"].(bool)
+		invert = blackIs1 != image.invert
+		data = ccittTIFF(data, image.width, image.height, params)
+	}
+
+	mat, err := decodeImage(string(filter)+" image", func(flags gocv.IMReadFlag) gocv.Mat {
+		mat, err := gocv.IMDecode(data, flags)
+		if err != nil {
+			return gocv.NewMat()
+		}
+		return mat
+	})
+	if err != nil || !invert {
+		return mat, err
+	}
+	inverted := gocv.NewMat()
+	if err := gocv.BitwiseNot(mat, &inverted); err != nil {
+		mat.Close()
+		inverted.Close()
+		return gocv.NewMat(), openCVError("fax image inversion", err)
+	}
+	mat.Close()
+	return inverted, nil
+}
+
+// ccittTIFF wraps CCITT fax data in a one-strip TIFF. K below 0 is Group 4,
+// otherwise Group 3 with K above 0 meaning 2D coding.
+func ccittTIFF(data []byte, width, height int, params pdfDict) []byte {
+	k, _ := params["K"].(float64)
+	if columns, ok := params["Columns"].(float64); ok && columns > 0 {
+		width = int(columns)
+	}
+	if rows, ok := params["Rows"].(float64); ok && rows > 0 {
+		height = int(rows)
+	}
+
+	if k < 0 {
+		return faxTIFF(data, width, height, shortEntry(tagCompression, compressionCCITTGroup4))
+	}
+	var options uint32
+	if k > 0 {
+		options |= 1 // 2D coding
+	}
+	if aligned, _ := params["EncodedByteAlign"].(bool); aligned {
+		options |= 4 // rows start on a byte
+	}
+	return faxTIFF(data, width, height, shortEntry(tagCompression, compressionCCITTGroup3), longEntry(tagT4Options, options))
+}
+
+// samples turns uncompressed samples into a gray or BGR Mat: 16-bit
+// samples stay 16-bit, fewer bits become 8-bit and CMYK becomes BGR
+func (image *pdfImage) samples(data []byte) (gocv.Mat, error) {
+	bits, space := image.bits, image.space
+	if bits != 1 && bits != 2 && bits != 4 && bits != 8 && bits != 16 {
+		return gocv.NewMat(), fmt.Errorf("unsupported %d bits per component", bits)
+	}
+	components := space.components
+	rowLength := (image.width*components*bits + 7) / 8
+	// Short data leaves the rest of the image black, as viewers show it
+	if size := rowLength * image.height; len(data) < size {
+		data = append(data, make([]byte, size-len(data))...)
+	}
+
+	depth, full := gocv.MatTypeCV8U, 255
+	if bits == 16 && space.palette == nil {
+		depth, full = gocv.MatTypeCV16U, 65535
+	}
+	channels := 1
+	if space.familyComponents() > 1 {
+		channels = 3
+	}
+	mat := gocv.NewMatWithSize(image.height, image.width, matType(depth, channels))
+	var put func(i, value int)
+	if depth == gocv.MatTypeCV16U {
+		pixels, err := mat.DataPtrUint16()
+		if err != nil {
+			mat.Close()
+			return gocv.NewMat(), err
+		}
+		put = func(i, value int) { pixels[i] = uint16(value) }
+	} else {
+		pixels, err := mat.DataPtrUint8()
+		if err != nil {
+			mat.Close()
+			return gocv.NewMat(), err
+		}
+		put = func(i, value int) { pixels[i] = uint8(value) }
+	}
+
+	sample := func(row []byte, i int) int {
+		switch bits {
+		case 8:
+			return int(row[i])
+		case 16:
+			return int(binary.BigEndian.Uint16(row[2*i:]))
+		}
+		bit := i * bits
+		return int(row[bit/8]>>(8-bits-bit%8)) & (1<<bits - 1)
+	}
+
+	maxSample := 1<<bits - 1
+	base := space.familyComponents()
+	color := make([]int, 4)
+	for y := 0; y < image.height; y++ {
+		row := data[y*rowLength : (y+1)*rowLength]
+		for x := 0; x < image.width; x++ {
+			if space.palette != nil {
+				index := sample(row, x) * base
+				for c := 0; c < base; c++ {
+					color[c] = 0
+					if index+c < len(space.palette) {
+						color[c] = int(space.palette[index+c])
+					}
+				}
+			} else {
+				for c := 0; c < components; c++ {
+					value := sample(row, x*components+c)
+					if image.invert {
+						value = maxSample - value
+					}
+					color[c] = value * full / maxSample
+				}
+			}
+
+			i := (y*image.width + x) * channels
+			switch space.family {
+			case "DeviceRGB":
+				put(i, color[2])
+				put(i+1, color[1])
+				put(i+2, color[0])
+			case "DeviceCMYK":
+				black := full - color[3]
+				put(i, (full-color[2])*black/full)
+				put(i+1, (full-color[1])*black/full)
+				put(i+2, (full-color[0])*black/full)
+			default:
+				put(i, color[0])
+			}
+		}
+	}
+	return mat, nil
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPDFLexerObjects(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  any
+	}{
+		{"integer", "42", 42.0},
+		{"real", "-.5", -0.5},
+		{"comment", "% a comment\n 7", 7.0},
+		{"booleans", "[true false null]", []any{true, false, nil}},
+		{"name", "/Type", pdfName("Type")},
+		{"escaped name", "/A#20B", pdfName("A B")},
+		{"literal string", "(Hello)", []byte("Hello")},
+		{"nested parentheses", "(a (b) c)", []byte("a (b) c")},
+		{"escapes", `(\(\)\\\n\t\101)`, []byte("()\\\n\tA")},
+		{"line continuation", "(ab\\\r\ncd)", []byte("abcd")},
+		{"hex string", "<48 65 6C6C6F>", []byte("Hello")},
+		{"odd hex string", "<414>", []byte{0x41, 0x40}},
+		{"reference", "12 0 R", pdfRef{number: 12}},
+		{"numbers not a reference", "[1 2 3]", []any{1.0, 2.0, 3.0}},
+		{"references in array", "[1 0 R 2 5 R]", []any{pdfRef{number: 1}, pdfRef{number: 2, generation: 5}}},
+		{
+			"nested dictionaries",
+			"<< /Type /Page /Kids [3 0 R] /Sub << /A 1.5 /B (x) /C << >> >> >>",
+			pdfDict{
+				"Type": pdfName("Page"),
+				"Kids": []any{pdfRef{number: 3}},
+				"Sub":  pdfDict{"A": 1.5, "B": []byte("x"), "C": pdfDict{}},
+			},
+		},
+		{"dictionary without spaces", "<</A/B/C[1]>>", pdfDict{"A": pdfName("B"), "C": []any{1.0}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lexer := &pdfLexer{data: []byte(tt.input)}
+			got, err := lexer.object(0)
+			if err != nil {
+				t.Fatalf("object(%q): %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("object(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPDFLexerMalformed(t *testing.T) {
+	inputs := []string{
+		"",
+		"   % only a comment",
+		"<< /A",
+		"<< /A 1",
+		"<< 1 2 >>",
+		"<< /A >",
+		"[1 2",
+		"(abc",
+		"(abc\\",
+		"<616",
+		")",
+		"]",
+		"{",
+		"abc",
+		strings.Repeat("[", 2*maxPDFDepth),
+		strings.Repeat("<< /A ", 2*maxPDFDepth),
+	}
+
+	for _, input := range inputs {
+		lexer := &pdfLexer{data: []byte(input)}
+		if value, err := lexer.object(0); err == nil {
+			t.Errorf("object(%q) = %#v, want an error", input, value)
+		}
+	}
+}
+
+// buildPDF numbers objects from 1 and joins them into a PDF whose trailer
+// names object 1 as the catalog. Without xref the cross-reference table and
+// trailer are left out.
+func buildPDF(xref bool, objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	if !xref {
+		return b.Bytes()
+	}
+
+	start := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, start)
+	return b.Bytes()
+}
+
+// pdfImageObject is an uncompressed 2x2 gray image with the given samples
+func pdfImageObject(samples string) string {
+	return "<< /Type /XObject /Subtype /Image /Width 2 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 8 /Length 4 >>\nstream\n" + samples + "\nendstream"
+}
+
+// threePagePDF has a page tree with a nested node, whose pages inherit
+// their media box and resources
+func threePagePDF(xref bool) []byte {
+	return buildPDF(xref,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 3 /MediaBox [0 0 144 144] >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im0 7 0 R >> >> >>",
+		"<< /Type /Pages /Parent 2 0 R /Kids [5 0 R 6 0 R] /Count 2 /Resources << /XObject << /Im0 8 0 R >> >> >>",
+		"<< /Type /Page /Parent 4 0 R >>",
+		"<< /Type /Page /Parent 4 0 R /Rotate 90 >>",
+		pdfImageObject("\x00\x40\x80\xff"),
+		pdfImageObject("\xff\xff\x00\x00"),
+	)
+}
+
+func writeTestPDF(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.pdf")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenPDFPageCount(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"cross-reference table", threePagePDF(true)},
+		{"no cross-reference table", threePagePDF(false)},
+		{"wrong startxref", append(threePagePDF(false), "startxref\n999999\n%%EOF\n"...)},
+		{"trailer with missing root", append(threePagePDF(false), "trailer\n<< /Root 99 0 R >>\n%%EOF\n"...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, err := openPDF(writeTestPDF(t, tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pages) != 3 {
+				t.Fatalf("got %d pages, want 3", len(pages))
+			}
+			for i, page := range pages {
+				if page.image == nil {
+					t.Fatalf("page %d has no image: %v", i+1, page.err)
+				}
+				if page.mediaBox != [4]float64{0, 0, 144, 144} {
+					t.Errorf("page %d media box %v, want the inherited [0 0 144 144]", i+1, page.mediaBox)
+				}
+			}
+			if pages[2].rotate != 90 {
+				t.Errorf("page 3 rotated %d, want 90", pages[2].rotate)
+			}
+		})
+	}
+}
+
+func TestPDFPageLoad(t *testing.T) {
+	path := writeTestPDF(t, threePagePDF(true))
+	pages, err := openPDF(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mat, metadata, err := pages[0].load(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mat.Close()
+	if mat.Cols() != 2 || mat.Rows() != 2 || mat.Channels() != 1 {
+		t.Fatalf("page 1 is %dx%d with %d channels, want 2x2 gray", mat.Cols(), mat.Rows(), mat.Channels())
+	}
+	want := [][]uint8{{0x00, 0x40}, {0x80, 0xff}}
+	for y, row := range want {
+		for x, value := range row {
+			if got := mat.GetUCharAt(y, x); got != value {
+				t.Errorf("pixel (%d, %d) = %d, want %d", x, y, got, value)
+			}
+		}
+	}
+	// Two pixels across two inches
+	if metadata.Resolution != (Resolution{X: 1, Y: 1}) {
+		t.Errorf("resolution %v, want 1 DPI", metadata.Resolution)
+	}
+}
+
+func TestOpenPDFLaterUpdateWins(t *testing.T) {
+	data := threePagePDF(false)
+	data = append(data, "5 0 obj\n<< /Type /Page /Parent 4 0 R /MediaBox [0 0 72 72] >>\nendobj\n"...)
+
+	pages, err := openPDF(writeTestPDF(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages[1].mediaBox != [4]float64{0, 0, 72, 72} {
+		t.Errorf("page 2 media box %v, want the updated [0 0 72 72]", pages[1].mediaBox)
+	}
+}
+
+func TestOpenPDFMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not a PDF", []byte("hello world")},
+		{"no page tree", buildPDF(true, "<< /Type /Catalog >>")},
+		{"page without image", buildPDF(true,
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"<< /Type /Page /Parent 2 0 R >>",
+		)},
+		{"page tree cycle", buildPDF(true,
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [2 0 R] /Count 1 >>",
+		)},
+		{"encrypted", append(threePagePDF(true), "trailer\n<< /Root 1 0 R /Encrypt 9 0 R >>\n"...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, err := openPDF(writeTestPDF(t, tt.data))
+			if err == nil && len(pages) > 0 && pages[0].image != nil {
+				t.Errorf("opened %d pages, want an error or a page without image", len(pages))
+			}
+		})
+	}
+}
+
+// Every prefix of a PDF must open or fail cleanly, never panic
+func TestOpenPDFTruncated(t *testing.T) {
+	data := threePagePDF(true)
+	path := filepath.Join(t.TempDir(), "truncated.pdf")
+	for n := range len(data) {
+		if err := os.WriteFile(path, data[:n], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		pages, err := openPDF(path)
+		if err != nil {
+			continue
+		}
+		for i, page := range pages {
+			if page.image == nil {
+				continue
+			}
+			mat, _, err := page.load(path, i+1)
+			if err == nil {
+				mat.Close()
+			}
+		}
+	}
+}
+
+func TestPDFStreamRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		start      int
+		dict       pdfDict
+		wantResult string
+	}{
+		{"length", "abcd\nendstream", 0, pdfDict{"Length": 4.0}, "abcd"},
+		{"wrong length", "abcdef\nendstream", 0, pdfDict{"Length": 4.0}, "abcdef"},
+		{"length past the end", "abcd\nendstream", 0, pdfDict{"Length": 1000.0}, "abcd"},
+		{"negative length", "abcd\r\nendstream", 0, pdfDict{"Length": -5.0}, "abcd"},
+		{"no endstream", "abcd", 0, pdfDict{}, "abcd"},
+		{"at the end", "abcd", 4, pdfDict{"Length": 10.0}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &pdfReader{data: []byte(tt.data), objects: map[int]any{}}
+			start, end := r.streamRange(pdfStream{dict: tt.dict, start: tt.start})
+			if got := tt.data[start:end]; got != tt.wantResult {
+				t.Errorf("stream data %q, want %q", got, tt.wantResult)
+			}
+		})
+	}
+}
+
+func deflateForTest(data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+func TestPDFFlatePredictors(t *testing.T) {
+	tests := []struct {
+		name      string
+		params    pdfDict
+		predicted []byte
+		want      []byte
+	}{
+		{
+			"no predictor",
+			pdfDict{},
+			[]byte{1, 2, 3},
+			[]byte{1, 2, 3},
+		},
+		{
+			"PNG none and sub",
+			pdfDict{"Predictor": 10.0, "Columns": 3.0},
+			[]byte{0, 10, 20, 30, 1, 10, 10, 10},
+			[]byte{10, 20, 30, 10, 20, 30},
+		},
+		{
+			"PNG up",
+			pdfDict{"Predictor": 12.0, "Columns": 3.0},
+			[]byte{2, 10, 20, 30, 2, 1, 2, 3},
+			[]byte{10, 20, 30, 11, 22, 33},
+		},
+		{
+			"PNG average and Paeth",
+			pdfDict{"Predictor": 15.0, "Columns": 3.0},
+			[]byte{3, 10, 15, 20, 4, 1, 2, 3},
+			[]byte{10, 20, 30, 11, 22, 33},
+		},
+		{
+			"PNG sub with RGB pixels",
+			pdfDict{"Predictor": 11.0, "Columns": 2.0, "Colors": 3.0},
+			[]byte{1, 10, 20, 30, 5, 5, 5},
+			[]byte{10, 20, 30, 15, 25, 35},
+		},
+		{
+			"PNG incomplete last row dropped",
+			pdfDict{"Predictor": 10.0, "Columns": 3.0},
+			[]byte{0, 1, 2, 3, 0, 4},
+			[]byte{1, 2, 3},
+		},
+		{
+			"TIFF",
+			pdfDict{"Predictor": 2.0, "Columns": 2.0, "Colors": 3.0},
+			[]byte{10, 20, 30, 5, 5, 5, 1, 1, 1, 1, 1, 1},
+			[]byte{10, 20, 30, 15, 25, 35, 1, 1, 1, 2, 2, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pdfFilter("FlateDecode", tt.params, deflateForTest(tt.predicted), 1<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("decoded %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPDFFilterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter pdfName
+		params pdfDict
+		data   []byte
+	}{
+		{"not zlib data", "FlateDecode", nil, []byte("not compressed")},
+		{"zero columns", "FlateDecode", pdfDict{"Predictor": 12.0, "Columns": 0.0}, deflateForTest([]byte{0, 1})},
+		{"negative colours", "FlateDecode", pdfDict{"Predictor": 12.0, "Colors": -1.0}, deflateForTest([]byte{0, 1})},
+		{"huge rows", "FlateDecode", pdfDict{"Predictor": 12.0, "Columns": 1e12}, deflateForTest([]byte{0, 1})},
+		{"16-bit TIFF predictor", "FlateDecode", pdfDict{"Predictor": 2.0, "BitsPerComponent": 16.0}, deflateForTest([]byte{0, 1})},
+		{"unknown filter", "LZWDecode", nil, []byte{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := pdfFilter(tt.filter, tt.params, tt.data, 1<<20); err == nil {
+				t.Errorf("decoded %v, want an error", got)
+			}
+		})
+	}
+}
+
+func TestPDFFilterDamagedData(t *testing.T) {
+	// A Flate stream cut short keeps what was decoded
+	compressed := deflateForTest(bytes.Repeat([]byte("abcdefgh"), 100))
+	if _, err := pdfFilter("FlateDecode", nil, compressed[:len(compressed)/2], 1<<20); err != nil {
+		t.Errorf("truncated Flate stream: %v", err)
+	}
+
+	// Run lengths past the end of the data
+	for _, data := range [][]byte{{5, 'a'}, {200}, {127}, {0}} {
+		if _, err := pdfFilter("RunLengthDecode", nil, data, 1<<20); err != nil {
+			t.Errorf("RunLengthDecode(%v): %v", data, err)
+		}
+	}
+
+	// Decoding stops at the limit
+	decoded, err := pdfFilter("RunLengthDecode", nil, []byte{129, 'x', 129, 'x', 129, 'x'}, 200)
+	if err != nil || len(decoded) > 200+128 {
+		t.Errorf("RunLengthDecode past the limit: %d bytes, %v", len(decoded), err)
+	}
+}
+
+func TestPDFObjectStream(t *testing.T) {
+	// The header pairs object numbers with offsets after First; the third
+	// points past the data
+	objects := "<< /A 1 >> [2]"
+	header := "10 0 11 11 12 999 "
+	data := deflateForTest([]byte(header + objects))
+	r := &pdfReader{data: data, objects: map[int]any{}}
+	r.readObjectStream(pdfStream{
+		dict:  pdfDict{"Type": pdfName("ObjStm"), "N": 3.0, "First": float64(len(header)), "Filter": pdfName("FlateDecode"), "Length": float64(len(data))},
+		start: 0,
+	})
+
+	if got := r.objects[10]; !reflect.DeepEqual(got, pdfDict{"A": 1.0}) {
+		t.Errorf("object 10 = %#v", got)
+	}
+	if got := r.objects[11]; !reflect.DeepEqual(got, []any{2.0}) {
+		t.Errorf("object 11 = %#v", got)
+	}
+	if _, ok := r.objects[12]; ok {
+		t.Errorf("object 12 read from outside the stream")
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gocv.io/x/gocv"
+)
+
+// PDF export places each processed page image on a page sized by its
+// resolution. Bilevel images are stored with CCITT Group 4 and others with
+// Flate. The original page can go along as a layer that viewers hide until
+// it is switched on, and recognized words are laid over the image as
+// invisible text so the page can be searched and copied from.
+
+// defaultPDFResolution sizes the pages of images without a resolution
+const defaultPDFResolution = 300
+
+// pdfOutputPage is one page for pdfPageWriter
+type pdfOutputPage struct {
+	image    gocv.Mat
+	original gocv.Mat // hidden layer, none when empty
+	metadata ImageMetadata
+	words    []TextWord
+}
+
+// pdfPageWriter writes a PDF page by page, so only one page is held in
+// memory at a time. Objects 1 and 2, the catalog and the page tree, are
+// written by Close once every page is known.
+type pdfPageWriter struct {
+	file    *os.File
+	size    int64
+	offsets []int64 // of every object, by number - 1
+	pages   []int
+	layer   int // optional content group of the originals, 0 until needed
+	font    int
+	xmp     []byte // of the first page, for the document
+	save    SaveOptions
+}
+
+// createPDF starts a PDF at path. The JPEG settings of save apply to the
+// originals.
+func createPDF(path string, save SaveOptions) (*pdfPageWriter, error) {
+	if err := save.Validate(); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &pdfPageWriter{file: file, offsets: make([]int64, 2), save: save}
+	// The binary comment marks the file as binary for transfer programs
+	if err := w.write([]byte("%PDF-1.5\n%\xe2\xe3\xcf\xd3\n")); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *pdfPageWriter) write(data []byte) error {
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+	return err
+}
+
+// object writes the next object, a dictionary with the given entries and
+// the stream data if it is not nil, and returns its number
+func (w *pdfPageWriter) object(entries string, stream []byte) (int, error) {
+	w.offsets = append(w.offsets, 0)
+	number := len(w.offsets)
+	return number, w.writeObject(number, entries, stream)
+}
+
+func (w *pdfPageWriter) writeObject(number int, entries string, stream []byte) error {
+	w.offsets[number-1] = w.size
+
+	var b bytes.Buffer
+	if stream == nil {
+		fmt.Fprintf(&b, "%d 0 obj\n<< %s >>\nendobj\n", number, entries)
+		return w.write(b.Bytes())
+	}
+	fmt.Fprintf(&b, "%d 0 obj\n<< %s /Length %d >>\nstream\n", number, entries, len(stream))
+	b.Write(stream)
+	b.WriteString("\nendstream\nendobj\n")
+	return w.write(b.Bytes())
+}
+
+// add writes a page
+func (w *pdfPageWriter) add(page pdfOutputPage) error {
+	resolution := page.metadata.Resolution
+	if !resolution.Known() {
+		resolution = Resolution{X: defaultPDFResolution, Y: defaultPDFResolution}
+	}
+	width := float64(page.image.Cols()) * 72 / resolution.X
+	height := float64(page.image.Rows()) * 72 / resolution.Y
+	placement := fmt.Sprintf("q %s 0 0 %s 0 0 cm", pdfNumber(width), pdfNumber(height))
+
+	image, err := w.image(page.image, false)
+	if err != nil {
+		return err
+	}
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "%s /Im0 Do Q\n", placement)
+	xObjects := fmt.Sprintf("/Im0 %d 0 R", image)
+	var properties, fonts string
+
+	// The original covers the processed image while its layer is shown
+	if !page.original.Empty() {
+		original, err := w.image(page.original, true)
+		if err != nil {
+			return err
+		}
+		if w.layer == 0 {
+			if w.layer, err = w.object("/Type /OCG /Name (Original)", nil); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(&content, "/OC /Original BDC %s /Im1 Do Q EMC\n", placement)
+		xObjects += fmt.Sprintf(" /Im1 %d 0 R", original)
+		properties = fmt.Sprintf(" /Properties << /Original %d 0 R >>", w.layer)
+	}
+
+	if len(page.words) > 0 {
+		if w.font == 0 {
+			if w.font, err = w.object("/Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding", nil); err != nil {
+				return err
+			}
+		}
+		writeTextLayer(&content, page.words, resolution, height)
+		fonts = fmt.Sprintf(" /Font << /F0 %d 0 R >>", w.font)
+	}
+
+	contents, err := w.object("/Filter /FlateDecode", deflate(content.Bytes()))
+	if err != nil {
+		return err
+	}
+	number, err := w.object(fmt.Sprintf("/Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /XObject << %s >>%s%s >> /Contents %d 0 R",
+		pdfNumber(width), pdfNumber(height), xObjects, properties, fonts, contents), nil)
+	if err != nil {
+		return err
+	}
+	w.pages = append(w.pages, number)
+	if len(w.pages) == 1 {
+		w.xmp = page.metadata.XMP
+	}
+	return nil
+}
+
+// image writes mat as an image object and returns its number. Originals
+// that are not bilevel are photographs or colour scans and stored as JPEG.
+func (w *pdfPageWriter) image(mat gocv.Mat, original bool) (int, error) {
+	if matDepth(mat) != gocv.MatTypeCV8U {
+		converted, err := convertDepth(mat, gocv.MatTypeCV8U)
+		if err != nil {
+			return 0, err
+		}
+		defer converted.Close()
+		mat = converted
+	}
+	if !mat.IsContinuous() {
+		mat = mat.Clone()
+		defer mat.Close()
+	}
+
+	width, height := mat.Cols(), mat.Rows()
+	entries := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d", width, height)
+	colorSpace := " /ColorSpace /DeviceGray"
+	if mat.Channels() > 1 {
+		colorSpace = " /ColorSpace /DeviceRGB"
+	}
+
+	switch {
+	case isBilevel(mat):
+		entries += fmt.Sprintf(" /ColorSpace /DeviceGray /BitsPerComponent 1 /Filter /CCITTFaxDecode /DecodeParms << /K -1 /Columns %d /Rows %d >>", width, height)
+		return w.object(entries, encodeG4(faxPixels(mat), width, height))
+
+	case original && mat.Channels() != 4:
+		buffer, err := gocv.IMEncodeWithParams(gocv.JPEGFileExt, mat, w.save.openCVParams(".jpg"))
+		if err != nil {
+			return 0, openCVError("encode JPEG", err)
+		}
+		defer buffer.Close()
+		return w.object(entries+colorSpace+" /BitsPerComponent 8 /Filter /DCTDecode", buffer.GetBytes())
+	}
+
+	samples, alpha := pdfSamples(mat)
+	if alpha != nil {
+		mask, err := w.object(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode", width, height), deflate(alpha))
+		if err != nil {
+			return 0, err
+		}
+		entries += fmt.Sprintf(" /SMask %d 0 R", mask)
+	}
+	return w.object(entries+colorSpace+" /BitsPerComponent 8 /Filter /FlateDecode", deflate(samples))
+}
+
+// pdfSamples splits the 8-bit pixels of mat into gray or RGB samples and the
+// alpha channel, nil when mat has none
+func pdfSamples(mat gocv.Mat) (samples, alpha []byte) {
+	pixels := mat.ToBytes()
+	channels := mat.Channels()
+	if channels == 1 {
+		return pixels, nil
+	}
+
+	count := mat.Rows() * mat.Cols()
+	samples = make([]byte, 0, 3*count)
+	if channels == 4 {
+		alpha = make([]byte, 0, count)
+	}
+	for i := 0; i < len(pixels); i += channels {
+		samples = append(samples, pixels[i+2], pixels[i+1], pixels[i])
+		if channels == 4 {
+			alpha = append(alpha, pixels[i+3])
+		}
+	}
+	return samples, alpha
+}
+
+// writeTextLayer adds words to content as invisible text over their place in
+// an image filling a page pageHeight points high. Courier's fixed advance
+// of 0.6 em lets each word be stretched to the width of its box.
+func writeTextLayer(content *bytes.Buffer, words []TextWord, resolution Resolution, pageHeight float64) {
+	scaleX, scaleY := 72/resolution.X, 72/resolution.Y
+	content.WriteString("BT 3 Tr\n")
+	for _, word := range words {
+		count := utf8.RuneCountInString(word.Text)
+		if count == 0 || word.Bounds.Empty() {
+			continue
+		}
+		size := float64(word.Bounds.Dy()) * scaleY
+		stretch := 100 * float64(word.Bounds.Dx()) * scaleX / (float64(count) * 0.6 * size)
+		x := float64(word.Bounds.Min.X) * scaleX
+		y := pageHeight - float64(word.Bounds.Max.Y)*scaleY
+		fmt.Fprintf(content, "/F0 %s Tf %s Tz 1 0 0 1 %s %s Tm %s Tj\n",
+			pdfNumber(size), pdfNumber(stretch), pdfNumber(x), pdfNumber(y), pdfTextString(word.Text))
+	}
+	content.WriteString("ET\n")
+}
+
+// pdfTextString is a literal string of text in WinAnsiEncoding, which
+// matches Latin-1 for the characters kept; others become '?'
+func pdfTextString(text string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r >= 0x20 && r < 0x7f || r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// pdfNumber formats v with at most two decimals
+func pdfNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func deflate(data []byte) []byte {
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	writer.Write(data)
+	writer.Close()
+	return compressed.Bytes()
+}
+
+// Close finishes the document and closes the file
+func (w *pdfPageWriter) Close() error {
+	err := w.finish()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// abort closes the file of a document that will not be finished
+func (w *pdfPageWriter) abort() {
+	w.file.Close()
+}
+
+func (w *pdfPageWriter) finish() error {
+	catalog := "/Type /Catalog /Pages 2 0 R"
+	if len(w.xmp) > 0 {
+		metadata, err := w.object("/Type /Metadata /Subtype /XML", w.xmp)
+		if err != nil {
+			return err
+		}
+		catalog += fmt.Sprintf(" /Metadata %d 0 R", metadata)
+	}
+	if w.layer != 0 {
+		catalog += fmt.Sprintf(" /OCProperties << /OCGs [%[1]d 0 R] /D << /Order [%[1]d 0 R] /OFF [%[1]d 0 R] >> >>", w.layer)
+	}
+	info, err := w.object("/Producer (Image Restoration Suite)", nil)
+	if err != nil {
+		return err
+	}
+
+	kids := make([]string, len(w.pages))
+	for i, page := range w.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	if err := w.writeObject(2, fmt.Sprintf("/Type /Pages /Kids [%s] /Count %d", strings.Join(kids, " "), len(w.pages)), nil); err != nil {
+		return err
+	}
+	if err := w.writeObject(1, catalog, nil); err != nil {
+		return err
+	}
+
+	// Every cross-reference entry is 20 bytes
+	start := w.size
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, info, start)
+	return w.write(b.Bytes())
+}
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"sync/atomic"
 )
 
-func (p *ImagePipeline) ProcessImage() error {
+// ProcessImage reprocesses the full resolution image. A cancelled ctx stops
+// it between and inside transformations, keeping the previous result.
+func (p *ImagePipeline) ProcessImage(ctx context.Context) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
 	p.debugPipeline.Log("ProcessImage: Force reprocessing full resolution image")
-	return p.processImageUnsafe()
+	return p.processImageUnsafe(ctx)
 }
 
 func (p *ImagePipeline) ProcessPreview() error {
@@ -20,10 +23,10 @@ func (p *ImagePipeline) ProcessPreview() error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	return p.processPreviewUnsafe()
+	return p.processPreviewUnsafe(context.Background())
 }
 
-func (p *ImagePipeline) ForcePreviewRegeneration() error {
+func (p *ImagePipeline) ForcePreviewRegeneration(ctx context.Context) error {
 	p.debugPipeline.Log("ForcePreviewRegeneration: Regenerating preview with current parameters")
 
 	p.mutex.Lock()
@@ -33,7 +36,7 @@ func (p *ImagePipeline) ForcePreviewRegeneration() error {
 		return fmt.Errorf("no image loaded")
 	}
 
-	return p.processPreviewUnsafe()
+	return p.processPreviewUnsafe(ctx)
 }
 
 func (p *ImagePipeline) ReprocessPreview() error {
@@ -42,10 +45,10 @@ func (p *ImagePipeline) ReprocessPreview() error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	return p.processPreviewUnsafe()
+	return p.processPreviewUnsafe(context.Background())
 }
 
-func (p *ImagePipeline) processImageUnsafe() (err error) {
+func (p *ImagePipeline) processImageUnsafe(ctx context.Context) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("panic in processImage: %v", r)
@@ -73,11 +76,10 @@ func (p *ImagePipeline) processImageUnsafe() (err error) {
 	}()
 
 	p.debugPipeline.LogProcessStep("creating new processed image")
-	newProcessed := p.originalImage.Clone()
+	start, newProcessed, resolution := p.imageCache.resume(p.transformations, p.bypassed, p.originalImage, p.metadata.Resolution)
 	if newProcessed.Empty() {
 		return fmt.Errorf("failed to clone original for processing")
 	}
-
 	defer func() {
 		if err != nil && !newProcessed.Empty() {
 			newProcessed.Close()
@@ -85,16 +87,44 @@ func (p *ImagePipeline) processImageUnsafe() (err error) {
 	}()
 
 	p.debugPipeline.LogTransformationCount(len(p.transformations))
-	for i, transformation := range p.transformations {
+	if start > 0 {
+		p.debugPipeline.Log(fmt.Sprintf("Reusing cached results of %d of %d steps", start, len(p.transformations)))
+	}
+	for i := start; i < len(p.transformations); i++ {
+		transformation := p.transformations[i]
 		if transformation == nil {
 			return fmt.Errorf("transformation %d is nil", i)
 		}
+		if p.bypassed[transformation] {
+			p.debugPipeline.Log(fmt.Sprintf("Skipping bypassed transformation %d: %s", i, transformation.Name()))
+			p.imageCache.storeBypassed(i, transformation)
+			continue
+		}
+		if ctx.Err() != nil {
+			p.debugPipeline.LogProcessEarlyReturn("cancelled")
+			return ctx.Err()
+		}
+		params := transformation.GetParameters()
 
 		timerName := fmt.Sprintf("transformation_%d_%s", i, transformation.Name())
 		p.debugPipeline.StartTimer(timerName)
 
+		input, converted, convErr := inputForStep(transformation, newProcessed)
+		if convErr != nil {
+			p.debugPipeline.EndTimer(timerName)
+			return &StepError{Index: i, Name: transformation.Name(), Err: convErr}
+		}
+		if converted {
+			p.debugPipeline.Log(fmt.Sprintf("%s takes %s input, converted from %s", transformation.Name(), depthName(matDepth(input)), depthName(matDepth(newProcessed))))
+		}
+
+		ReportStage(ctx, fmt.Sprintf("%s (%d/%d)", transformation.Name(), i+1, len(p.transformations)))
 		before := newProcessed.Clone()
-		result := transformation.Apply(newProcessed)
+		stepCtx := withImageResolution(stepProgress(ctx, i-start, len(p.transformations)-start), resolution)
+		result, applyErr := transformation.Apply(stepCtx, input)
+		if converted {
+			input.Close()
+		}
 		duration := p.debugPipeline.EndTimer(timerName)
 
 		p.debugPipeline.LogTransformationApplied(transformation.Name(), before, result, duration)
@@ -103,26 +133,38 @@ func (p *ImagePipeline) processImageUnsafe() (err error) {
 			before.Close()
 		}
 
-		if result.Empty() {
-			return fmt.Errorf("transformation %s returned empty result", transformation.Name())
+		if ctx.Err() != nil {
+			result.Close()
+			p.debugPipeline.LogProcessEarlyReturn("cancelled")
+			return ctx.Err()
+		}
+		if applyErr == nil && result.Empty() {
+			applyErr = fmt.Errorf("returned empty result")
+		}
+		if applyErr != nil {
+			result.Close()
+			return &StepError{Index: i, Name: transformation.Name(), Err: applyErr}
 		}
 
+		resolution = stepResolution(transformation, resolution, newProcessed, result)
 		if !newProcessed.Empty() {
 			newProcessed.Close()
 		}
 		newProcessed = result
+		p.storeStepUnsafe(&p.imageCache, i, transformation, params, newProcessed, resolution)
 	}
 
 	if !p.processedImage.Empty() {
 		p.processedImage.Close()
 	}
 	p.processedImage = newProcessed
+	p.processedRes = resolution
 
 	p.debugPipeline.LogProcessComplete()
 	return nil
 }
 
-func (p *ImagePipeline) processPreviewUnsafe() (err error) {
+func (p *ImagePipeline) processPreviewUnsafe(ctx context.Context) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("panic in processPreview: %v", r)
@@ -139,6 +181,10 @@ func (p *ImagePipeline) processPreviewUnsafe() (err error) {
 		p.debugPipeline.LogProcessEarlyReturn("original image is empty for preview")
 		return fmt.Errorf("original image is empty")
 	}
+	if p.previewDisabled {
+		p.debugPipeline.LogProcessEarlyReturn("preview disabled")
+		return nil
+	}
 
 	p.debugPipeline.StartTimer("processPreview")
 	defer func() {
@@ -148,27 +194,49 @@ func (p *ImagePipeline) processPreviewUnsafe() (err error) {
 		}
 	}()
 
-	newPreview := p.originalImage.Clone()
+	start, newPreview, resolution := p.previewCache.resume(p.transformations, p.bypassed, p.originalImage, p.metadata.Resolution)
 	if newPreview.Empty() {
 		return fmt.Errorf("failed to clone original for preview")
 	}
-
 	defer func() {
 		if err != nil && !newPreview.Empty() {
 			newPreview.Close()
 		}
 	}()
 
-	for i, transformation := range p.transformations {
+	for i := start; i < len(p.transformations); i++ {
+		transformation := p.transformations[i]
 		if transformation == nil {
 			return fmt.Errorf("preview transformation %d is nil", i)
 		}
+		if p.bypassed[transformation] {
+			p.previewCache.storeBypassed(i, transformation)
+			continue
+		}
+		if ctx.Err() != nil {
+			p.debugPipeline.LogProcessEarlyReturn("preview cancelled")
+			return ctx.Err()
+		}
+		params := transformation.GetParameters()
 
 		timerName := fmt.Sprintf("preview_transformation_%d_%s", i, transformation.Name())
 		p.debugPipeline.StartTimer(timerName)
 
+		input, converted, convErr := inputForStep(transformation, newPreview)
+		if convErr != nil {
+			p.debugPipeline.EndTimer(timerName)
+			return &StepError{Index: i, Name: transformation.Name(), Preview: true, Err: convErr}
+		}
+		if converted {
+			p.debugPipeline.Log(fmt.Sprintf("%s takes %s input, converted from %s", transformation.Name(), depthName(matDepth(input)), depthName(matDepth(newPreview))))
+		}
+
 		before := newPreview.Clone()
-		result := transformation.ApplyPreview(newPreview)
+		stepCtx := withImageResolution(stepProgress(ctx, i-start, len(p.transformations)-start), resolution)
+		result, applyErr := transformation.ApplyPreview(stepCtx, input)
+		if converted {
+			input.Close()
+		}
 		duration := p.debugPipeline.EndTimer(timerName)
 
 		p.debugPipeline.LogTransformationApplied(transformation.Name()+" (preview)", before, result, duration)
@@ -177,14 +245,25 @@ func (p *ImagePipeline) processPreviewUnsafe() (err error) {
 			before.Close()
 		}
 
-		if result.Empty() {
-			return fmt.Errorf("preview transformation %s returned empty result", transformation.Name())
+		if ctx.Err() != nil {
+			result.Close()
+			p.debugPipeline.LogProcessEarlyReturn("preview cancelled")
+			return ctx.Err()
+		}
+		if applyErr == nil && result.Empty() {
+			applyErr = fmt.Errorf("returned empty result")
+		}
+		if applyErr != nil {
+			result.Close()
+			return &StepError{Index: i, Name: transformation.Name(), Preview: true, Err: applyErr}
 		}
 
+		resolution = stepResolution(transformation, resolution, newPreview, result)
 		if !newPreview.Empty() {
 			newPreview.Close()
 		}
 		newPreview = result
+		p.storeStepUnsafe(&p.previewCache, i, transformation, params, newPreview, resolution)
 	}
 
 	if !p.previewImage.Empty() {
@@ -194,3 +273,8 @@ func (p *ImagePipeline) processPreviewUnsafe() (err error) {
 
 	return nil
 }
+
+// stepProgress gives step index of count an equal share of the job progress
+func stepProgress(ctx context.Context, index, count int) context.Context {
+	return ProgressRange(ctx, float64(index)/float64(count), float64(index+1)/float64(count))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const recipeVersion = 1
+
+// PipelineRecipe is the serializable form of a transformation stack
+type PipelineRecipe struct {
+	Version         int          `json:"version" yaml:"version"`
+	Transformations []RecipeStep `json:"transformations" yaml:"transformations"`
+}
+
+// RecipeStep stores one transformation by identifier with its parameters
+type RecipeStep struct {
+	Type       string                 `json:"type" yaml:"type"`
+	Parameters map[string]interface{} `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Bypassed   bool                   `json:"bypassed,omitempty" yaml:"bypassed,omitempty"`
+}
+
+// Recipe captures the current transformation stack in processing order
+func (p *ImagePipeline) Recipe() (PipelineRecipe, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.recipeUnsafe()
+}
+
+func (p *ImagePipeline) recipeUnsafe() (PipelineRecipe, error) {
+	recipe := PipelineRecipe{
+		Version:         recipeVersion,
+		Transformations: make([]RecipeStep, 0, len(p.transformations)),
+	}
+
+	for i, transformation := range p.transformations {
+		id, err := transformationRegistry.IDOf(transformation)
+		if err != nil {
+			return PipelineRecipe{}, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		recipe.Transformations = append(recipe.Transformations, RecipeStep{
+			Type:       id,
+			Parameters: transformation.GetParameters(),
+			Bypassed:   p.bypassed[transformation],
+		})
+	}
+
+	return recipe, nil
+}
+
+// ApplyRecipe replaces the transformation stack with the steps of recipe
+func (p *ImagePipeline) ApplyRecipe(recipe PipelineRecipe) error {
+	transformations, err := recipe.Build(p.config)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.debugPipeline.Log(fmt.Sprintf("Applying recipe with %d steps", len(transformations)))
+	return p.setTransformationsUnsafe(transformations, recipe.bypassedSteps(transformations), "load recipe")
+}
+
+// bypassedSteps maps the transformations built from r to their bypass flag
+func (r PipelineRecipe) bypassedSteps(transformations []Transformation) map[Transformation]bool {
+	bypassed := make(map[Transformation]bool)
+	for i, step := range r.Transformations {
+		if step.Bypassed && i < len(transformations) {
+			bypassed[transformations[i]] = true
+		}
+	}
+	return bypassed
+}
+
+// Build creates new transformations for every step of the recipe. Every
+// step is checked against the registry and its parameter specs, and all
+// problems are reported together.
+func (r PipelineRecipe) Build(config *DebugConfig) ([]Transformation, error) {
+	if r.Version > recipeVersion {
+		return nil, fmt.Errorf("recipe version %d is newer than supported version %d", r.Version, recipeVersion)
+	}
+
+	transformations := make([]Transformation, 0, len(r.Transformations))
+	var problems []error
+
+	for i, step := range r.Transformations {
+		transformation, err := transformationRegistry.New(step.Type, config)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("recipe step %d: %w", i+1, err))
+			continue
+		}
+		transformations = append(transformations, transformation)
+
+		for _, name := range sortedParameterNames(step.Parameters) {
+			err := applyParameters(transformation, map[string]interface{}{name: step.Parameters[name]})
+			if err != nil {
+				problems = append(problems, fmt.Errorf("recipe step %d (%s): %w", i+1, step.Type, err))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, transformation := range transformations {
+			transformation.Close()
+		}
+		return nil, errors.Join(problems...)
+	}
+
+	return transformations, nil
+}
+
+func sortedParameterNames(params map[string]interface{}) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadRecipe reads a recipe file, choosing JSON or YAML by extension
+func LoadRecipe(path string) (PipelineRecipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PipelineRecipe{}, err
+	}
+
+	recipe, err := DecodeRecipe(data, isYAMLRecipe(path))
+	if err != nil {
+		return PipelineRecipe{}, fmt.Errorf("failed to parse recipe %s: %w", filepath.Base(path), err)
+	}
+	return recipe, nil
+}
+
+// SaveRecipe writes recipe to path, choosing JSON or YAML by extension
+func SaveRecipe(path string, recipe PipelineRecipe) error {
+	data, err := EncodeRecipe(recipe, isYAMLRecipe(path))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func DecodeRecipe(data []byte, asYAML bool) (PipelineRecipe, error) {
+	var recipe PipelineRecipe
+	var err error
+	if asYAML {
+		err = yaml.Unmarshal(data, &recipe)
+	} else {
+		err = json.Unmarshal(data, &recipe)
+	}
+	return recipe, err
+}
+
+func EncodeRecipe(recipe PipelineRecipe, asYAML bool) ([]byte, error) {
+	if asYAML {
+		data, err := yaml.Marshal(recipe)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode recipe: %w", err)
+		}
+		return data, nil
+	}
+
+	data, err := json.MarshalIndent(recipe, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode recipe: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func isYAMLRecipe(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
@@ -0,0 +1,66 @@
+package main
+
+import "fmt"
+
+// Resolution is the pixel density of an image in dots per inch. The zero
+// value means the file did not say.
+type Resolution struct {
+	X, Y float64
+}
+
+func (r Resolution) Known() bool {
+	return r.X > 0 && r.Y > 0
+}
+
+func (r Resolution) String() string {
+	if !r.Known() {
+		return "unknown"
+	}
+	if r.X == r.Y {
+		return fmt.Sprintf("%.0f DPI", r.X)
+	}
+	return fmt.Sprintf("%.0fx%.0f DPI", r.X, r.Y)
+}
+
+// scaled is the resolution after resizing by scaleX and scaleY, keeping the
+// physical size of the image
+func (r Resolution) scaled(scaleX, scaleY float64) Resolution {
+	if !r.Known() {
+		return r
+	}
+	return Resolution{X: r.X * scaleX, Y: r.Y * scaleY}
+}
+
+// ifdResolution reads XResolution, YResolution and ResolutionUnit from a TIFF
+// or EXIF IFD
+func ifdResolution(ifd tiffIFD) Resolution {
+	x, y := ifd.find(tagXResolution), ifd.find(tagYResolution)
+	if x == nil || y == nil {
+		return Resolution{}
+	}
+	resolution := Resolution{X: x.rational(), Y: y.rational()}
+
+	unit := uint32(2) // inches unless stated
+	if entry := ifd.find(tagResolutionUnit); entry != nil {
+		unit = entry.uint()
+	}
+	switch unit {
+	case 2:
+	case 3:
+		resolution = Resolution{X: resolution.X * 2.54, Y: resolution.Y * 2.54}
+	default:
+		return Resolution{}
+	}
+	if !resolution.Known() {
+		return Resolution{}
+	}
+	return resolution
+}
+
+// withResolution sets the resolution fields of ifd in dots per inch
+func (ifd tiffIFD) withResolution(resolution Resolution) tiffIFD {
+	return ifd.
+		set(rationalEntry(tagXResolution, resolution.X)).
+		set(rationalEntry(tagYResolution, resolution.Y)).
+		set(shortEntry(tagResolutionUnit, 2))
+}
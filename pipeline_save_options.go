@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// saveFormats are the image formats images are saved in, by extension
+var saveFormats = []struct {
+	ext  string
+	name string
+}{
+	{".png", "PNG"},
+	{".jpg", "JPEG"},
+	{".tif", "TIFF"},
+	{".webp", "WebP"},
+}
+
+// isSaveFormat reports whether ext, lower case with its dot, is a format
+// images are saved in
+func isSaveFormat(ext string) bool {
+	switch ext {
+	case ".jpeg", ".tiff":
+		return true
+	}
+	for _, format := range saveFormats {
+		if format.ext == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// saveFormatName is the name of the format of ext
+func saveFormatName(ext string) string {
+	switch ext {
+	case ".jpeg":
+		ext = ".jpg"
+	case ".tiff":
+		ext = ".tif"
+	}
+	for _, format := range saveFormats {
+		if format.ext == ext {
+			return format.name
+		}
+	}
+	return strings.ToUpper(strings.TrimPrefix(ext, "."))
+}
+
+// JPEG chroma subsampling, as OpenCV's sampling factors
+var jpegSubsamplings = map[string]int{
+	"4:2:0": 0x221111,
+	"4:2:2": 0x211111,
+	"4:4:4": 0x111111,
+}
+
+// TIFF compression schemes, as TIFF Compression tag values
+var tiffCompressions = map[string]int{
+	"none":    1,
+	"lzw":     5,
+	"deflate": 8,
+}
+
+// SaveOptions are the encoder settings of saved images. Each format uses
+// its own fields.
+type SaveOptions struct {
+	JPEGQuality     int    `json:"jpegQuality"` // 0-100
+	JPEGProgressive bool   `json:"jpegProgressive"`
+	JPEGSubsampling string `json:"jpegSubsampling"` // 4:2:0, 4:2:2 or 4:4:4
+	PNGCompression  int    `json:"pngCompression"`  // zlib level 0-9
+	TIFFCompression string `json:"tiffCompression"` // lzw, deflate or none
+	// TIFFGroup4 writes black-and-white TIFFs with CCITT Group 4 instead
+	// of TIFFCompression
+	TIFFGroup4  bool `json:"tiffGroup4"`
+	WebPQuality int  `json:"webpQuality"` // 1-100
+}
+
+func DefaultSaveOptions() SaveOptions {
+	return SaveOptions{
+		JPEGQuality:     95,
+		JPEGSubsampling: "4:2:0",
+		PNGCompression:  6,
+		TIFFCompression: "lzw",
+		TIFFGroup4:      true,
+		WebPQuality:     90,
+	}
+}
+
+func (o SaveOptions) Validate() error {
+	switch {
+	case o.JPEGQuality < 0 || o.JPEGQuality > 100:
+		return &InvalidParameterError{Parameter: "JPEG quality", Value: o.JPEGQuality, Reason: "must be between 0 and 100"}
+	case jpegSubsamplings[o.JPEGSubsampling] == 0:
+		return &InvalidParameterError{Parameter: "JPEG subsampling", Value: o.JPEGSubsampling, Reason: "must be 4:2:0, 4:2:2 or 4:4:4"}
+	case o.PNGCompression < 0 || o.PNGCompression > 9:
+		return &InvalidParameterError{Parameter: "PNG compression", Value: o.PNGCompression, Reason: "must be between 0 and 9"}
+	case tiffCompressions[o.TIFFCompression] == 0:
+		return &InvalidParameterError{Parameter: "TIFF compression", Value: o.TIFFCompression, Reason: "must be lzw, deflate or none"}
+	case o.WebPQuality < 1 || o.WebPQuality > 100:
+		return &InvalidParameterError{Parameter: "WebP quality", Value: o.WebPQuality, Reason: "must be between 1 and 100"}
+	}
+	return nil
+}
+
+// openCVParams are the IMEncode parameters for ext
+func (o SaveOptions) openCVParams(ext string) []int {
+	switch ext {
+	case ".jpg", ".jpeg":
+		params := []int{gocv.IMWriteJpegQuality, o.JPEGQuality, imwriteJpegSamplingFactor, jpegSubsamplings[o.JPEGSubsampling]}
+		if o.JPEGProgressive {
+			params = append(params, gocv.IMWriteJpegProgressive, 1)
+		}
+		return params
+	case ".png":
+		return []int{gocv.IMWritePngCompression, o.PNGCompression}
+	case ".tif", ".tiff":
+		return []int{imwriteTiffCompression, tiffCompressions[o.TIFFCompression]}
+	case ".webp":
+		return []int{gocv.IMWriteWebpQuality, o.WebPQuality}
+	}
+	return nil
+}
+
+// Summary describes the settings used for ext
+func (o SaveOptions) Summary(ext string) string {
+	switch ext {
+	case ".jpg", ".jpeg":
+		summary := fmt.Sprintf("JPEG quality %d, %s", o.JPEGQuality, o.JPEGSubsampling)
+		if o.JPEGProgressive {
+			summary += ", progressive"
+		}
+		return summary
+	case ".png":
+		return fmt.Sprintf("PNG compression %d", o.PNGCompression)
+	case ".tif", ".tiff":
+		summary := "TIFF " + strings.ToUpper(o.TIFFCompression)
+		if o.TIFFGroup4 {
+			summary += ", Group 4 when black-and-white"
+		}
+		return summary
+	case ".webp":
+		return fmt.Sprintf("WebP quality %d", o.WebPQuality)
+	}
+	return strings.TrimPrefix(ext, ".")
+}
@@ -8,11 +8,21 @@ import (
 
 type ImagePipeline struct {
 	originalImage   gocv.Mat
+	metadata        ImageMetadata // of originalImage, from its file
+	processedRes    Resolution    // of processedImage
 	processedImage  gocv.Mat
 	previewImage    gocv.Mat
 	transformations []Transformation
+	paramSnapshots  map[Transformation]map[string]interface{}
+	bypassed        map[Transformation]bool
+	imageCache      stepCache
+	previewCache    stepCache
+	cacheBudget     int64
+	history         *PipelineHistory
 	debugPipeline   *DebugPipeline
+	config          *DebugConfig
 	initialized     int32
+	previewDisabled bool
 	mutex           sync.RWMutex
 	processingMutex sync.Mutex
 }
@@ -20,7 +30,12 @@ type ImagePipeline struct {
 func NewImagePipeline(config *DebugConfig) *ImagePipeline {
 	return &ImagePipeline{
 		transformations: make([]Transformation, 0),
+		paramSnapshots:  make(map[Transformation]map[string]interface{}),
+		bypassed:        make(map[Transformation]bool),
+		history:         NewPipelineHistory(defaultHistoryLimit),
 		debugPipeline:   NewDebugPipeline(config),
+		config:          config,
+		cacheBudget:     defaultCacheBudget,
 		originalImage:   gocv.NewMat(),
 		processedImage:  gocv.NewMat(),
 		previewImage:    gocv.NewMat(),
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+// TIFF tags that locate the image data of a page
+const (
+	tagNewSubfileType  = 254
+	tagStripOffsets    = 273
+	tagStripByteCounts = 279
+	tagTileOffsets     = 324
+	tagTileByteCounts  = 325
+)
+
+// tiffPageWriter collects pages encoded as single-page TIFFs into one
+// multi-page file. Each page's image data and IFD are appended as the page
+// is added, so only one page is held in memory at a time.
+type tiffPageWriter struct {
+	file     *os.File
+	size     int64
+	nextLink int64 // where the offset of the next page's IFD goes
+}
+
+func createTIFFPages(path string) (*tiffPageWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write([]byte{'I', 'I', 42, 0, 0, 0, 0, 0}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &tiffPageWriter{file: file, size: 8, nextLink: 4}, nil
+}
+
+// add appends the first page of the encoded TIFF page
+func (w *tiffPageWriter) add(page []byte) error {
+	r := bytes.NewReader(page)
+	order, offset, err := readTIFFHeader(r)
+	if err != nil {
+		return err
+	}
+	// The image data is copied as it is, and samples wider than a byte
+	// follow the byte order of the file they are in
+	if order != binary.LittleEndian {
+		return fmt.Errorf("page is a big-endian TIFF, the multi-page file is little-endian")
+	}
+	ifd, _, err := readIFD(r, order, offset, 0)
+	if err != nil {
+		return err
+	}
+
+	offsetsTag, countsTag := uint16(tagStripOffsets), uint16(tagStripByteCounts)
+	if ifd.find(tagTileOffsets) != nil {
+		offsetsTag, countsTag = tagTileOffsets, tagTileByteCounts
+	}
+	offsets, counts := ifd.find(offsetsTag), ifd.find(countsTag)
+	if offsets == nil || counts == nil || offsets.count != counts.count {
+		return fmt.Errorf("page has no image data")
+	}
+
+	// The image data goes first, then the IFD pointing at its new place
+	var data []byte
+	moved := make([]byte, 0, 4*offsets.count)
+	for i := 0; i < int(offsets.count); i++ {
+		start, length := int(offsets.uintAt(i)), int(counts.uintAt(i))
+		if start+length > len(page) {
+			return fmt.Errorf("page data outside the file")
+		}
+		if len(data)%2 == 1 {
+			data = append(data, 0)
+		}
+		moved = binary.LittleEndian.AppendUint32(moved, uint32(w.size)+uint32(len(data)))
+		data = append(data, page[start:start+length]...)
+	}
+	ifd = ifd.set(tiffEntry{tag: offsetsTag, kind: tiffLong, count: offsets.count, value: moved})
+	ifd = ifd.set(longEntry(tagNewSubfileType, 2)) // one page of a multi-page image
+
+	if len(data)%2 == 1 {
+		data = append(data, 0)
+	}
+	data, ifdOffset := appendIFD(data, uint32(w.size), binary.LittleEndian, ifd, 0)
+	if w.size+int64(len(data)) > math.MaxUint32 {
+		return fmt.Errorf("multi-page TIFF would exceed 4 GB")
+	}
+
+	if _, err := w.file.WriteAt(data, w.size); err != nil {
+		return err
+	}
+	if _, err := w.file.WriteAt(binary.LittleEndian.AppendUint32(nil, ifdOffset), w.nextLink); err != nil {
+		return err
+	}
+	w.size += int64(len(data))
+	w.nextLink = int64(ifdOffset) + 2 + 12*int64(len(ifd))
+	return nil
+}
+
+func (w *tiffPageWriter) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testPage is a one-strip 8-bit gray TIFF of the given pixels
+func testPage(order binary.ByteOrder, width, height uint32, pixels []byte) []byte {
+	return testTIFF(order, pixels, []testField{
+		testLong(order, tagImageWidth, width),
+		testLong(order, tagImageLength, height),
+		testShort(order, tagBitsPerSample, 8),
+		testShort(order, tagPhotometric, 1),
+		testLong(order, tagStripOffsets, 8),
+		testShort(order, tagSamplesPerPixel, 1),
+		testLong(order, tagRowsPerStrip, height),
+		testLong(order, tagStripByteCounts, uint32(len(pixels))),
+	})
+}
+
+func TestTIFFPageWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pages.tif")
+	pages, err := createTIFFPages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pages.Close()
+
+	contents := [][]byte{{1, 2, 3, 4, 5, 6}, {7, 8, 9}}
+	if err := pages.add(testPage(binary.LittleEndian, 3, 2, contents[0])); err != nil {
+		t.Fatal(err)
+	}
+	if err := pages.add(testPage(binary.LittleEndian, 3, 1, contents[1])); err != nil {
+		t.Fatal(err)
+	}
+	if err := pages.add(testPage(binary.BigEndian, 3, 1, contents[1])); err == nil {
+		t.Error("big-endian page added to a little-endian file")
+	}
+	if err := pages.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(data)
+	order, offset, err := readTIFFHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range contents {
+		if offset == 0 {
+			t.Fatalf("file ends after %d pages, want %d", i, len(contents))
+		}
+		ifd, next, err := readIFD(r, order, offset, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		strip, length := ifd.find(tagStripOffsets).uint(), ifd.find(tagStripByteCounts).uint()
+		if got := data[strip : strip+length]; !bytes.Equal(got, want) {
+			t.Errorf("page %d holds %v, want %v", i+1, got, want)
+		}
+		if subfile := ifd.find(tagNewSubfileType); subfile == nil || subfile.uint() != 2 {
+			t.Errorf("page %d NewSubfileType %v, want 2", i+1, subfile)
+		}
+		offset = next
+	}
+	if offset != 0 {
+		t.Errorf("more than %d pages in the file", len(contents))
+	}
+}
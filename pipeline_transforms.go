@@ -1,6 +1,9 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 func (p *ImagePipeline) AddTransformation(transformation Transformation) error {
 	p.mutex.Lock()
@@ -15,13 +18,27 @@ func (p *ImagePipeline) AddTransformation(transformation Transformation) error {
 		return fmt.Errorf("transformation is nil")
 	}
 
-	p.transformations = append(p.transformations, transformation)
+	p.commitParameterChangesUnsafe()
 
-	if err := p.processImageUnsafe(); err != nil {
-		p.transformations = p.transformations[:len(p.transformations)-1]
+	index := len(p.transformations)
+	if err := p.insertTransformationUnsafe(index, transformation); err != nil {
+		delete(p.bypassed, transformation)
+		transformation.Close()
+		return err
+	}
+
+	if err := p.processImageUnsafe(context.Background()); err != nil {
+		p.transformations = p.transformations[:index]
+		delete(p.paramSnapshots, transformation)
+		delete(p.bypassed, transformation)
+		transformation.Close()
 		return fmt.Errorf("failed to process image after adding transformation: %w", err)
 	}
-	if err := p.processPreviewUnsafe(); err != nil {
+
+	step, err := p.captureStep(transformation)
+	p.recordUnsafe(&addCommand{index: index, step: step}, err)
+
+	if err := p.processPreviewUnsafe(context.Background()); err != nil {
 		return fmt.Errorf("failed to process preview after adding transformation: %w", err)
 	}
 
@@ -33,16 +50,19 @@ func (p *ImagePipeline) RemoveTransformation(index int) error {
 	defer p.mutex.Unlock()
 
 	if index >= 0 && index < len(p.transformations) {
-		if p.transformations[index] != nil {
-			p.transformations[index].Close()
+		p.commitParameterChangesUnsafe()
+
+		step, captureErr := p.captureStep(p.transformations[index])
+		if err := p.removeTransformationUnsafe(index); err != nil {
+			return err
 		}
-		p.transformations = append(p.transformations[:index], p.transformations[index+1:]...)
+		p.recordUnsafe(&removeCommand{index: index, step: step}, captureErr)
 
 		if p.HasImageUnsafe() {
-			if err := p.processImageUnsafe(); err != nil {
+			if err := p.processImageUnsafe(context.Background()); err != nil {
 				return fmt.Errorf("failed to process image after removing transformation: %w", err)
 			}
-			if err := p.processPreviewUnsafe(); err != nil {
+			if err := p.processPreviewUnsafe(context.Background()); err != nil {
 				return fmt.Errorf("failed to process preview after removing transformation: %w", err)
 			}
 		}
@@ -55,15 +75,192 @@ func (p *ImagePipeline) ClearTransformations() {
 	defer p.mutex.Unlock()
 
 	p.debugPipeline.Log("Clearing all transformations")
+	p.commitParameterChangesUnsafe()
+
+	if len(p.transformations) > 0 {
+		before, err := p.captureSteps(p.transformations)
+		p.recordUnsafe(&replaceCommand{description: "reset transformations", before: before}, err)
+	}
+	p.replaceTransformationsUnsafe(nil)
+
+	if p.HasImageUnsafe() {
+		p.processImageUnsafe(context.Background())
+		p.processPreviewUnsafe(context.Background())
+	}
+}
+
+// SetTransformations replaces the whole transformation stack. Unlike
+// AddTransformation it does not require an image to be loaded, so a stack can
+// be configured once and reused for many images.
+func (p *ImagePipeline) SetTransformations(transformations []Transformation) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i, transformation := range transformations {
+		if transformation == nil {
+			return fmt.Errorf("transformation %d is nil", i)
+		}
+	}
+
+	return p.setTransformationsUnsafe(transformations, nil, "replace transformations")
+}
+
+// setTransformationsUnsafe replaces the stack as one undoable edit. bypassed
+// marks steps of the new stack that start out disabled.
+func (p *ImagePipeline) setTransformationsUnsafe(transformations []Transformation, bypassed map[Transformation]bool, description string) error {
+	p.debugPipeline.Log(fmt.Sprintf("Replacing transformation stack with %d transformations", len(transformations)))
+	p.commitParameterChangesUnsafe()
+
+	before, err := p.captureSteps(p.transformations)
+	p.replaceTransformationsUnsafe(transformations)
+	for transformation, disabled := range bypassed {
+		if disabled {
+			p.bypassed[transformation] = true
+		}
+	}
+
+	var after []stepState
+	if err == nil {
+		after, err = p.captureSteps(p.transformations)
+	}
+	p.recordUnsafe(&replaceCommand{description: description, before: before, after: after}, err)
+
+	if p.HasImageUnsafe() {
+		if err := p.processImageUnsafe(context.Background()); err != nil {
+			return fmt.Errorf("failed to process image after replacing transformations: %w", err)
+		}
+		if err := p.processPreviewUnsafe(context.Background()); err != nil {
+			return fmt.Errorf("failed to process preview after replacing transformations: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// MoveTransformation moves the step at from to position to, shifting the
+// steps in between. Parameters and the bypass flag move with the step.
+func (p *ImagePipeline) MoveTransformation(from, to int) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if from == to {
+		return nil
+	}
+
+	p.commitParameterChangesUnsafe()
+	if err := p.moveTransformationUnsafe(from, to); err != nil {
+		return err
+	}
+	p.debugPipeline.Log(fmt.Sprintf("Moved transformation %d to %d", from, to))
+	p.recordUnsafe(&moveCommand{from: from, to: to}, nil)
+
+	return p.reprocessUnsafe()
+}
+
+// SetTransformationBypassed enables or disables a step without removing it.
+// Bypassed steps keep their parameters and are skipped during processing.
+func (p *ImagePipeline) SetTransformationBypassed(index int, bypassed bool) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if index < 0 || index >= len(p.transformations) {
+		return fmt.Errorf("transformation index %d out of range", index)
+	}
+	if p.bypassed[p.transformations[index]] == bypassed {
+		return nil
+	}
+
+	p.debugPipeline.Log(fmt.Sprintf("Setting transformation %d bypassed: %t", index, bypassed))
+	p.commitParameterChangesUnsafe()
+	p.setBypassedUnsafe(index, bypassed)
+	p.recordUnsafe(&bypassCommand{index: index, bypassed: bypassed}, nil)
+
+	return p.reprocessUnsafe()
+}
+
+func (p *ImagePipeline) IsTransformationBypassed(index int) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if index < 0 || index >= len(p.transformations) {
+		return false
+	}
+	return p.bypassed[p.transformations[index]]
+}
+
+// SetPreviewEnabled controls whether the preview image is regenerated.
+// Headless callers only need the full resolution result.
+func (p *ImagePipeline) SetPreviewEnabled(enabled bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.previewDisabled = !enabled
+}
+
+func (p *ImagePipeline) insertTransformationUnsafe(index int, transformation Transformation) error {
+	if index < 0 || index > len(p.transformations) {
+		return fmt.Errorf("transformation index %d out of range", index)
+	}
+
+	p.transformations = append(p.transformations, nil)
+	copy(p.transformations[index+1:], p.transformations[index:])
+	p.transformations[index] = transformation
+	p.snapshotParametersUnsafe(transformation)
+	return nil
+}
+
+func (p *ImagePipeline) removeTransformationUnsafe(index int) error {
+	if index < 0 || index >= len(p.transformations) {
+		return fmt.Errorf("transformation index %d out of range", index)
+	}
+
+	if p.transformations[index] != nil {
+		delete(p.paramSnapshots, p.transformations[index])
+		delete(p.bypassed, p.transformations[index])
+		p.transformations[index].Close()
+	}
+	p.transformations = append(p.transformations[:index], p.transformations[index+1:]...)
+	return nil
+}
+
+func (p *ImagePipeline) replaceTransformationsUnsafe(transformations []Transformation) {
 	for _, transform := range p.transformations {
 		if transform != nil {
+			delete(p.bypassed, transform)
 			transform.Close()
 		}
 	}
-	p.transformations = make([]Transformation, 0)
 
-	if p.HasImageUnsafe() {
-		p.processImageUnsafe()
-		p.processPreviewUnsafe()
+	p.transformations = append(make([]Transformation, 0, len(transformations)), transformations...)
+	p.paramSnapshots = make(map[Transformation]map[string]interface{}, len(transformations))
+	for _, transformation := range p.transformations {
+		p.snapshotParametersUnsafe(transformation)
 	}
 }
+
+func (p *ImagePipeline) moveTransformationUnsafe(from, to int) error {
+	if from < 0 || from >= len(p.transformations) || to < 0 || to >= len(p.transformations) {
+		return fmt.Errorf("cannot move transformation %d to %d: index out of range", from, to)
+	}
+
+	transformation := p.transformations[from]
+	if from < to {
+		copy(p.transformations[from:to], p.transformations[from+1:to+1])
+	} else {
+		copy(p.transformations[to+1:from+1], p.transformations[to:from])
+	}
+	p.transformations[to] = transformation
+	return nil
+}
+
+func (p *ImagePipeline) setBypassedUnsafe(index int, bypassed bool) error {
+	if index < 0 || index >= len(p.transformations) {
+		return fmt.Errorf("transformation index %d out of range", index)
+	}
+	if bypassed {
+		p.bypassed[p.transformations[index]] = true
+	} else {
+		delete(p.bypassed, p.transformations[index])
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"gocv.io/x/gocv"
+)
+
+func (g *Grayscale) applyGrayscale(ctx context.Context, src gocv.Mat) (gocv.Mat, error) {
+	if src.Empty() {
+		return gocv.NewMat(), fmt.Errorf("input image is empty")
+	}
+
+	g.paramMutex.RLock()
+	method := g.method
+	background := float64(g.background)
+	g.paramMutex.RUnlock()
+
+	channels := src.Channels()
+	if channels == 1 {
+		ReportProgress(ctx, 1.0)
+		return src.Clone(), nil
+	}
+	g.debugImage.LogColorConversion(fmt.Sprintf("%d channels", channels), "Grayscale ("+method+")")
+
+	// Samples of every depth are mixed as float in 0..1
+	unit, err := convertDepth(src, gocv.MatTypeCV32F)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	defer unit.Close()
+	pixels, err := unit.DataPtrFloat32()
+	if err != nil {
+		return gocv.NewMat(), openCVError("read pixels", err)
+	}
+	background /= 255
+
+	// BGR weights; luminance uses the Rec. 601 weights of OpenCV's BGR2GRAY
+	weightB, weightG, weightR := 0.114, 0.587, 0.299
+	if method == grayscaleAverage {
+		weightB, weightG, weightR = 1.0/3, 1.0/3, 1.0/3
+	}
+
+	rows, cols := src.Rows(), src.Cols()
+	gray := gocv.NewMatWithSize(rows, cols, gocv.MatTypeCV32F)
+	defer gray.Close()
+	output, err := gray.DataPtrFloat32()
+	if err != nil {
+		return gocv.NewMat(), openCVError("write pixels", err)
+	}
+
+	for y := 0; y < rows; y++ {
+		if y%64 == 0 {
+			if err := ctx.Err(); err != nil {
+				return gocv.NewMat(), err
+			}
+			ReportProgress(ctx, float64(y)/float64(rows))
+		}
+
+		for x := 0; x < cols; x++ {
+			i := (y*cols + x) * channels
+			value := weightB*float64(pixels[i]) + weightG*float64(pixels[i+1]) + weightR*float64(pixels[i+2])
+			if channels == 4 {
+				alpha := float64(pixels[i+3])
+				value = value*alpha + background*(1-alpha)
+			}
+			output[y*cols+x] = float32(value)
+		}
+	}
+
+	ReportProgress(ctx, 1.0)
+	return convertDepth(gray, matDepth(src))
+}
@@ -0,0 +1,42 @@
+package main
+
+var grayscaleParameters = []ParameterSpec{
+	{
+		Name: "method", Label: "Method", Type: ParameterEnum,
+		Values: []string{grayscaleLuminance, grayscaleAverage}, Default: grayscaleLuminance,
+		Help: "luminance weights green most, as the eye does; average weighs blue, green and red equally",
+	},
+	{
+		Name: "background", Label: "Background", Type: ParameterInt,
+		Min: 0, Max: 255, Step: 1, Default: 255,
+		Help: "Gray level that transparent areas are flattened onto",
+	},
+}
+
+func (g *Grayscale) ParameterSpecs() []ParameterSpec {
+	return grayscaleParameters
+}
+
+func (g *Grayscale) GetParameters() map[string]interface{} {
+	g.paramMutex.RLock()
+	defer g.paramMutex.RUnlock()
+
+	return map[string]interface{}{
+		"method":     g.method,
+		"background": g.background,
+	}
+}
+
+func (g *Grayscale) SetParameters(params map[string]interface{}) {
+	g.paramMutex.Lock()
+	defer g.paramMutex.Unlock()
+
+	for name, value := range validParameters(grayscaleParameters, params) {
+		switch name {
+		case "method":
+			g.method = value.(string)
+		case "background":
+			g.background = value.(int)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	grayscaleLuminance = "luminance"
+	grayscaleAverage   = "average"
+)
+
+// Grayscale converts colour and BGRA images to a single channel. Scaling
+// and the binarizations keep or handle colour themselves, so this is the
+// explicit step for pipelines that want gray output.
+type Grayscale struct {
+	debugImage *DebugImage
+
+	paramMutex sync.RWMutex
+	method     string
+	background int
+}
+
+func init() {
+	RegisterTransformation(TransformationInfo{
+		ID:          "grayscale",
+		DisplayName: "Grayscale",
+		Category:    "Color",
+		Description: "Convert to a single gray channel, flattening transparency onto a background",
+		Factory: func(config *DebugConfig) Transformation {
+			return NewGrayscale(config)
+		},
+	})
+}
+
+func NewGrayscale(config *DebugConfig) *Grayscale {
+	g := &Grayscale{
+		debugImage: NewDebugImage(config),
+	}
+	g.SetParameters(defaultParameters(grayscaleParameters))
+	return g
+}
+
+func (g *Grayscale) Name() string {
+	return "Grayscale"
+}
+
+func (g *Grayscale) Close() {
+	// No resources to cleanup
+}
+
+func (g *Grayscale) SupportedDepths() []gocv.MatType {
+	return allDepths
+}
+
+func (g *Grayscale) Apply(ctx context.Context, src gocv.Mat) (gocv.Mat, error) {
+	return g.applyGrayscale(ctx, src)
+}
+
+func (g *Grayscale) ApplyPreview(ctx context.Context, src gocv.Mat) (gocv.Mat, error) {
+	return g.applyGrayscale(ctx, src)
+}
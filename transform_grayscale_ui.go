@@ -0,0 +1,13 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+)
+
+func (g *Grayscale) GetParametersWidget(onParameterChanged func()) fyne.CanvasObject {
+	return newParameterForm(g, g.debugImage, func(string) {
+		if onParameterChanged != nil {
+			onParameterChanged()
+		}
+	}).content
+}
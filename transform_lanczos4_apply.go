@@ -8,83 +8,97 @@ import (
 	"gocv.io/x/gocv"
 )
 
-func (l *Lanczos4Transform) applyLanczos4(src gocv.Mat, scale float64) gocv.Mat {
+func (l *Lanczos4Transform) applyLanczos4(src gocv.Mat, settings lanczos4Settings, scaleX, scaleY float64) (gocv.Mat, error) {
 	if src.Empty() {
 		l.debugImage.LogAlgorithmStep("Lanczos4", "ERROR: Input matrix is empty")
-		return gocv.NewMat()
+		return gocv.NewMat(), fmt.Errorf("input image is empty")
 	}
 
-	if scale <= 0 || math.IsInf(scale, 0) || math.IsNaN(scale) {
-		l.debugImage.LogAlgorithmStep("Lanczos4", fmt.Sprintf("ERROR: Invalid scale factor: %.3f", scale))
-		return gocv.NewMat()
+	for _, scale := range []float64{scaleX, scaleY} {
+		if scale <= 0 || math.IsInf(scale, 0) || math.IsNaN(scale) {
+			l.debugImage.LogAlgorithmStep("Lanczos4", fmt.Sprintf("ERROR: Invalid scale factor: %.3f", scale))
+			return gocv.NewMat(), &InvalidParameterError{Parameter: "scaleFactor", Value: scale, Reason: "must be a positive number"}
+		}
 	}
 
-	l.debugImage.LogAlgorithmStep("Lanczos4", fmt.Sprintf("Input: %dx%d, scale: %.2f", src.Cols(), src.Rows(), scale))
+	l.debugImage.LogAlgorithmStep("Lanczos4", fmt.Sprintf("Input: %dx%d, %d channels, scale: %.3fx%.3f", src.Cols(), src.Rows(), src.Channels(), scaleX, scaleY))
 
-	working := gocv.NewMat()
-	defer working.Close()
+	if src.Cols() <= 0 || src.Rows() <= 0 {
+		l.debugImage.LogAlgorithmStep("Lanczos4", "ERROR: Invalid input dimensions")
+		return gocv.NewMat(), &DimensionLimitError{Width: src.Cols(), Height: src.Rows(), What: "input"}
+	}
 
-	if src.Channels() > 1 {
-		l.debugImage.LogColorConversion("BGR", "Grayscale")
-		err := gocv.CvtColor(src, &working, gocv.ColorBGRToGray)
+	// Every channel is resampled. Linear light and alpha need float samples;
+	// other images are resized as they are.
+	floatSamples := settings.linearLight || src.Channels() == 4
+	var working gocv.Mat
+	if floatSamples {
+		l.debugImage.LogAlgorithmStep("Lanczos4", fmt.Sprintf("Resampling in float (linear light: %v, premultiplied alpha: %v)", settings.linearLight, src.Channels() == 4))
+		var err error
+		working, err = toResamplingSpace(src, settings.linearLight)
 		if err != nil {
 			l.debugImage.LogError(err)
-			return gocv.NewMat()
+			return gocv.NewMat(), err
 		}
 	} else {
 		working = src.Clone()
 	}
+	defer working.Close()
 
 	l.debugImage.LogMatInfo("input_working", working)
 
-	if working.Cols() <= 0 || working.Rows() <= 0 {
-		l.debugImage.LogAlgorithmStep("Lanczos4", "ERROR: Invalid input dimensions")
-		return gocv.NewMat()
-	}
-
-	filtered := l.applyPreFilter(working)
+	filtered := settings.filters.applyPre(l.debugImage, working)
 	defer filtered.Close()
 
-	newWidth := int(math.Round(float64(filtered.Cols()) * scale))
-	newHeight := int(math.Round(float64(filtered.Rows()) * scale))
-
-	if newWidth <= 0 || newHeight <= 0 {
-		l.debugImage.LogAlgorithmStep("Lanczos4", fmt.Sprintf("ERROR: Invalid target dimensions: %dx%d", newWidth, newHeight))
-		return gocv.NewMat()
-	}
+	newWidth := int(math.Round(float64(filtered.Cols()) * scaleX))
+	newHeight := int(math.Round(float64(filtered.Rows()) * scaleY))
 
 	maxDimension := 32768
-	if newWidth > maxDimension || newHeight > maxDimension {
-		l.debugImage.LogAlgorithmStep("Lanczos4", fmt.Sprintf("ERROR: Target dimensions too large: %dx%d (max: %d)", newWidth, newHeight, maxDimension))
-		return gocv.NewMat()
+	if err := checkDimensions("target", newWidth, newHeight, maxDimension); err != nil {
+		l.debugImage.LogAlgorithmStep("Lanczos4", fmt.Sprintf("ERROR: Invalid target dimensions: %dx%d (max: %d)", newWidth, newHeight, maxDimension))
+		return gocv.NewMat(), err
 	}
 
 	l.debugImage.LogAlgorithmStep("Lanczos4", fmt.Sprintf("Target dimensions: %dx%d", newWidth, newHeight))
 
 	var result gocv.Mat
 
-	if l.useIterative && scale < 0.5 {
-		result = l.iterativeLanczos4(filtered, newWidth, newHeight)
+	if settings.useIterative && math.Min(scaleX, scaleY) < 0.5 {
+		var err error
+		result, err = l.iterativeLanczos4(filtered, newWidth, newHeight)
+		if err != nil {
+			return gocv.NewMat(), err
+		}
 	} else {
 		result = gocv.NewMat()
 		err := gocv.Resize(filtered, &result, image.Point{X: newWidth, Y: newHeight}, 0, 0, gocv.InterpolationLanczos4)
 		if err != nil {
 			l.debugImage.LogError(err)
 			result.Close()
-			return gocv.NewMat()
+			return gocv.NewMat(), openCVError("Lanczos4 resize", err)
 		}
 	}
 
 	if result.Empty() {
 		l.debugImage.LogAlgorithmStep("Lanczos4", "ERROR: Scaling operation failed")
-		return gocv.NewMat()
+		return gocv.NewMat(), openCVError("Lanczos4 resize", nil)
+	}
+
+	if floatSamples {
+		converted, err := fromResamplingSpace(result, settings.linearLight, matDepth(src))
+		result.Close()
+		if err != nil {
+			l.debugImage.LogError(err)
+			return gocv.NewMat(), err
+		}
+		result = converted
 	}
 
-	final := l.applyPostFilter(result)
+	final := settings.filters.applyPost(l.debugImage, result)
 	result.Close()
 
 	l.debugImage.LogMatInfo("final_result", final)
 	l.debugImage.LogAlgorithmStep("Lanczos4", "Scaling completed successfully")
 
-	return final
+	return final, nil
 }
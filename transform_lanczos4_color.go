@@ -0,0 +1,185 @@
+package main
+
+import (
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// srgbToLinear decodes every 8-bit sRGB value to linear light in 0..1
+var srgbToLinear = func() (table [256]float32) {
+	for i := range table {
+		table[i] = float32(decodeSRGB(float64(i) / 255))
+	}
+	return table
+}()
+
+// linearToSRGB encodes linear light quantized to 16 bits, fine enough that
+// neighbouring dark sRGB values stay apart
+var linearToSRGB = func() (table [65536]uint8) {
+	for i := range table {
+		table[i] = uint8(math.Round(encodeSRGB(float64(i)/65535) * 255))
+	}
+	return table
+}()
+
+// srgb16ToLinear decodes every 16-bit sRGB value to linear light in 0..1
+var srgb16ToLinear = func() (table [65536]float32) {
+	for i := range table {
+		table[i] = float32(decodeSRGB(float64(i) / 65535))
+	}
+	return table
+}()
+
+func decodeSRGB(value float64) float64 {
+	if value <= 0.04045 {
+		return value / 12.92
+	}
+	return math.Pow((value+0.055)/1.055, 2.4)
+}
+
+func encodeSRGB(value float64) float64 {
+	if value <= 0.0031308 {
+		return value * 12.92
+	}
+	return 1.055*math.Pow(value, 1/2.4) - 0.055
+}
+
+// toResamplingSpace converts an 8-bit, 16-bit or float image to float
+// samples in 0..1. The colour channels are decoded to linear light when
+// linear is set, and multiplied by alpha in 4 channel images so transparent
+// pixels do not bleed their colour into their neighbours.
+func toResamplingSpace(src gocv.Mat, linear bool) (gocv.Mat, error) {
+	depth := matDepth(src)
+	result, err := convertDepth(src, gocv.MatTypeCV32F)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	samples, err := result.DataPtrFloat32()
+	if err != nil {
+		result.Close()
+		return gocv.NewMat(), openCVError("read pixels", err)
+	}
+
+	// Integer samples are decoded through the tables, which are exact for them
+	decode := func(value float32) float32 { return float32(decodeSRGB(float64(value))) }
+	switch depth {
+	case gocv.MatTypeCV8U:
+		decode = func(value float32) float32 { return srgbToLinear[int(value*255+0.5)] }
+	case gocv.MatTypeCV16U:
+		decode = func(value float32) float32 { return srgb16ToLinear[int(value*65535+0.5)] }
+	}
+
+	channels := src.Channels()
+	colour := colourChannels(channels)
+	if !linear && colour == channels {
+		return result, nil
+	}
+	for i := 0; i < len(samples); i += channels {
+		alpha := float32(1)
+		if colour < channels {
+			alpha = clampUnit(samples[i+colour])
+			samples[i+colour] = alpha
+		}
+		for c := 0; c < colour; c++ {
+			value := samples[i+c]
+			if linear {
+				value = decode(clampUnit(value))
+			}
+			samples[i+c] = value * alpha
+		}
+	}
+	return result, nil
+}
+
+// fromResamplingSpace reverses toResamplingSpace into depth, clipping the
+// overshoot of the Lanczos kernel.
+func fromResamplingSpace(src gocv.Mat, linear bool, depth gocv.MatType) (gocv.Mat, error) {
+	unit := src.Clone()
+	defer unit.Close()
+	samples, err := unit.DataPtrFloat32()
+	if err != nil {
+		return gocv.NewMat(), openCVError("read pixels", err)
+	}
+
+	// 8-bit output only needs the precision of the table
+	encode := func(value float32) float32 { return float32(encodeSRGB(float64(value))) }
+	if depth == gocv.MatTypeCV8U {
+		encode = func(value float32) float32 { return float32(linearToSRGB[int(value*65535+0.5)]) / 255 }
+	}
+
+	channels := src.Channels()
+	colour := colourChannels(channels)
+	for i := 0; i < len(samples); i += channels {
+		alpha := float32(1)
+		if colour < channels {
+			alpha = clampUnit(samples[i+colour])
+			samples[i+colour] = alpha
+		}
+		for c := 0; c < colour; c++ {
+			value := samples[i+c]
+			if alpha < 1 {
+				if alpha > 0 {
+					value /= alpha
+				} else {
+					value = 0
+				}
+			}
+			value = clampUnit(value)
+			if linear {
+				value = encode(value)
+			}
+			samples[i+c] = value
+		}
+	}
+	return convertDepth(unit, depth)
+}
+
+// withoutAlpha runs filter on the colour channels of a BGRA image and puts
+// the alpha channel back unchanged; other images are filtered whole.
+func withoutAlpha(src gocv.Mat, filter func(gocv.Mat) gocv.Mat) gocv.Mat {
+	if src.Channels() != 4 {
+		return filter(src)
+	}
+
+	planes := gocv.Split(src)
+	defer func() {
+		for _, plane := range planes {
+			plane.Close()
+		}
+	}()
+
+	colour := gocv.NewMat()
+	defer colour.Close()
+	if err := gocv.Merge(planes[:3], &colour); err != nil {
+		return src.Clone()
+	}
+	filtered := filter(colour)
+	defer filtered.Close()
+
+	filteredPlanes := gocv.Split(filtered)
+	defer func() {
+		for _, plane := range filteredPlanes {
+			plane.Close()
+		}
+	}()
+
+	result := gocv.NewMat()
+	if err := gocv.Merge(append(filteredPlanes, planes[3]), &result); err != nil {
+		result.Close()
+		return src.Clone()
+	}
+	return result
+}
+
+// colourChannels is the number of channels that are not alpha
+func colourChannels(channels int) int {
+	if channels == 4 {
+		return 3
+	}
+	return channels
+}
+
+func clampUnit(value float32) float32 {
+	return float32(math.Max(0, math.Min(1, float64(value))))
+}
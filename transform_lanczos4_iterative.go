@@ -8,9 +8,12 @@ import (
 	"gocv.io/x/gocv"
 )
 
-func (l *Lanczos4Transform) iterativeLanczos4(src gocv.Mat, targetWidth, targetHeight int) gocv.Mat {
-	if src.Empty() || targetWidth <= 0 || targetHeight <= 0 {
-		return gocv.NewMat()
+func (l *Lanczos4Transform) iterativeLanczos4(src gocv.Mat, targetWidth, targetHeight int) (gocv.Mat, error) {
+	if src.Empty() {
+		return gocv.NewMat(), fmt.Errorf("input image is empty")
+	}
+	if targetWidth <= 0 || targetHeight <= 0 {
+		return gocv.NewMat(), &DimensionLimitError{Width: targetWidth, Height: targetHeight, What: "target"}
 	}
 
 	l.debugImage.LogAlgorithmStep("Lanczos4 Iterative", "Starting iterative downscaling")
@@ -60,9 +63,9 @@ func (l *Lanczos4Transform) iterativeLanczos4(src gocv.Mat, targetWidth, targetH
 	if err != nil {
 		l.debugImage.LogError(err)
 		scaled.Close()
-		return gocv.NewMat()
+		return gocv.NewMat(), openCVError("Lanczos4 resize", err)
 	}
 
 	l.debugImage.LogAlgorithmStep("Lanczos4 Iterative", fmt.Sprintf("Completed in %d steps", step+1))
-	return scaled
+	return scaled, nil
 }
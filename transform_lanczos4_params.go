@@ -5,37 +5,93 @@ import (
 	"math"
 )
 
+var lanczos4Parameters = append([]ParameterSpec{
+	{
+		Name: "scaleMode", Label: "Scale Mode", Type: ParameterEnum,
+		Values: []string{scaleModeFactor, scaleModeDPI}, Default: scaleModeFactor,
+		Help: "factor scales by Scale Factor; dpi resamples from the image's resolution to exactly Target DPI and saves that DPI",
+	},
+	{
+		Name: "scaleFactor", Label: "Scale Factor", Type: ParameterFloat,
+		Min: 0.1, Max: 10.0, Step: 0.1, Default: 2.0,
+		Help: "Output size relative to the input",
+	},
+	{
+		Name: "targetDPI", Label: "Target DPI", Type: ParameterFloat,
+		Min: 72, Max: 2400, Step: 1, Default: 300.0,
+		Help: "Resolution to scale to; changing it recalculates the scale factor",
+	},
+	{
+		Name: "originalDPI", Label: "Original DPI", Type: ParameterFloat,
+		Min: 72, Max: 2400, Step: 1, Default: 150.0,
+		Help: "Resolution assumed when the image file does not state one",
+	},
+	{
+		Name: "useIterative", Label: "Use Iterative Downscaling", Type: ParameterBool,
+		Default: false,
+		Help:    "Halve the image in several passes for large reductions",
+	},
+	{
+		Name: "linearLight", Label: "Resample in Linear Light", Type: ParameterBool,
+		Default: false,
+		Help:    "Decode sRGB before resampling so thin bright and dark details keep their brightness; slower",
+	},
+}, resamplingFilterParameters(filterAuto, filterAuto)...)
+
+func (l *Lanczos4Transform) ParameterSpecs() []ParameterSpec {
+	return lanczos4Parameters
+}
+
 func (l *Lanczos4Transform) GetParameters() map[string]interface{} {
-	return map[string]interface{}{
+	l.paramMutex.RLock()
+	defer l.paramMutex.RUnlock()
+
+	params := map[string]interface{}{
+		"scaleMode":    l.scaleMode,
 		"scaleFactor":  l.scaleFactor,
 		"targetDPI":    l.targetDPI,
 		"originalDPI":  l.originalDPI,
 		"useIterative": l.useIterative,
+		"linearLight":  l.linearLight,
 	}
+	l.filters.parameters(params)
+	return params
 }
 
 func (l *Lanczos4Transform) SetParameters(params map[string]interface{}) {
-	if scale, ok := params["scaleFactor"].(float64); ok {
-		if scale >= 0.1 && scale <= 10.0 {
-			l.scaleFactor = scale
-		}
-	}
-	if target, ok := params["targetDPI"].(float64); ok {
-		if target >= 72 && target <= 2400 {
-			l.targetDPI = target
-		}
-	}
-	if original, ok := params["originalDPI"].(float64); ok {
-		if original >= 72 && original <= 2400 {
-			l.originalDPI = original
+	l.paramMutex.Lock()
+	defer l.paramMutex.Unlock()
+
+	for name, value := range validParameters(lanczos4Parameters, params) {
+		switch name {
+		case "scaleMode":
+			l.scaleMode = value.(string)
+		case "scaleFactor":
+			l.scaleFactor = value.(float64)
+		case "targetDPI":
+			l.targetDPI = value.(float64)
+		case "originalDPI":
+			l.originalDPI = value.(float64)
+		case "useIterative":
+			l.useIterative = value.(bool)
+		case "linearLight":
+			l.linearLight = value.(bool)
+		default:
+			l.filters.set(name, value)
 		}
 	}
-	if iterative, ok := params["useIterative"].(bool); ok {
-		l.useIterative = iterative
-	}
+}
+
+func (l *Lanczos4Transform) settings() lanczos4Settings {
+	l.paramMutex.RLock()
+	defer l.paramMutex.RUnlock()
+	return l.lanczos4Settings
 }
 
 func (l *Lanczos4Transform) calculateScaleFactor() float64 {
+	l.paramMutex.RLock()
+	defer l.paramMutex.RUnlock()
+
 	if l.originalDPI > 0 && l.targetDPI > 0 && !math.IsInf(l.originalDPI, 0) && !math.IsInf(l.targetDPI, 0) {
 		calculated := l.targetDPI / l.originalDPI
 
@@ -47,3 +103,28 @@ func (l *Lanczos4Transform) calculateScaleFactor() float64 {
 	}
 	return l.scaleFactor
 }
+
+// scales returns the horizontal and vertical scale. In dpi mode they take
+// the image from its resolution, or originalDPI when that is unknown, to
+// targetDPI.
+func (l *Lanczos4Transform) scales(settings lanczos4Settings, resolution Resolution) (float64, float64) {
+	if settings.scaleMode != scaleModeDPI {
+		return settings.scaleFactor, settings.scaleFactor
+	}
+	if !resolution.Known() {
+		resolution = Resolution{X: settings.originalDPI, Y: settings.originalDPI}
+	}
+	l.debugImage.LogAlgorithmStep("Lanczos4", fmt.Sprintf("Scaling %s to %.0f DPI", resolution, settings.targetDPI))
+	return settings.targetDPI / resolution.X, settings.targetDPI / resolution.Y
+}
+
+// OutputResolution is targetDPI in dpi mode
+func (l *Lanczos4Transform) OutputResolution(input Resolution) (Resolution, bool) {
+	l.paramMutex.RLock()
+	defer l.paramMutex.RUnlock()
+
+	if l.scaleMode != scaleModeDPI {
+		return Resolution{}, false
+	}
+	return Resolution{X: l.targetDPI, Y: l.targetDPI}, true
+}
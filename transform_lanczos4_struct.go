@@ -1,25 +1,50 @@
 package main
 
-import "gocv.io/x/gocv"
+import (
+	"context"
+	"math"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
 
 type Lanczos4Transform struct {
-	debugImage   *DebugImage
+	debugImage *DebugImage
+
+	paramMutex sync.RWMutex
+	lanczos4Settings
+}
+
+// lanczos4Settings are the parameters, copied as a whole so a pass is not
+// affected by edits made while it runs
+type lanczos4Settings struct {
+	scaleMode    string
 	scaleFactor  float64
 	targetDPI    float64
 	originalDPI  float64
 	useIterative bool
+	linearLight  bool
+	filters      resamplingFilters
+}
 
-	onParameterChanged func()
+func init() {
+	RegisterTransformation(TransformationInfo{
+		ID:          "lanczos4",
+		DisplayName: "Lanczos4 Scaling",
+		Category:    "Scaling",
+		Description: "High quality colour resampling with Lanczos4 interpolation and artifact filters",
+		Factory: func(config *DebugConfig) Transformation {
+			return NewLanczos4Transform(config)
+		},
+	})
 }
 
 func NewLanczos4Transform(config *DebugConfig) *Lanczos4Transform {
-	return &Lanczos4Transform{
-		debugImage:   NewDebugImage(config),
-		scaleFactor:  2.0,
-		targetDPI:    300.0,
-		originalDPI:  150.0,
-		useIterative: false,
+	l := &Lanczos4Transform{
+		debugImage: NewDebugImage(config),
 	}
+	l.SetParameters(defaultParameters(lanczos4Parameters))
+	return l
 }
 
 func (l *Lanczos4Transform) Name() string {
@@ -30,20 +55,39 @@ func (l *Lanczos4Transform) Close() {
 	// No resources to cleanup
 }
 
-func (l *Lanczos4Transform) Apply(src gocv.Mat) gocv.Mat {
-	l.debugImage.LogAlgorithmStep("Lanczos4", "Starting full resolution scaling")
-	return l.applyLanczos4(src, l.scaleFactor)
+func (l *Lanczos4Transform) SupportedDepths() []gocv.MatType {
+	return allDepths
 }
 
-func (l *Lanczos4Transform) ApplyPreview(src gocv.Mat) gocv.Mat {
-	l.debugImage.LogAlgorithmStep("Lanczos4 Preview", "Starting preview scaling")
+const (
+	scaleModeFactor = "factor"
+	scaleModeDPI    = "dpi"
+)
 
-	previewScale := l.scaleFactor
-	if l.scaleFactor > 3.0 {
-		previewScale = 3.0
+func (l *Lanczos4Transform) Apply(ctx context.Context, src gocv.Mat) (gocv.Mat, error) {
+	if err := ctx.Err(); err != nil {
+		return gocv.NewMat(), err
 	}
 
-	result := l.applyLanczos4(src, previewScale)
+	settings := l.settings()
+	l.debugImage.LogAlgorithmStep("Lanczos4", "Starting full resolution scaling")
+	scaleX, scaleY := l.scales(settings, imageResolution(ctx))
+	result, err := l.applyLanczos4(src, settings, scaleX, scaleY)
+	ReportProgress(ctx, 1.0)
+	return result, err
+}
+
+func (l *Lanczos4Transform) ApplyPreview(ctx context.Context, src gocv.Mat) (gocv.Mat, error) {
+	if err := ctx.Err(); err != nil {
+		return gocv.NewMat(), err
+	}
+
+	settings := l.settings()
+	l.debugImage.LogAlgorithmStep("Lanczos4 Preview", "Starting preview scaling")
+
+	scaleX, scaleY := l.scales(settings, imageResolution(ctx))
+	result, err := l.applyLanczos4(src, settings, math.Min(scaleX, 3.0), math.Min(scaleY, 3.0))
 	l.debugImage.LogAlgorithmStep("Lanczos4 Preview", "Preview scaling completed")
-	return result
+	ReportProgress(ctx, 1.0)
+	return result, err
 }
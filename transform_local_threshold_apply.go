@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+func (l *LocalThreshold) applyWithScale(ctx context.Context, src gocv.Mat, scale float64) (result gocv.Mat, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in %s: %v", l.method.name, r)
+			l.debugImage.LogError(err)
+		}
+	}()
+
+	operation := l.method.name + "_Complete"
+	l.debugPerf.StartOperation(operation, fmt.Sprintf("scale=%.2f", scale))
+	defer l.debugPerf.EndOperation(operation)
+
+	if src.Empty() {
+		return gocv.NewMat(), fmt.Errorf("input image is empty")
+	}
+
+	l.paramMutex.RLock()
+	windowRadius := l.windowRadius
+	k := l.k
+	dynamicRange := l.dynamicRange
+	guidedRadius := l.guidedRadius
+	epsilon := l.epsilon
+	noiseReduction := l.noiseReduction
+	morphKernelSize := l.morphKernelSize
+	l.paramMutex.RUnlock()
+
+	// The preview runs at reduced size, so the window shrinks with it
+	if scale != 1.0 {
+		windowRadius = max(1, int(math.Round(float64(windowRadius)*scale)))
+		guidedRadius = int(math.Round(float64(guidedRadius) * scale))
+	}
+
+	l.debugImage.LogAlgorithmStep(l.method.name, fmt.Sprintf("Starting binarization (scale: %.2f, radius: %d, k: %.3f)", scale, windowRadius, k))
+
+	working := src.Clone()
+	defer working.Close()
+	if scale != 1.0 {
+		newWidth := int(float64(src.Cols()) * scale)
+		newHeight := int(float64(src.Rows()) * scale)
+		if err := checkDimensions("scaled working image", newWidth, newHeight, 16384); err != nil {
+			return gocv.NewMat(), err
+		}
+		if err := gocv.Resize(src, &working, image.Point{X: newWidth, Y: newHeight}, 0, 0, gocv.InterpolationArea); err != nil {
+			l.debugImage.LogError(err)
+			return gocv.NewMat(), openCVError("resize", err)
+		}
+	}
+
+	gray := gocv.NewMat()
+	defer gray.Close()
+	if working.Channels() > 1 {
+		if err := gocv.CvtColor(working, &gray, gocv.ColorBGRToGray); err != nil {
+			l.debugImage.LogError(err)
+			return gocv.NewMat(), openCVError("grayscale conversion", err)
+		}
+	} else {
+		working.CopyTo(&gray)
+	}
+
+	if noiseReduction {
+		denoised := historicalNoiseReduction(l.debugImage, gray)
+		gray.Close()
+		gray = denoised
+	}
+
+	if guidedRadius > 0 {
+		guided, err := guidedFilter(l.debugImage, l.debugPerf, gray, guidedRadius, epsilon)
+		if err != nil {
+			return gocv.NewMat(), err
+		}
+		gray.Close()
+		gray = guided
+	}
+
+	if err := ctx.Err(); err != nil {
+		return gocv.NewMat(), err
+	}
+	ReportProgress(ctx, 0.2)
+
+	binary, err := l.thresholdLocally(ProgressRange(ctx, 0.2, 0.9), gray, windowRadius, k, dynamicRange)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+
+	processed := morphologicalCleanup(l.debugImage, binary, morphKernelSize)
+	binary.Close()
+
+	if scale != 1.0 {
+		result = gocv.NewMat()
+		err := gocv.Resize(processed, &result, image.Point{X: src.Cols(), Y: src.Rows()}, 0, 0, gocv.InterpolationNearestNeighbor)
+		processed.Close()
+		if err != nil {
+			l.debugImage.LogError(err)
+			result.Close()
+			return gocv.NewMat(), openCVError("resize", err)
+		}
+	} else {
+		result = processed
+	}
+
+	ReportProgress(ctx, 1.0)
+	l.debugImage.LogAlgorithmStep(l.method.name, "Completed successfully")
+	return result, nil
+}
+
+// thresholdLocally computes the window mean and standard deviation of every
+// pixel from integral images and applies the method's threshold formula.
+// Ink becomes 0 and background 255, as in the 2D Otsu output.
+func (l *LocalThreshold) thresholdLocally(ctx context.Context, gray gocv.Mat, radius int, k, dynamicRange float64) (gocv.Mat, error) {
+	operation := l.method.name + "_Integral"
+	l.debugPerf.StartOperation(operation, fmt.Sprintf("radius=%d", radius))
+	defer l.debugPerf.EndOperation(operation)
+
+	stats, err := newWindowStats(gray)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	defer stats.Close()
+
+	pixels, err := gray.DataPtrUint8()
+	if err != nil {
+		return gocv.NewMat(), openCVError("read pixels", err)
+	}
+
+	rows, cols := gray.Rows(), gray.Cols()
+
+	// Wolf-Jolion normalizes by the darkest pixel and the largest local
+	// standard deviation of the page
+	minGray, maxStd := 255.0, 0.0
+	if l.method.id == "wolf" {
+		for _, value := range pixels {
+			minGray = math.Min(minGray, float64(value))
+		}
+		for y := 0; y < rows; y++ {
+			for x := 0; x < cols; x++ {
+				_, std := stats.at(x, y, radius)
+				maxStd = math.Max(maxStd, std)
+			}
+		}
+		maxStd = math.Max(maxStd, 1)
+	}
+
+	result := gocv.NewMatWithSize(rows, cols, gocv.MatTypeCV8U)
+	output, err := result.DataPtrUint8()
+	if err != nil {
+		result.Close()
+		return gocv.NewMat(), openCVError("write pixels", err)
+	}
+
+	for y := 0; y < rows; y++ {
+		if y%64 == 0 {
+			if err := ctx.Err(); err != nil {
+				result.Close()
+				return gocv.NewMat(), err
+			}
+			ReportProgress(ctx, float64(y)/float64(rows))
+		}
+
+		for x := 0; x < cols; x++ {
+			mean, std := stats.at(x, y, radius)
+
+			var threshold float64
+			switch l.method.id {
+			case "niblack":
+				threshold = mean + k*std
+			case "sauvola":
+				threshold = mean * (1 + k*(std/dynamicRange-1))
+			case "wolf":
+				threshold = (1-k)*mean + k*minGray + k*(std/maxStd)*(mean-minGray)
+			case "bradley":
+				threshold = mean * (1 - k)
+			}
+
+			index := y*cols + x
+			if float64(pixels[index]) > threshold {
+				output[index] = 255
+			} else {
+				output[index] = 0
+			}
+		}
+	}
+
+	l.debugImage.LogAlgorithmStep(l.method.name, fmt.Sprintf("Thresholded %dx%d pixels with window %dx%d", cols, rows, 2*radius+1, 2*radius+1))
+	return result, nil
+}
+
+// windowStats reads the integral and squared integral images of an 8-bit
+// grayscale Mat, each (cols+1) wide, in place. Close releases them.
+type windowStats struct {
+	sumMat   gocv.Mat
+	sqsumMat gocv.Mat
+	sum      []byte
+	sqsum    []float64
+	width    int
+	height   int
+}
+
+func newWindowStats(gray gocv.Mat) (*windowStats, error) {
+	stats := &windowStats{
+		sumMat:   gocv.NewMat(),
+		sqsumMat: gocv.NewMat(),
+		width:    gray.Cols(),
+		height:   gray.Rows(),
+	}
+
+	// The binding always computes the tilted integral as well
+	tilted := gocv.NewMat()
+	defer tilted.Close()
+	if err := gocv.Integral(gray, &stats.sumMat, &stats.sqsumMat, &tilted); err != nil {
+		stats.Close()
+		return nil, openCVError("integral image", err)
+	}
+
+	// An 8-bit image gives a CV_32S sum, which gocv only exposes as bytes,
+	// and a CV_64F squared sum
+	var err error
+	if stats.sum, err = stats.sumMat.DataPtrUint8(); err != nil {
+		stats.Close()
+		return nil, openCVError("integral image", err)
+	}
+	if stats.sqsum, err = stats.sqsumMat.DataPtrFloat64(); err != nil {
+		stats.Close()
+		return nil, openCVError("integral image", err)
+	}
+	return stats, nil
+}
+
+func (s *windowStats) sumAt(index int) int32 {
+	return int32(binary.NativeEndian.Uint32(s.sum[4*index:]))
+}
+
+func (s *windowStats) Close() {
+	s.sumMat.Close()
+	s.sqsumMat.Close()
+}
+
+// at returns the mean and standard deviation of the window of the given
+// radius around x, y, clipped to the image.
+func (s *windowStats) at(x, y, radius int) (float64, float64) {
+	x1, y1 := max(x-radius, 0), max(y-radius, 0)
+	x2, y2 := min(x+radius+1, s.width), min(y+radius+1, s.height)
+
+	stride := s.width + 1
+	area := float64((x2 - x1) * (y2 - y1))
+	// The int32 sum wraps on large pages; the window difference stays exact
+	sum := float64(s.sumAt(y2*stride+x2) - s.sumAt(y1*stride+x2) - s.sumAt(y2*stride+x1) + s.sumAt(y1*stride+x1))
+	sqsum := s.sqsum[y2*stride+x2] - s.sqsum[y1*stride+x2] - s.sqsum[y2*stride+x1] + s.sqsum[y1*stride+x1]
+
+	mean := sum / area
+	variance := math.Max(sqsum/area-mean*mean, 0)
+	return mean, math.Sqrt(variance)
+}
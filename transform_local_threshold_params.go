@@ -0,0 +1,87 @@
+package main
+
+func localThresholdParameters(method localThresholdMethod) []ParameterSpec {
+	specs := []ParameterSpec{
+		{
+			Name: "windowRadius", Label: "Window Radius", Type: ParameterInt,
+			Min: 1, Max: 100, Step: 1, Default: 15,
+			Help: "Half size of the window; about the height of a text line works best",
+		},
+		method.k,
+	}
+	if method.usesRange {
+		specs = append(specs, ParameterSpec{
+			Name: "dynamicRange", Label: "Dynamic Range R", Type: ParameterFloat,
+			Min: 1, Max: 255, Step: 1, Default: 128.0,
+			Help: "Largest expected standard deviation of gray values",
+		})
+	}
+	return append(specs,
+		ParameterSpec{
+			Name: "guidedRadius", Label: "Guided Filter Radius", Type: ParameterInt,
+			Min: 0, Max: 20, Step: 1, Default: 2,
+			Help: "Edge preserving smoothing before thresholding; 0 disables it",
+		},
+		ParameterSpec{
+			Name: "epsilon", Label: "Epsilon", Type: ParameterFloat,
+			Min: 0.001, Max: 1.0, Step: 0.001, Default: 0.01,
+			Help: "Guided filter regularization; larger values smooth across edges",
+		},
+		ParameterSpec{
+			Name: "noiseReduction", Label: "Enable Historical Noise Reduction", Type: ParameterBool,
+			Default: false,
+			Help:    "Denoise paper texture before thresholding",
+		},
+		ParameterSpec{
+			Name: "morphKernelSize", Label: "Morphological Kernel Size", Type: ParameterInt,
+			Min: 1, Max: 15, Step: 2, OddOnly: true, Default: 1,
+			Help: "Size of the opening and closing kernel applied to the binary result; 1 disables it",
+		},
+	)
+}
+
+func (l *LocalThreshold) ParameterSpecs() []ParameterSpec {
+	return l.specs
+}
+
+func (l *LocalThreshold) GetParameters() map[string]interface{} {
+	l.paramMutex.RLock()
+	defer l.paramMutex.RUnlock()
+
+	params := map[string]interface{}{
+		"windowRadius":    l.windowRadius,
+		"k":               l.k,
+		"guidedRadius":    l.guidedRadius,
+		"epsilon":         l.epsilon,
+		"noiseReduction":  l.noiseReduction,
+		"morphKernelSize": l.morphKernelSize,
+	}
+	if l.method.usesRange {
+		params["dynamicRange"] = l.dynamicRange
+	}
+	return params
+}
+
+func (l *LocalThreshold) SetParameters(params map[string]interface{}) {
+	l.paramMutex.Lock()
+	defer l.paramMutex.Unlock()
+
+	for name, value := range validParameters(l.specs, params) {
+		switch name {
+		case "windowRadius":
+			l.windowRadius = value.(int)
+		case "k":
+			l.k = value.(float64)
+		case "dynamicRange":
+			l.dynamicRange = value.(float64)
+		case "guidedRadius":
+			l.guidedRadius = value.(int)
+		case "epsilon":
+			l.epsilon = value.(float64)
+		case "noiseReduction":
+			l.noiseReduction = value.(bool)
+		case "morphKernelSize":
+			l.morphKernelSize = value.(int)
+		}
+	}
+}
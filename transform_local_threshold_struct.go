@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// localThresholdMethod describes one of the window based binarization
+// formulas. All of them threshold a pixel against the mean m and standard
+// deviation s of the window around it.
+type localThresholdMethod struct {
+	id          string
+	name        string
+	description string
+	k           ParameterSpec
+	usesRange   bool
+}
+
+var localThresholdMethods = []localThresholdMethod{
+	{
+		id:          "niblack",
+		name:        "Niblack",
+		description: "Local threshold T = m + k*s; keeps faint strokes but amplifies background noise",
+		k: ParameterSpec{
+			Name: "k", Label: "k", Type: ParameterFloat, Min: -1.0, Max: 1.0, Step: 0.01, Default: -0.2,
+			Help: "Weight of the local standard deviation; negative values keep more ink",
+		},
+	},
+	{
+		id:          "sauvola",
+		name:        "Sauvola",
+		description: "Local threshold T = m*(1 + k*(s/R - 1)); suited to stained and unevenly lit pages",
+		k: ParameterSpec{
+			Name: "k", Label: "k", Type: ParameterFloat, Min: 0.01, Max: 1.0, Step: 0.01, Default: 0.34,
+			Help: "Larger values thin strokes and clean the background",
+		},
+		usesRange: true,
+	},
+	{
+		id:          "wolf",
+		name:        "Wolf-Jolion",
+		description: "Sauvola variant normalized by the page contrast; suited to low contrast documents",
+		k: ParameterSpec{
+			Name: "k", Label: "k", Type: ParameterFloat, Min: 0.01, Max: 1.0, Step: 0.01, Default: 0.5,
+			Help: "Weight of the page minimum and the normalized standard deviation",
+		},
+	},
+	{
+		id:          "bradley",
+		name:        "Bradley-Roth",
+		description: "Marks pixels darker than the window mean by more than t percent; fast and robust to gradients",
+		k: ParameterSpec{
+			Name: "k", Label: "Sensitivity t", Type: ParameterFloat, Min: 0.0, Max: 0.5, Step: 0.01, Default: 0.15,
+			Help: "Fraction below the local mean at which a pixel becomes ink",
+		},
+	},
+}
+
+func init() {
+	for _, method := range localThresholdMethods {
+		RegisterTransformation(TransformationInfo{
+			ID:          method.id,
+			DisplayName: method.name,
+			Category:    "Binarization",
+			Description: method.description,
+			Factory: func(config *DebugConfig) Transformation {
+				return NewLocalThreshold(config, method)
+			},
+		})
+	}
+}
+
+// LocalThreshold binarizes with one of the localThresholdMethods, using
+// integral images for the window mean and variance.
+type LocalThreshold struct {
+	debugImage *DebugImage
+	debugPerf  *DebugPerformance
+	method     localThresholdMethod
+	specs      []ParameterSpec
+
+	paramMutex      sync.RWMutex
+	windowRadius    int
+	k               float64
+	dynamicRange    float64
+	guidedRadius    int
+	epsilon         float64
+	noiseReduction  bool
+	morphKernelSize int
+}
+
+func NewLocalThreshold(config *DebugConfig, method localThresholdMethod) *LocalThreshold {
+	l := &LocalThreshold{
+		debugImage: NewDebugImage(config),
+		debugPerf:  NewDebugPerformance(config),
+		method:     method,
+		specs:      localThresholdParameters(method),
+	}
+	l.SetParameters(defaultParameters(l.specs))
+	return l
+}
+
+func (l *LocalThreshold) Name() string {
+	return l.method.name
+}
+
+func (l *LocalThreshold) Close() {
+	// No resources to cleanup
+}
+
+func (l *LocalThreshold) Apply(ctx context.Context, src gocv.Mat) (gocv.Mat, error) {
+	return l.applyWithScale(ctx, src, 1.0)
+}
+
+func (l *LocalThreshold) ApplyPreview(ctx context.Context, src gocv.Mat) (gocv.Mat, error) {
+	return l.applyWithScale(ctx, src, 0.5)
+}
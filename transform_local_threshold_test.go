@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func grayMat(t *testing.T, rows, cols int, pixels []byte) gocv.Mat {
+	t.Helper()
+	mat, err := gocv.NewMatFromBytes(rows, cols, gocv.MatTypeCV8UC1, pixels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mat
+}
+
+func TestWindowStats(t *testing.T) {
+	const rows, cols = 3, 4
+	pixels := []byte{
+		200, 200, 190, 60,
+		210, 40, 200, 195,
+		205, 200, 50, 190,
+	}
+	gray := grayMat(t, rows, cols, pixels)
+	defer gray.Close()
+
+	stats, err := newWindowStats(gray)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stats.Close()
+
+	for radius := 0; radius <= 2; radius++ {
+		for y := 0; y < rows; y++ {
+			for x := 0; x < cols; x++ {
+				var sum, sqsum, count float64
+				for wy := max(y-radius, 0); wy <= min(y+radius, rows-1); wy++ {
+					for wx := max(x-radius, 0); wx <= min(x+radius, cols-1); wx++ {
+						value := float64(pixels[wy*cols+wx])
+						sum += value
+						sqsum += value * value
+						count++
+					}
+				}
+				wantMean := sum / count
+				wantStd := math.Sqrt(math.Max(sqsum/count-wantMean*wantMean, 0))
+
+				mean, std := stats.at(x, y, radius)
+				if math.Abs(mean-wantMean) > 1e-9 || math.Abs(std-wantStd) > 1e-6 {
+					t.Errorf("radius %d at (%d, %d): mean %.3f std %.3f, want %.3f %.3f", radius, x, y, mean, std, wantMean, wantStd)
+				}
+			}
+		}
+	}
+}
+
+func TestLocalThresholdFormulas(t *testing.T) {
+	// With a radius of 4 every window is the whole image: mean 195 and
+	// standard deviation 51.54, so each method has one threshold
+	pixels := []byte{90, 160, 175, 190, 200, 245, 250, 250}
+	tests := []struct {
+		method string
+		want   []byte
+	}{
+		// 195 - 0.2*51.54 = 184.69
+		{"niblack", []byte{0, 0, 0, 255, 255, 255, 255, 255}},
+		// 195 * (1 + 0.34*(51.54/128 - 1)) = 155.40
+		{"sauvola", []byte{0, 255, 255, 255, 255, 255, 255, 255}},
+		// 0.5*195 + 0.5*90 + 0.5*(51.54/51.54)*(195 - 90) = 195
+		{"wolf", []byte{0, 0, 0, 0, 255, 255, 255, 255}},
+		// 195 * (1 - 0.15) = 165.75
+		{"bradley", []byte{0, 0, 255, 255, 255, 255, 255, 255}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			transformation, err := transformationRegistry.New(test.method, &DebugConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			threshold := transformation.(*LocalThreshold)
+			defer threshold.Close()
+
+			gray := grayMat(t, 2, 4, pixels)
+			defer gray.Close()
+
+			result, err := threshold.thresholdLocally(context.Background(), gray, 4, threshold.method.k.Default.(float64), 128)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer result.Close()
+
+			got := result.ToBytes()
+			for i := range test.want {
+				if got[i] != test.want[i] {
+					t.Fatalf("output %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
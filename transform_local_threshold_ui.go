@@ -0,0 +1,13 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+)
+
+func (l *LocalThreshold) GetParametersWidget(onParameterChanged func()) fyne.CanvasObject {
+	return newParameterForm(l, l.debugImage, func(string) {
+		if onParameterChanged != nil {
+			onParameterChanged()
+		}
+	}).content
+}
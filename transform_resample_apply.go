@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+func (r *Resample) applyResample(ctx context.Context, src gocv.Mat, preview bool) (gocv.Mat, error) {
+	if src.Empty() {
+		return gocv.NewMat(), fmt.Errorf("input image is empty")
+	}
+
+	r.paramMutex.RLock()
+	kernelID := r.kernel
+	scale := r.scaleFactor
+	linearLight := r.linearLight
+	filters := r.filters
+	r.paramMutex.RUnlock()
+
+	if preview && scale > 3.0 {
+		scale = 3.0
+	}
+
+	newWidth := int(math.Round(float64(src.Cols()) * scale))
+	newHeight := int(math.Round(float64(src.Rows()) * scale))
+	if err := checkDimensions("target", newWidth, newHeight, 32768); err != nil {
+		return gocv.NewMat(), err
+	}
+
+	r.debugPerf.StartOperation("Resample_Complete", fmt.Sprintf("kernel=%s, scale=%.2f", kernelID, scale))
+	defer r.debugPerf.EndOperation("Resample_Complete")
+	r.debugImage.LogAlgorithmStep("Resample", fmt.Sprintf("%s: %dx%d -> %dx%d, %d channels", kernelID, src.Cols(), src.Rows(), newWidth, newHeight, src.Channels()))
+
+	// Nearest neighbour only copies samples, so bilevel and label images
+	// keep their values; filters would add new ones
+	if kernelID == kernelNearest {
+		result := gocv.NewMat()
+		if err := gocv.Resize(src, &result, image.Point{X: newWidth, Y: newHeight}, 0, 0, gocv.InterpolationNearestNeighbor); err != nil {
+			result.Close()
+			return gocv.NewMat(), openCVError("nearest neighbour resize", err)
+		}
+		ReportProgress(ctx, 1.0)
+		return result, nil
+	}
+
+	working, err := toResamplingSpace(src, linearLight)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	defer working.Close()
+
+	filtered := filters.applyPre(r.debugImage, working)
+	defer filtered.Close()
+	ReportProgress(ctx, 0.1)
+
+	var resized gocv.Mat
+	if kernelID == kernelArea {
+		resized = gocv.NewMat()
+		if err := gocv.Resize(filtered, &resized, image.Point{X: newWidth, Y: newHeight}, 0, 0, gocv.InterpolationArea); err != nil {
+			resized.Close()
+			return gocv.NewMat(), openCVError("area resize", err)
+		}
+	} else {
+		kernel, ok := findResamplingKernel(kernelID)
+		if !ok {
+			return gocv.NewMat(), &InvalidParameterError{Parameter: "kernel", Value: kernelID, Reason: "unknown kernel"}
+		}
+		resized, err = r.resampleWithKernel(ProgressRange(ctx, 0.1, 0.9), kernel, filtered, newWidth, newHeight)
+		if err != nil {
+			return gocv.NewMat(), err
+		}
+	}
+	defer resized.Close()
+
+	converted, err := fromResamplingSpace(resized, linearLight, matDepth(src))
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	defer converted.Close()
+
+	result := filters.applyPost(r.debugImage, converted)
+	ReportProgress(ctx, 1.0)
+	r.debugImage.LogAlgorithmStep("Resample", "Completed successfully")
+	return result, nil
+}
+
+// resampleWithKernel runs the separable Go resampler over a float Mat
+func (r *Resample) resampleWithKernel(ctx context.Context, kernel resamplingKernel, src gocv.Mat, width, height int) (gocv.Mat, error) {
+	r.debugPerf.StartOperation("Resample_Kernel", kernel.id)
+	defer r.debugPerf.EndOperation("Resample_Kernel")
+
+	source := src
+	if !src.IsContinuous() {
+		source = src.Clone()
+		defer source.Close()
+	}
+	samples, err := source.DataPtrFloat32()
+	if err != nil {
+		return gocv.NewMat(), openCVError("read pixels", err)
+	}
+
+	channels := src.Channels()
+	resampled, err := resampleSamples(ctx, kernel, samples, channels, src.Cols(), src.Rows(), width, height)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+
+	result := gocv.NewMatWithSize(height, width, matType(gocv.MatTypeCV32F, channels))
+	output, err := result.DataPtrFloat32()
+	if err != nil {
+		result.Close()
+		return gocv.NewMat(), openCVError("write pixels", err)
+	}
+	copy(output, resampled)
+	return result, nil
+}
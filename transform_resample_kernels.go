@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"math"
+)
+
+// resamplingKernel is a separable reconstruction filter, zero outside
+// [-support, support]. area and nearest are left to OpenCV and have none.
+type resamplingKernel struct {
+	id      string
+	support float64
+	weight  func(x float64) float64
+}
+
+var resamplingKernels = []resamplingKernel{
+	{id: "lanczos2", support: 2, weight: lanczos(2)},
+	{id: "lanczos3", support: 3, weight: lanczos(3)},
+	{id: "lanczos4", support: 4, weight: lanczos(4)},
+	{id: "mitchell", support: 2, weight: bicubic(1.0/3, 1.0/3)},
+	{id: "catmull-rom", support: 2, weight: bicubic(0, 0.5)},
+	{id: "b-spline", support: 2, weight: bicubic(1, 0)},
+	{id: kernelArea},
+	{id: kernelNearest},
+}
+
+const (
+	kernelArea    = "area"
+	kernelNearest = "nearest"
+)
+
+func resamplingKernelIDs() []string {
+	ids := make([]string, len(resamplingKernels))
+	for i, kernel := range resamplingKernels {
+		ids[i] = kernel.id
+	}
+	return ids
+}
+
+func findResamplingKernel(id string) (resamplingKernel, bool) {
+	for _, kernel := range resamplingKernels {
+		if kernel.id == id {
+			return kernel, true
+		}
+	}
+	return resamplingKernel{}, false
+}
+
+func lanczos(a float64) func(float64) float64 {
+	return func(x float64) float64 {
+		x = math.Abs(x)
+		if x < 1e-8 {
+			return 1
+		}
+		if x >= a {
+			return 0
+		}
+		px := math.Pi * x
+		return a * math.Sin(px) * math.Sin(px/a) / (px * px)
+	}
+}
+
+// bicubic is the Mitchell-Netravali family; B=1/3, C=1/3 is Mitchell,
+// B=0, C=1/2 Catmull-Rom and B=1, C=0 the cubic B-spline
+func bicubic(b, c float64) func(float64) float64 {
+	return func(x float64) float64 {
+		x = math.Abs(x)
+		switch {
+		case x < 1:
+			return ((12-9*b-6*c)*x*x*x + (-18+12*b+6*c)*x*x + (6 - 2*b)) / 6
+		case x < 2:
+			return ((-b-6*c)*x*x*x + (6*b+30*c)*x*x + (-12*b-48*c)*x + (8*b + 24*c)) / 6
+		default:
+			return 0
+		}
+	}
+}
+
+// resampleTaps lists, for every output position along one axis, the input
+// positions and normalized weights that contribute to it
+type resampleTaps struct {
+	offsets []int // taps of output i are indices[offsets[i]:offsets[i+1]]
+	indices []int
+	weights []float32
+}
+
+// newResampleTaps maps inSize samples to outSize. When shrinking, the kernel
+// is stretched by the reduction so it also acts as the anti-alias filter.
+// Positions past the edges repeat the edge sample.
+func newResampleTaps(kernel resamplingKernel, inSize, outSize int) resampleTaps {
+	scale := float64(outSize) / float64(inSize)
+	stretch := math.Max(1, 1/scale)
+	support := kernel.support * stretch
+
+	taps := resampleTaps{offsets: make([]int, 1, outSize+1)}
+	for out := 0; out < outSize; out++ {
+		centre := (float64(out)+0.5)/scale - 0.5
+		first := int(math.Ceil(centre - support))
+		last := int(math.Floor(centre + support))
+
+		start := len(taps.weights)
+		total := 0.0
+		for in := first; in <= last; in++ {
+			weight := kernel.weight((float64(in) - centre) / stretch)
+			if weight == 0 {
+				continue
+			}
+			taps.indices = append(taps.indices, max(0, min(in, inSize-1)))
+			taps.weights = append(taps.weights, float32(weight))
+			total += weight
+		}
+		if total != 0 {
+			for i := start; i < len(taps.weights); i++ {
+				taps.weights[i] /= float32(total)
+			}
+		}
+		taps.offsets = append(taps.offsets, len(taps.weights))
+	}
+	return taps
+}
+
+// resampleSamples resizes interleaved float samples with channels per pixel
+// from inWidth x inHeight to outWidth x outHeight, horizontally and then
+// vertically. Rows are processed in bands on the tile workers.
+func resampleSamples(ctx context.Context, kernel resamplingKernel, samples []float32, channels, inWidth, inHeight, outWidth, outHeight int) ([]float32, error) {
+	horizontal := newResampleTaps(kernel, inWidth, outWidth)
+	vertical := newResampleTaps(kernel, inHeight, outHeight)
+
+	// Horizontal pass: inHeight rows of outWidth pixels
+	wide := make([]float32, inHeight*outWidth*channels)
+	err := resampleRows(ProgressRange(ctx, 0, 0.5), inHeight, func(y int) {
+		in := samples[y*inWidth*channels : (y+1)*inWidth*channels]
+		out := wide[y*outWidth*channels : (y+1)*outWidth*channels]
+		for x := 0; x < outWidth; x++ {
+			for t := horizontal.offsets[x]; t < horizontal.offsets[x+1]; t++ {
+				weight := horizontal.weights[t]
+				source := horizontal.indices[t] * channels
+				for c := 0; c < channels; c++ {
+					out[x*channels+c] += weight * in[source+c]
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Vertical pass: outHeight rows, each a weighted sum of whole rows
+	rowLength := outWidth * channels
+	result := make([]float32, outHeight*rowLength)
+	err = resampleRows(ProgressRange(ctx, 0.5, 1.0), outHeight, func(y int) {
+		out := result[y*rowLength : (y+1)*rowLength]
+		for t := vertical.offsets[y]; t < vertical.offsets[y+1]; t++ {
+			weight := vertical.weights[t]
+			in := wide[vertical.indices[t]*rowLength : (vertical.indices[t]+1)*rowLength]
+			for i, value := range in {
+				out[i] += weight * value
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// resampleRows calls row for 0..rows-1 in bands of 64 rows; rows are
+// independent, so bands need no locking
+func resampleRows(ctx context.Context, rows int, row func(y int)) error {
+	const bandRows = 64
+	bands := (rows + bandRows - 1) / bandRows
+	return runTiles(ctx, bands, tileWorkers(ctx), func(ctx context.Context, worker, band int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for y := band * bandRows; y < min((band+1)*bandRows, rows); y++ {
+			row(y)
+		}
+		return nil
+	})
+}
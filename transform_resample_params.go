@@ -0,0 +1,54 @@
+package main
+
+var resampleParameters = append([]ParameterSpec{
+	{
+		Name: "kernel", Label: "Kernel", Type: ParameterEnum,
+		Values: resamplingKernelIDs(), Default: "lanczos3",
+		Help: "lanczos2-4 are sharp with slight ringing; mitchell balances sharpness and ringing; catmull-rom is sharper; b-spline is smooth without ringing; area averages when shrinking; nearest keeps bilevel images bilevel",
+	},
+	{
+		Name: "scaleFactor", Label: "Scale Factor", Type: ParameterFloat,
+		Min: 0.1, Max: 10.0, Step: 0.1, Default: 2.0,
+		Help: "Output size relative to the input",
+	},
+	{
+		Name: "linearLight", Label: "Resample in Linear Light", Type: ParameterBool,
+		Default: false,
+		Help:    "Decode sRGB before resampling so thin bright and dark details keep their brightness",
+	},
+}, resamplingFilterParameters(filterNone, filterNone)...)
+
+func (r *Resample) ParameterSpecs() []ParameterSpec {
+	return resampleParameters
+}
+
+func (r *Resample) GetParameters() map[string]interface{} {
+	r.paramMutex.RLock()
+	defer r.paramMutex.RUnlock()
+
+	params := map[string]interface{}{
+		"kernel":      r.kernel,
+		"scaleFactor": r.scaleFactor,
+		"linearLight": r.linearLight,
+	}
+	r.filters.parameters(params)
+	return params
+}
+
+func (r *Resample) SetParameters(params map[string]interface{}) {
+	r.paramMutex.Lock()
+	defer r.paramMutex.Unlock()
+
+	for name, value := range validParameters(resampleParameters, params) {
+		switch name {
+		case "kernel":
+			r.kernel = value.(string)
+		case "scaleFactor":
+			r.scaleFactor = value.(float64)
+		case "linearLight":
+			r.linearLight = value.(bool)
+		default:
+			r.filters.set(name, value)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+)
+
+func (r *Resample) GetParametersWidget(onParameterChanged func()) fyne.CanvasObject {
+	return newParameterForm(r, r.debugImage, func(string) {
+		if onParameterChanged != nil {
+			onParameterChanged()
+		}
+	}).content
+}
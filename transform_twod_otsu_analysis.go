@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"gocv.io/x/gocv"
+)
+
+// twoDOtsuAnalysis is what the parameter panel shows of the latest run: the
+// joint histogram of the whole image, the thresholds used with it when one
+// (s, t) pair applies everywhere, and a one-line description.
+type twoDOtsuAnalysis struct {
+	hist          *histogram2D
+	s, t          int
+	hasThresholds bool
+	note          string
+}
+
+func (t *TwoDOtsu) recordAnalysis(analysis twoDOtsuAnalysis) {
+	t.analysisMutex.Lock()
+	t.analysis = analysis
+	notify := t.onAnalysis
+	t.analysisMutex.Unlock()
+
+	if analysis.hasThresholds {
+		t.debugImage.LogAlgorithmStep("2D Otsu", analysis.note)
+	}
+	if notify != nil {
+		notify()
+	}
+}
+
+func (t *TwoDOtsu) lastAnalysis() twoDOtsuAnalysis {
+	t.analysisMutex.Lock()
+	defer t.analysisMutex.Unlock()
+	return t.analysis
+}
+
+// setAnalysisListener replaces the callback run after every recorded
+// analysis; the panel built last is the one kept up to date.
+func (t *TwoDOtsu) setAnalysisListener(listener func()) {
+	t.analysisMutex.Lock()
+	t.onAnalysis = listener
+	t.analysisMutex.Unlock()
+}
+
+// applyManual2DOtsu binarizes with fixed thresholds. The histogram is still
+// built so the panel can show where the thresholds fall.
+func (t *TwoDOtsu) applyManual2DOtsu(ctx context.Context, gray, guided gocv.Mat, s, threshold int) (gocv.Mat, error) {
+	t.debugImage.LogAlgorithmStep("2D Otsu", fmt.Sprintf("Using manual thresholds s=%d, t=%d", s, threshold))
+
+	t.debugPerf.StartOperation("2D_Otsu_Manual_Histogram", "joint_histogram_construction")
+	hist, err := t.buildHistogram2D(ProgressRange(ctx, 0, 0.8), gray, guided)
+	t.debugPerf.EndOperation("2D_Otsu_Manual_Histogram")
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	t.recordAnalysis(twoDOtsuAnalysis{
+		hist: hist, s: s, t: threshold, hasThresholds: true,
+		note: fmt.Sprintf("Manual thresholds s=%d, t=%d", s, threshold),
+	})
+
+	result := t.applyVectorizedBinarization(gray, guided, s, threshold)
+	ReportProgress(ctx, 1.0)
+	return result, nil
+}
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
+	"sync"
 
 	"gocv.io/x/gocv"
 )
 
-func (t *TwoDOtsu) applyWithScale(src gocv.Mat, scale float64) gocv.Mat {
+func (t *TwoDOtsu) applyWithScale(ctx context.Context, src gocv.Mat, scale float64) (result gocv.Mat, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			t.debugImage.LogError(fmt.Errorf("panic in 2D Otsu: %v", r))
+			err = fmt.Errorf("panic in 2D Otsu: %v", r)
+			t.debugImage.LogError(err)
 		}
 	}()
 
@@ -22,7 +25,7 @@ func (t *TwoDOtsu) applyWithScale(src gocv.Mat, scale float64) gocv.Mat {
 
 	if src.Empty() {
 		t.debugImage.LogAlgorithmStep("2D Otsu", "ERROR: Input matrix is empty")
-		return gocv.NewMat()
+		return gocv.NewMat(), fmt.Errorf("input image is empty")
 	}
 
 	t.paramMutex.RLock()
@@ -32,6 +35,12 @@ func (t *TwoDOtsu) applyWithScale(src gocv.Mat, scale float64) gocv.Mat {
 	noiseReduction := t.noiseReduction
 	useIntegralImage := t.useIntegralImage
 	adaptiveRegions := t.adaptiveRegions
+	regionMode := t.regionMode
+	regionOverlap := t.regionOverlap
+	classes := t.classes
+	multiLevelOutput := t.multiLevelOutput
+	manualThresholds := t.manualThresholds
+	manualS, manualT := t.manualS, t.manualT
 	t.paramMutex.RUnlock()
 
 	t.debugPerf.LogStep("2D_Otsu_Complete", "Parameters loaded", fmt.Sprintf("radius=%d, epsilon=%.3f, regions=%d", windowRadius, epsilon, adaptiveRegions))
@@ -42,10 +51,10 @@ func (t *TwoDOtsu) applyWithScale(src gocv.Mat, scale float64) gocv.Mat {
 		newWidth := int(float64(src.Cols()) * scale)
 		newHeight := int(float64(src.Rows()) * scale)
 
-		if newWidth <= 0 || newHeight <= 0 || newWidth > 16384 || newHeight > 16384 {
+		if err := checkDimensions("scaled working image", newWidth, newHeight, 16384); err != nil {
 			t.debugImage.LogAlgorithmStep("2D Otsu", fmt.Sprintf("ERROR: Invalid scaled dimensions: %dx%d", newWidth, newHeight))
 			t.debugPerf.EndOperation("2D_Otsu_Scaling")
-			return gocv.NewMat()
+			return gocv.NewMat(), err
 		}
 
 		workingImage = gocv.NewMat()
@@ -54,7 +63,7 @@ func (t *TwoDOtsu) applyWithScale(src gocv.Mat, scale float64) gocv.Mat {
 			t.debugImage.LogError(err)
 			workingImage.Close()
 			t.debugPerf.EndOperation("2D_Otsu_Scaling")
-			return gocv.NewMat()
+			return gocv.NewMat(), openCVError("resize", err)
 		}
 		t.debugImage.LogAlgorithmStep("2D Otsu", fmt.Sprintf("Scaled to %dx%d", newWidth, newHeight))
 		t.debugPerf.LogMatrixOperation("Resize", src, workingImage)
@@ -73,7 +82,7 @@ func (t *TwoDOtsu) applyWithScale(src gocv.Mat, scale float64) gocv.Mat {
 			t.debugImage.LogError(err)
 			grayscale.Close()
 			t.debugPerf.EndOperation("2D_Otsu_Grayscale")
-			return gocv.NewMat()
+			return gocv.NewMat(), openCVError("grayscale conversion", err)
 		}
 	} else {
 		grayscale = workingImage.Clone()
@@ -84,7 +93,7 @@ func (t *TwoDOtsu) applyWithScale(src gocv.Mat, scale float64) gocv.Mat {
 
 	if grayscale.Empty() {
 		t.debugImage.LogAlgorithmStep("2D Otsu", "ERROR: Grayscale conversion failed")
-		return gocv.NewMat()
+		return gocv.NewMat(), openCVError("grayscale conversion", nil)
 	}
 
 	t.debugImage.LogMatInfo("grayscale", grayscale)
@@ -93,7 +102,7 @@ func (t *TwoDOtsu) applyWithScale(src gocv.Mat, scale float64) gocv.Mat {
 	var denoisedGray gocv.Mat
 	if noiseReduction {
 		t.debugPerf.StartOperation("2D_Otsu_NoiseReduction", "bilateral_median_filter")
-		denoisedGray = t.applyHistoricalNoiseReduction(grayscale)
+		denoisedGray = historicalNoiseReduction(t.debugImage, grayscale)
 		t.debugPerf.LogMatrixOperation("NoiseReduction", grayscale, denoisedGray)
 		t.debugPerf.EndOperation("2D_Otsu_NoiseReduction")
 		defer denoisedGray.Close()
@@ -102,73 +111,119 @@ func (t *TwoDOtsu) applyWithScale(src gocv.Mat, scale float64) gocv.Mat {
 		defer denoisedGray.Close()
 	}
 
+	if err := ctx.Err(); err != nil {
+		t.debugImage.LogAlgorithmStep("2D Otsu", "Cancelled before thresholding")
+		return gocv.NewMat(), err
+	}
+	ReportProgress(ctx, 0.15)
+	thresholdCtx := ProgressRange(ctx, 0.15, 0.9)
+
 	// Choose between adaptive regional processing or global processing
 	var binaryResult gocv.Mat
-	if adaptiveRegions > 1 {
+	if manualThresholds {
+		t.debugPerf.StartOperation("2D_Otsu_Manual", fmt.Sprintf("s=%d, t=%d", manualS, manualT))
+		guided, guidedErr := guidedFilter(t.debugImage, t.debugPerf, denoisedGray, windowRadius, epsilon)
+		if guidedErr != nil {
+			t.debugPerf.EndOperation("2D_Otsu_Manual")
+			return gocv.NewMat(), guidedErr
+		}
+		binaryResult, err = t.applyManual2DOtsu(thresholdCtx, denoisedGray, guided, manualS, manualT)
+		guided.Close()
+		t.debugPerf.LogMatrixOperation("Manual", denoisedGray, binaryResult)
+		t.debugPerf.EndOperation("2D_Otsu_Manual")
+	} else if classes > 2 {
+		t.debugPerf.StartOperation("2D_Otsu_MultiLevel", fmt.Sprintf("classes=%d", classes))
+		guided, guidedErr := guidedFilter(t.debugImage, t.debugPerf, denoisedGray, windowRadius, epsilon)
+		if guidedErr != nil {
+			t.debugPerf.EndOperation("2D_Otsu_MultiLevel")
+			return gocv.NewMat(), guidedErr
+		}
+		binaryResult, err = t.applyMultiLevel2DOtsu(thresholdCtx, denoisedGray, guided, classes, multiLevelOutput)
+		guided.Close()
+		t.debugPerf.LogMatrixOperation("MultiLevel", denoisedGray, binaryResult)
+		t.debugPerf.EndOperation("2D_Otsu_MultiLevel")
+	} else if adaptiveRegions > 1 {
 		t.debugPerf.StartOperation("2D_Otsu_AdaptiveRegional", fmt.Sprintf("regions=%d", adaptiveRegions))
 		t.debugPerf.LogStep("2D_Otsu_AdaptiveRegional", "Starting regional processing", fmt.Sprintf("regions=%d", adaptiveRegions))
-		binaryResult = t.applyAdaptiveRegional2DOtsu(denoisedGray, adaptiveRegions, windowRadius, epsilon, useIntegralImage)
+		if regionMode == regionModeTiles {
+			binaryResult, err = t.applyAdaptiveRegional2DOtsu(thresholdCtx, denoisedGray, adaptiveRegions, windowRadius, epsilon, useIntegralImage)
+		} else {
+			binaryResult, err = t.applyBlendedRegional2DOtsu(thresholdCtx, denoisedGray, adaptiveRegions, regionOverlap, windowRadius, epsilon, useIntegralImage)
+		}
 		t.debugPerf.LogMatrixOperation("AdaptiveRegional", denoisedGray, binaryResult)
 		t.debugPerf.EndOperation("2D_Otsu_AdaptiveRegional")
+		if err == nil {
+			t.recordAnalysis(twoDOtsuAnalysis{
+				note: fmt.Sprintf("Thresholds vary across %dx%d regions; set manual thresholds to use one pair", adaptiveRegions, adaptiveRegions),
+			})
+		}
 	} else {
 		t.debugPerf.StartOperation("2D_Otsu_Global", "single_region")
 
 		t.debugPerf.StartOperation("2D_Otsu_GuidedFilter", "edge_preserving_smooth")
-		guided := t.applyGuidedFilter(denoisedGray, windowRadius, epsilon)
+		guided, guidedErr := guidedFilter(t.debugImage, t.debugPerf, denoisedGray, windowRadius, epsilon)
 		t.debugPerf.LogMatrixOperation("GuidedFilter", denoisedGray, guided)
 		t.debugPerf.EndOperation("2D_Otsu_GuidedFilter")
-		defer guided.Close()
-
-		if guided.Empty() {
-			t.debugImage.LogAlgorithmStep("2D Otsu", "ERROR: Guided filter failed")
-			guided = denoisedGray.Clone()
+		if guidedErr != nil {
+			t.debugPerf.EndOperation("2D_Otsu_Global")
+			return gocv.NewMat(), guidedErr
 		}
+		defer guided.Close()
 
+		var analysis twoDOtsuAnalysis
 		if useIntegralImage {
 			t.debugPerf.StartOperation("2D_Otsu_Integral", "optimized_algorithm")
 			size := denoisedGray.Size()
 			t.debugPerf.LogHistogramOperation("2D_Otsu_Integral", size, 256*256)
-			binaryResult = t.apply2DOtsuWithIntegralImage(denoisedGray, guided)
+			binaryResult, analysis, err = t.apply2DOtsuWithIntegralImage(thresholdCtx, denoisedGray, guided)
 			t.debugPerf.LogMatrixOperation("IntegralOtsu", denoisedGray, binaryResult)
 			t.debugPerf.EndOperation("2D_Otsu_Integral")
 		} else {
 			t.debugPerf.StartOperation("2D_Otsu_Standard", "standard_algorithm")
 			size := denoisedGray.Size()
 			t.debugPerf.LogHistogramOperation("2D_Otsu_Standard", size, 256*256)
-			binaryResult = t.apply2DOtsu(denoisedGray, guided)
+			binaryResult, analysis, err = t.apply2DOtsu(thresholdCtx, denoisedGray, guided)
 			t.debugPerf.LogMatrixOperation("StandardOtsu", denoisedGray, binaryResult)
 			t.debugPerf.EndOperation("2D_Otsu_Standard")
 		}
+		if err == nil {
+			t.recordAnalysis(analysis)
+		}
 		t.debugPerf.EndOperation("2D_Otsu_Global")
 	}
 
-	if binaryResult.Empty() {
-		t.debugImage.LogAlgorithmStep("2D Otsu", "ERROR: Binarization failed")
-		return gocv.NewMat()
+	if err != nil {
+		binaryResult.Close()
+		t.debugImage.LogAlgorithmStep("2D Otsu", fmt.Sprintf("Thresholding stopped: %v", err))
+		return gocv.NewMat(), err
 	}
 
 	t.debugPerf.StartOperation("2D_Otsu_Morphology", "cleanup_operations")
-	processed := t.applyMorphologicalOps(binaryResult, morphKernelSize)
+	processed := morphologicalCleanup(t.debugImage, binaryResult, morphKernelSize)
 	t.debugPerf.LogMatrixOperation("Morphology", binaryResult, processed)
 	t.debugPerf.EndOperation("2D_Otsu_Morphology")
 	binaryResult.Close()
 
 	if processed.Empty() {
 		t.debugImage.LogAlgorithmStep("2D Otsu", "ERROR: Morphological operations failed")
-		return gocv.NewMat()
+		return gocv.NewMat(), openCVError("morphology", nil)
 	}
 
-	var result gocv.Mat
 	if scale != 1.0 {
 		t.debugPerf.StartOperation("2D_Otsu_FinalResize", "restore_original_size")
 		result = gocv.NewMat()
-		err := gocv.Resize(processed, &result, image.Point{X: src.Cols(), Y: src.Rows()}, 0, 0, gocv.InterpolationLinear)
+		// Class labels must not be blended into values between classes
+		interpolation := gocv.InterpolationLinear
+		if classes > 2 {
+			interpolation = gocv.InterpolationNearestNeighbor
+		}
+		err := gocv.Resize(processed, &result, image.Point{X: src.Cols(), Y: src.Rows()}, 0, 0, interpolation)
 		processed.Close()
 		if err != nil {
 			t.debugImage.LogError(err)
 			result.Close()
 			t.debugPerf.EndOperation("2D_Otsu_FinalResize")
-			return gocv.NewMat()
+			return gocv.NewMat(), openCVError("resize", err)
 		}
 		t.debugImage.LogAlgorithmStep("2D Otsu", "Scaled back to original size")
 		t.debugPerf.LogMatrixOperation("FinalResize", processed, result)
@@ -177,94 +232,82 @@ func (t *TwoDOtsu) applyWithScale(src gocv.Mat, scale float64) gocv.Mat {
 		result = processed
 	}
 
+	ReportProgress(ctx, 1.0)
 	t.debugImage.LogAlgorithmStep("2D Otsu", "Completed successfully")
 	t.debugPerf.LogStep("2D_Otsu_Complete", "Algorithm completed", fmt.Sprintf("output=%dx%d", result.Cols(), result.Rows()))
-	return result
+	return result, nil
 }
 
-func (t *TwoDOtsu) applyHistoricalNoiseReduction(src gocv.Mat) gocv.Mat {
-	t.debugImage.LogAlgorithmStep("Historical Noise Reduction", "Applying bilateral filter for salt-and-pepper noise")
-
-	// Use bilateral filter to preserve edges while reducing noise
-	bilateralFiltered := gocv.NewMat()
-	err := gocv.BilateralFilter(src, &bilateralFiltered, 9, 75.0, 75.0)
-	if err != nil {
-		t.debugImage.LogError(err)
-		bilateralFiltered.Close()
-		return src.Clone()
-	}
-
-	// Apply median blur to further reduce salt-and-pepper noise
-	medianFiltered := gocv.NewMat()
-	err = gocv.MedianBlur(bilateralFiltered, &medianFiltered, 3)
-	bilateralFiltered.Close()
-	if err != nil {
-		t.debugImage.LogError(err)
-		medianFiltered.Close()
-		return src.Clone()
-	}
-
-	t.debugImage.LogFilter("HistoricalNoiseReduction", "bilateral+median")
-	return medianFiltered
-}
-
-func (t *TwoDOtsu) applyAdaptiveRegional2DOtsu(src gocv.Mat, regions int, windowRadius int, epsilon float64, useIntegralImage bool) gocv.Mat {
+func (t *TwoDOtsu) applyAdaptiveRegional2DOtsu(ctx context.Context, src gocv.Mat, regions int, windowRadius int, epsilon float64, useIntegralImage bool) (gocv.Mat, error) {
 	t.debugImage.LogAlgorithmStep("Adaptive Regional 2D Otsu", fmt.Sprintf("Processing %d regions", regions))
-	t.debugPerf.LogStep("2D_Otsu_AdaptiveRegional", "Region setup", fmt.Sprintf("total_regions=%d", regions*regions))
+	t.debugPerf.LogStep("2D_Otsu_AdaptiveRegional", "Region setup", fmt.Sprintf("total_regions=%d, workers=%d", regions*regions, tileWorkers(ctx)))
 
 	size := src.Size()
 	width, height := size[1], size[0]
 	result := gocv.NewMatWithSize(height, width, gocv.MatTypeCV8U)
 
-	regionWidth := width / regions
-	regionHeight := height / regions
-
 	totalRegions := regions * regions
+	var resultMutex sync.Mutex
 	processedRegions := 0
 
-	for i := 0; i < regions; i++ {
-		for j := 0; j < regions; j++ {
-			regionName := fmt.Sprintf("Region_%d_%d", i, j)
-			t.debugPerf.StartOperation(regionName, fmt.Sprintf("processing_region_%d_of_%d", processedRegions+1, totalRegions))
-
-			x1 := i * regionWidth
-			y1 := j * regionHeight
-			x2 := min((i+1)*regionWidth, width)
-			y2 := min((j+1)*regionHeight, height)
-
-			if x2 <= x1 || y2 <= y1 {
-				t.debugPerf.EndOperation(regionName)
-				continue
-			}
-
-			t.debugPerf.LogStep(regionName, "ROI extraction", fmt.Sprintf("rect=(%d,%d,%d,%d)", x1, y1, x2-x1, y2-y1))
-
-			roi := src.Region(image.Rect(x1, y1, x2, y2))
-			guided := t.applyGuidedFilter(roi, windowRadius, epsilon)
+	// Regions only read src; each one owns its ROI views and intermediate
+	// Mats, and writes into its own part of result
+	err := runTiles(ctx, totalRegions, tileWorkers(ctx), func(ctx context.Context, worker, tile int) error {
+		i, j := tile%regions, tile/regions
+		x1, x2 := regionBounds(width, regions, i)
+		y1, y2 := regionBounds(height, regions, j)
+		if x2 <= x1 || y2 <= y1 {
+			return nil
+		}
 
-			var regionResult gocv.Mat
-			if useIntegralImage {
-				regionResult = t.apply2DOtsuWithIntegralImage(roi, guided)
-			} else {
-				regionResult = t.apply2DOtsu(roi, guided)
-			}
+		regionName := fmt.Sprintf("Region_%d_%d", i, j)
+		t.debugPerf.StartOperation(regionName, fmt.Sprintf("processing_region_%d_of_%d_worker_%d", tile+1, totalRegions, worker))
+		defer t.debugPerf.EndOperation(regionName)
+		t.debugPerf.LogStep(regionName, "ROI extraction", fmt.Sprintf("rect=(%d,%d,%d,%d)", x1, y1, x2-x1, y2-y1))
 
-			roi.Close()
-			guided.Close()
+		roi := src.Region(image.Rect(x1, y1, x2, y2))
+		defer roi.Close()
+		guided, err := guidedFilter(t.debugImage, t.debugPerf, roi, windowRadius, epsilon)
+		if err != nil {
+			return fmt.Errorf("region %d,%d: %w", i, j, err)
+		}
+		defer guided.Close()
 
-			if !regionResult.Empty() {
-				resultROI := result.Region(image.Rect(x1, y1, x2, y2))
-				regionResult.CopyTo(&resultROI)
-				resultROI.Close()
+		var regionResult gocv.Mat
+		if useIntegralImage {
+			regionResult, _, err = t.apply2DOtsuWithIntegralImage(ctx, roi, guided)
+		} else {
+			regionResult, _, err = t.apply2DOtsu(ctx, roi, guided)
+		}
+		defer regionResult.Close()
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
 			}
-			regionResult.Close()
-
-			processedRegions++
-			t.debugPerf.LogStep("2D_Otsu_AdaptiveRegional", "Region completed", fmt.Sprintf("progress=%d/%d", processedRegions, totalRegions))
-			t.debugPerf.EndOperation(regionName)
+			return fmt.Errorf("region %d,%d: %w", i, j, err)
 		}
+
+		resultMutex.Lock()
+		resultROI := result.Region(image.Rect(x1, y1, x2, y2))
+		regionResult.CopyTo(&resultROI)
+		resultROI.Close()
+		processedRegions++
+		t.debugPerf.LogStep("2D_Otsu_AdaptiveRegional", "Region completed", fmt.Sprintf("progress=%d/%d", processedRegions, totalRegions))
+		resultMutex.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.debugImage.LogAlgorithmStep("Adaptive Regional 2D Otsu", fmt.Sprintf("Stopped after %d/%d regions: %v", processedRegions, totalRegions, err))
+		result.Close()
+		return gocv.NewMat(), err
 	}
 
 	t.debugImage.LogAlgorithmStep("Adaptive Regional 2D Otsu", "Regional processing completed")
-	return result
+	return result, nil
+}
+
+// regionBounds splits length into regions parts and returns the start and
+// end of part i. The parts differ by at most one pixel and cover every pixel.
+func regionBounds(length, regions, i int) (int, int) {
+	return i * length / regions, (i + 1) * length / regions
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// applyBlendedRegional2DOtsu finds 2D Otsu thresholds for a regions×regions
+// grid of overlapping windows and interpolates them bilinearly between the
+// region centres, so neighbouring regions meet without seams.
+func (t *TwoDOtsu) applyBlendedRegional2DOtsu(ctx context.Context, src gocv.Mat, regions int, overlap float64, windowRadius int, epsilon float64, useIntegralImage bool) (gocv.Mat, error) {
+	t.debugImage.LogAlgorithmStep("Blended Regional 2D Otsu", fmt.Sprintf("Processing %d regions with overlap %.2f", regions, overlap))
+
+	width, height := src.Cols(), src.Rows()
+
+	// One guided filter over the whole image keeps the mean continuous across regions
+	guided, err := guidedFilter(t.debugImage, t.debugPerf, src, windowRadius, epsilon)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	defer guided.Close()
+
+	grayThresholds := make([][]float64, regions)
+	meanThresholds := make([][]float64, regions)
+	centresX := make([]float64, regions)
+	centresY := make([]float64, regions)
+	for k := 0; k < regions; k++ {
+		grayThresholds[k] = make([]float64, regions)
+		meanThresholds[k] = make([]float64, regions)
+		x1, x2 := regionBounds(width, regions, k)
+		y1, y2 := regionBounds(height, regions, k)
+		centresX[k] = float64(x1+x2-1) / 2
+		centresY[k] = float64(y1+y2-1) / 2
+	}
+
+	totalRegions := regions * regions
+	t.debugPerf.LogStep("2D_Otsu_AdaptiveRegional", "Region setup", fmt.Sprintf("total_regions=%d, workers=%d", totalRegions, tileWorkers(ctx)))
+
+	// Every region writes only its own grid cell, so no locking is needed
+	err = runTiles(ProgressRange(ctx, 0, 0.8), totalRegions, tileWorkers(ctx), func(ctx context.Context, worker, tile int) error {
+		i, j := tile%regions, tile/regions
+		x1, x2 := regionBounds(width, regions, i)
+		y1, y2 := regionBounds(height, regions, j)
+
+		regionName := fmt.Sprintf("Region_%d_%d", i, j)
+		t.debugPerf.StartOperation(regionName, fmt.Sprintf("processing_region_%d_of_%d_worker_%d", tile+1, totalRegions, worker))
+		defer t.debugPerf.EndOperation(regionName)
+
+		window := expandRegion(image.Rect(x1, y1, x2, y2), overlap, width, height)
+		t.debugPerf.LogStep(regionName, "Window extraction", fmt.Sprintf("rect=(%d,%d,%d,%d)", window.Min.X, window.Min.Y, window.Dx(), window.Dy()))
+
+		grayROI := src.Region(window)
+		defer grayROI.Close()
+		guidedROI := guided.Region(window)
+		defer guidedROI.Close()
+
+		s, threshold, err := t.find2DOtsuThresholds(ctx, grayROI, guidedROI, useIntegralImage)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			return fmt.Errorf("region %d,%d: %w", i, j, err)
+		}
+
+		grayThresholds[j][i] = float64(s)
+		meanThresholds[j][i] = float64(threshold)
+		return nil
+	})
+	if err != nil {
+		t.debugImage.LogAlgorithmStep("Blended Regional 2D Otsu", fmt.Sprintf("Stopped: %v", err))
+		return gocv.NewMat(), err
+	}
+
+	t.debugPerf.StartOperation("2D_Otsu_BlendedClassification", "bilinear_threshold_interpolation")
+	defer t.debugPerf.EndOperation("2D_Otsu_BlendedClassification")
+
+	// DataPtrUint8 needs continuous memory, which full-size Mats from
+	// CvtColor and the guided filter have
+	grayPixels, err := src.DataPtrUint8()
+	if err != nil {
+		return gocv.NewMat(), openCVError("read pixels", err)
+	}
+	guidedPixels, err := guided.DataPtrUint8()
+	if err != nil {
+		return gocv.NewMat(), openCVError("read pixels", err)
+	}
+
+	result := gocv.NewMatWithSize(height, width, gocv.MatTypeCV8U)
+	output, err := result.DataPtrUint8()
+	if err != nil {
+		result.Close()
+		return gocv.NewMat(), openCVError("write pixels", err)
+	}
+
+	columns := make([]interpolationWeight, width)
+	for x := range columns {
+		columns[x] = interpolationAt(centresX, float64(x))
+	}
+
+	classifyCtx := ProgressRange(ctx, 0.8, 1.0)
+	for y := 0; y < height; y++ {
+		if y%64 == 0 {
+			if err := ctx.Err(); err != nil {
+				result.Close()
+				return gocv.NewMat(), err
+			}
+			ReportProgress(classifyCtx, float64(y)/float64(height))
+		}
+
+		row := interpolationAt(centresY, float64(y))
+		for x := 0; x < width; x++ {
+			column := columns[x]
+			s := row.blend(grayThresholds, column)
+			threshold := row.blend(meanThresholds, column)
+
+			index := y*width + x
+			if float64(grayPixels[index]) > s && float64(guidedPixels[index]) > threshold {
+				output[index] = 255
+			} else {
+				output[index] = 0
+			}
+		}
+	}
+
+	ReportProgress(ctx, 1.0)
+	t.debugImage.LogAlgorithmStep("Blended Regional 2D Otsu", "Regional processing completed")
+	return result, nil
+}
+
+// find2DOtsuThresholds returns the gray level and local mean thresholds of
+// one window without binarizing it.
+func (t *TwoDOtsu) find2DOtsuThresholds(ctx context.Context, gray, guided gocv.Mat, useIntegralImage bool) (int, int, error) {
+	hist, err := t.buildHistogram2D(ctx, gray, guided)
+	if err != nil {
+		return 0, 0, err
+	}
+	if gray.Total() == 0 {
+		return 0, 0, fmt.Errorf("2D Otsu input has no pixels")
+	}
+	stats := newCumulativeStats(hist)
+
+	var s, threshold int
+	var maxVariance float64
+	if useIntegralImage {
+		s, threshold, maxVariance = t.findOptimalThresholdsWithIntegralImage(stats)
+	} else {
+		s, threshold, maxVariance = t.findOptimalThresholdsRecursive(stats)
+	}
+	t.debugImage.LogOptimalThresholds(s, threshold, maxVariance)
+	return s, threshold, nil
+}
+
+// expandRegion grows rect by overlap times its size on every side, clipped
+// to the image.
+func expandRegion(rect image.Rectangle, overlap float64, width, height int) image.Rectangle {
+	dx := int(overlap * float64(rect.Dx()))
+	dy := int(overlap * float64(rect.Dy()))
+	return image.Rect(
+		max(rect.Min.X-dx, 0), max(rect.Min.Y-dy, 0),
+		min(rect.Max.X+dx, width), min(rect.Max.Y+dy, height),
+	)
+}
+
+// interpolationWeight selects the two region centres around a coordinate
+// and the weight of the second one.
+type interpolationWeight struct {
+	first, second int
+	weight        float64
+}
+
+// interpolationAt finds the centres around position. Positions before the
+// first or after the last centre use that centre alone.
+func interpolationAt(centres []float64, position float64) interpolationWeight {
+	last := len(centres) - 1
+	if position <= centres[0] {
+		return interpolationWeight{}
+	}
+	if position >= centres[last] {
+		return interpolationWeight{first: last, second: last}
+	}
+
+	i := 0
+	for position >= centres[i+1] {
+		i++
+	}
+	return interpolationWeight{
+		first:  i,
+		second: i + 1,
+		weight: (position - centres[i]) / (centres[i+1] - centres[i]),
+	}
+}
+
+// blend interpolates grid[row][column] bilinearly, with r the row weight
+func (r interpolationWeight) blend(grid [][]float64, c interpolationWeight) float64 {
+	top := grid[r.first][c.first]*(1-c.weight) + grid[r.first][c.second]*c.weight
+	bottom := grid[r.second][c.first]*(1-c.weight) + grid[r.second][c.second]*c.weight
+	return top*(1-r.weight) + bottom*r.weight
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// histogram2D counts pixels by gray level g and local mean f, flat and
+// indexed by g<<8 | f.
+type histogram2D [65536]uint32
+
+// buildHistogram2D counts gray/guided pairs from the raw Mat bytes. Bands of
+// rows are counted in parallel into per-worker histograms and merged.
+func (t *TwoDOtsu) buildHistogram2D(ctx context.Context, gray, guided gocv.Mat) (*histogram2D, error) {
+	t.debugImage.LogAlgorithmStep("2D Histogram", "Building flat histogram from raw pixel data")
+
+	if gray.Rows() != guided.Rows() || gray.Cols() != guided.Cols() {
+		return nil, fmt.Errorf("2D histogram inputs differ in size: %dx%d and %dx%d", gray.Cols(), gray.Rows(), guided.Cols(), guided.Rows())
+	}
+
+	grayPixels, releaseGray, err := continuousPixels(gray)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseGray()
+	guidedPixels, releaseGuided, err := continuousPixels(guided)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseGuided()
+
+	width, height := gray.Cols(), gray.Rows()
+
+	bandRows := 256
+	totalBands := (height + bandRows - 1) / bandRows
+	workers := min(tileWorkers(ctx), max(totalBands, 1))
+	partials := make([]histogram2D, workers)
+	startTime := time.Now()
+
+	t.debugPerf.LogStep("2D_Histogram", "Processing setup", fmt.Sprintf("size=%dx%d, bands=%d, workers=%d", width, height, totalBands, workers))
+
+	var progressMutex sync.Mutex
+	processedBands := 0
+	err = runTiles(ctx, totalBands, workers, func(ctx context.Context, worker, band int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		hist := &partials[worker]
+		start := band * bandRows * width
+		end := min((band+1)*bandRows, height) * width
+		guidedBand := guidedPixels[start:end]
+		for i, g := range grayPixels[start:end] {
+			hist[int(g)<<8|int(guidedBand[i])]++
+		}
+
+		progressMutex.Lock()
+		processedBands++
+		t.debugPerf.LogLoopProgress("2D_Histogram", processedBands, totalBands, startTime)
+		progressMutex.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.debugImage.LogAlgorithmStep("2D Histogram", "Cancelled")
+		return nil, err
+	}
+
+	hist := &partials[0]
+	for w := 1; w < len(partials); w++ {
+		for i, count := range partials[w] {
+			hist[i] += count
+		}
+	}
+
+	ReportProgress(ctx, 1.0)
+	return hist, nil
+}
+
+// continuousPixels returns the bytes of a single channel 8-bit Mat. Region
+// views are not continuous and are copied first; release closes the copy.
+func continuousPixels(mat gocv.Mat) ([]uint8, func(), error) {
+	if mat.Type() != gocv.MatTypeCV8U {
+		return nil, nil, fmt.Errorf("expected a single channel 8-bit image, got %v", mat.Type())
+	}
+
+	source := mat
+	release := func() {}
+	if !mat.IsContinuous() {
+		source = mat.Clone()
+		release = func() { source.Close() }
+	}
+
+	pixels, err := source.DataPtrUint8()
+	if err != nil {
+		release()
+		return nil, nil, openCVError("read pixels", err)
+	}
+	return pixels, release, nil
+}
+
+// CumulativeStats holds summed area tables of the normalized 2D histogram
+// for the probability and the first moments in g and f. The tables are flat
+// and indexed like histogram2D, so the statistics of any rectangle of
+// (g, f) values cost four lookups.
+type CumulativeStats struct {
+	cumulativeP   []float64
+	cumulativeMuG []float64
+	cumulativeMuF []float64
+	totalMeanG    float64
+	totalMeanF    float64
+}
+
+func newCumulativeStats(hist *histogram2D) *CumulativeStats {
+	stats := &CumulativeStats{
+		cumulativeP:   make([]float64, len(hist)),
+		cumulativeMuG: make([]float64, len(hist)),
+		cumulativeMuF: make([]float64, len(hist)),
+	}
+
+	total := 0.0
+	for _, count := range hist {
+		total += float64(count)
+	}
+	if total == 0 {
+		return stats
+	}
+	invTotal := 1.0 / total
+
+	for g := 0; g < 256; g++ {
+		// Running sums along the current row, added to the row above
+		rowP, rowMuG, rowMuF := 0.0, 0.0, 0.0
+		for f := 0; f < 256; f++ {
+			index := g<<8 | f
+			prob := float64(hist[index]) * invTotal
+			rowP += prob
+			rowMuG += float64(g) * prob
+			rowMuF += float64(f) * prob
+
+			stats.cumulativeP[index] = rowP
+			stats.cumulativeMuG[index] = rowMuG
+			stats.cumulativeMuF[index] = rowMuF
+			if g > 0 {
+				stats.cumulativeP[index] += stats.cumulativeP[index-256]
+				stats.cumulativeMuG[index] += stats.cumulativeMuG[index-256]
+				stats.cumulativeMuF[index] += stats.cumulativeMuF[index-256]
+			}
+		}
+	}
+
+	stats.totalMeanG = stats.cumulativeMuG[65535]
+	stats.totalMeanF = stats.cumulativeMuF[65535]
+	return stats
+}
+
+// region sums table over g1..g2 and f1..f2 inclusive. Empty or out of range
+// rectangles sum to zero.
+func (c *CumulativeStats) region(table []float64, g1, f1, g2, f2 int) float64 {
+	if g1 < 0 || f1 < 0 || g2 > 255 || f2 > 255 || g1 > g2 || f1 > f2 {
+		return 0
+	}
+
+	sum := table[g2<<8|f2]
+	if g1 > 0 {
+		sum -= table[(g1-1)<<8|f2]
+	}
+	if f1 > 0 {
+		sum -= table[g2<<8|(f1-1)]
+	}
+	if g1 > 0 && f1 > 0 {
+		sum += table[(g1-1)<<8|(f1-1)]
+	}
+	return sum
+}
+
+// classStats returns the weight and mean g and f of a rectangle of the histogram
+func (c *CumulativeStats) classStats(g1, f1, g2, f2 int) (weight, meanG, meanF float64) {
+	weight = c.region(c.cumulativeP, g1, f1, g2, f2)
+	if weight <= 1e-10 {
+		return weight, 0, 0
+	}
+	return weight, c.region(c.cumulativeMuG, g1, f1, g2, f2) / weight, c.region(c.cumulativeMuF, g1, f1, g2, f2) / weight
+}
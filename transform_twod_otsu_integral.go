@@ -1,74 +1,45 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"gocv.io/x/gocv"
 )
 
-func (t *TwoDOtsu) apply2DOtsuWithIntegralImage(gray, guided gocv.Mat) gocv.Mat {
-	t.debugImage.LogAlgorithmStep("2D Otsu Integral", "Using CalcHist API and integral image acceleration")
+// apply2DOtsuWithIntegralImage also returns the histogram and thresholds
+// it used, which the caller records when they describe the whole image.
+func (t *TwoDOtsu) apply2DOtsuWithIntegralImage(ctx context.Context, gray, guided gocv.Mat) (gocv.Mat, twoDOtsuAnalysis, error) {
+	t.debugImage.LogAlgorithmStep("2D Otsu Integral", "Using flat histogram and integral image acceleration")
 	t.debugPerf.LogAlgorithmPhase("2D Otsu Integral", "Starting accelerated algorithm", gray)
 
 	if gray.Empty() || guided.Empty() {
-		return gocv.NewMat()
+		return gocv.NewMat(), twoDOtsuAnalysis{}, fmt.Errorf("2D Otsu input is empty")
 	}
 
-	// Use GoCV's CalcHist for hardware-accelerated histogram calculation
-	t.debugPerf.StartOperation("2D_Otsu_Integral_Hist", "calcHist_validation")
-	grayHist := gocv.NewMat()
-	defer grayHist.Close()
-	guidedHist := gocv.NewMat()
-	defer guidedHist.Close()
-
-	// Calculate individual histograms first for validation
-	err := gocv.CalcHist([]gocv.Mat{gray}, []int{0}, gocv.NewMat(), &grayHist, []int{256}, []float64{0, 256}, false)
-	if err != nil {
-		t.debugImage.LogError(err)
-		t.debugPerf.EndOperation("2D_Otsu_Integral_Hist")
-		return gocv.NewMat()
-	}
-
-	err = gocv.CalcHist([]gocv.Mat{guided}, []int{0}, gocv.NewMat(), &guidedHist, []int{256}, []float64{0, 256}, false)
-	if err != nil {
-		t.debugImage.LogError(err)
-		t.debugPerf.EndOperation("2D_Otsu_Integral_Hist")
-		return gocv.NewMat()
-	}
-	t.debugPerf.EndOperation("2D_Otsu_Integral_Hist")
-
-	// Build 2D histogram using vectorized operations
 	t.debugPerf.StartOperation("2D_Otsu_Integral_2DHist", "fast_2d_histogram")
-	hist2D := t.build2DHistogramFast(gray, guided)
-	defer func() {
-		for i := range hist2D {
-			hist2D[i] = nil
-		}
-	}()
+	hist, err := t.buildHistogram2D(ProgressRange(ctx, 0, 0.8), gray, guided)
 	t.debugPerf.EndOperation("2D_Otsu_Integral_2DHist")
+	if err != nil {
+		return gocv.NewMat(), twoDOtsuAnalysis{}, err
+	}
 
 	totalPixels := gray.Total()
 	if totalPixels == 0 {
 		t.debugImage.LogAlgorithmStep("2D Otsu Integral", "ERROR: No pixels to process")
-		return gocv.NewMat()
+		return gocv.NewMat(), twoDOtsuAnalysis{}, fmt.Errorf("2D Otsu input has no pixels")
 	}
 
 	t.debugPerf.LogStep("2D_Otsu_Integral", "Histogram normalization", fmt.Sprintf("total_pixels=%d", totalPixels))
 
-	// Normalize histogram
-	t.debugPerf.StartOperation("2D_Otsu_Integral_Normalize", "vectorized_normalization")
-	invTotalPixels := 1.0 / float64(totalPixels)
-	for g := 0; g < 256; g++ {
-		for f := 0; f < 256; f++ {
-			hist2D[g][f] *= invTotalPixels
-		}
-	}
-	t.debugPerf.EndOperation("2D_Otsu_Integral_Normalize")
+	// Summed area tables over the normalized histogram
+	t.debugPerf.StartOperation("2D_Otsu_Integral_Tables", "summed_area_table_construction")
+	stats := newCumulativeStats(hist)
+	t.debugPerf.EndOperation("2D_Otsu_Integral_Tables")
 
-	// Use fast recursive dynamic programming for threshold optimization
 	t.debugPerf.StartOperation("2D_Otsu_Integral_Search", "integral_image_optimization")
-	bestS, bestT, maxVariance := t.findOptimalThresholdsWithIntegralImage(hist2D)
+	bestS, bestT, maxVariance := t.findOptimalThresholdsWithIntegralImage(stats)
 	t.debugPerf.EndOperation("2D_Otsu_Integral_Search")
 	t.debugImage.LogOptimalThresholds(bestS, bestT, maxVariance)
 
@@ -77,109 +48,20 @@ func (t *TwoDOtsu) apply2DOtsuWithIntegralImage(gray, guided gocv.Mat) gocv.Mat
 	result := t.applyVectorizedBinarization(gray, guided, bestS, bestT)
 	t.debugPerf.LogMatrixOperation("IntegralBinarization", gray, result)
 	t.debugPerf.EndOperation("2D_Otsu_Integral_Binarize")
+	ReportProgress(ctx, 1.0)
 
 	t.debugImage.LogAlgorithmStep("2D Otsu Integral", "Binarization with integral image completed")
-	return result
-}
-
-func (t *TwoDOtsu) build2DHistogramFast(gray, guided gocv.Mat) [][]float64 {
-	t.debugImage.LogAlgorithmStep("2D Histogram", "Building histogram using fast vectorized operations")
-
-	hist := make([][]float64, 256)
-	for i := range hist {
-		hist[i] = make([]float64, 256)
-	}
-
-	size := gray.Size()
-	width, height := size[1], size[0]
-	totalPixels := width * height
-
-	t.debugPerf.LogStep("2D_Histogram_Fast", "Processing setup", fmt.Sprintf("size=%dx%d", width, height))
-
-	// Process in blocks for better cache efficiency
-	blockSize := 64
-	totalBlocks := ((height + blockSize - 1) / blockSize) * ((width + blockSize - 1) / blockSize)
-	processedBlocks := 0
-	startTime := time.Now()
-
-	for y := 0; y < height; y += blockSize {
-		yEnd := min(y+blockSize, height)
-		for x := 0; x < width; x += blockSize {
-			xEnd := min(x+blockSize, width)
-
-			// Process block
-			for blockY := y; blockY < yEnd; blockY++ {
-				for blockX := x; blockX < xEnd; blockX++ {
-					gVal := int(gray.GetUCharAt(blockY, blockX))
-					fVal := int(guided.GetUCharAt(blockY, blockX))
-
-					if gVal >= 0 && gVal < 256 && fVal >= 0 && fVal < 256 {
-						hist[gVal][fVal]++
-					}
-				}
-			}
-
-			processedBlocks++
-			if processedBlocks%1000 == 0 { // Reduced frequency
-				t.debugPerf.LogLoopProgress("2D_Histogram_Fast", processedBlocks, totalBlocks, startTime)
-			}
-		}
-	}
-
-	t.debugPerf.LogStep("2D_Histogram_Fast", "Histogram construction completed", fmt.Sprintf("processed_pixels=%d", totalPixels))
-
-	return hist
+	return result, twoDOtsuAnalysis{
+		hist: hist, s: bestS, t: bestT, hasThresholds: true,
+		note: fmt.Sprintf("Otsu thresholds s=%d, t=%d", bestS, bestT),
+	}, nil
 }
 
-func (t *TwoDOtsu) findOptimalThresholdsWithIntegralImage(hist [][]float64) (int, int, float64) {
+func (t *TwoDOtsu) findOptimalThresholdsWithIntegralImage(stats *CumulativeStats) (int, int, float64) {
 	t.debugImage.LogAlgorithmStep("Integral Image Optimization", "Using summed area tables for acceleration")
-	t.debugPerf.LogAlgorithmPhase("Integral Image Optimization", "Building lookup tables", gocv.NewMat())
-
-	// Pre-compute integral images for different statistics
-	t.debugPerf.StartOperation("2D_Otsu_Integral_Tables", "summed_area_table_construction")
-	integralP := make([][]float64, 256)
-	integralMuG := make([][]float64, 256)
-	integralMuF := make([][]float64, 256)
-
-	for i := range integralP {
-		integralP[i] = make([]float64, 256)
-		integralMuG[i] = make([]float64, 256)
-		integralMuF[i] = make([]float64, 256)
-	}
-
-	// Build integral images
-	for g := 0; g < 256; g++ {
-		for f := 0; f < 256; f++ {
-			prob := hist[g][f]
-			mgVal := float64(g) * prob
-			mfVal := float64(f) * prob
-
-			integralP[g][f] = prob
-			integralMuG[g][f] = mgVal
-			integralMuF[g][f] = mfVal
 
-			if g > 0 {
-				integralP[g][f] += integralP[g-1][f]
-				integralMuG[g][f] += integralMuG[g-1][f]
-				integralMuF[g][f] += integralMuF[g-1][f]
-			}
-			if f > 0 {
-				integralP[g][f] += integralP[g][f-1]
-				integralMuG[g][f] += integralMuG[g][f-1]
-				integralMuF[g][f] += integralMuF[g][f-1]
-			}
-			if g > 0 && f > 0 {
-				integralP[g][f] -= integralP[g-1][f-1]
-				integralMuG[g][f] -= integralMuG[g-1][f-1]
-				integralMuF[g][f] -= integralMuF[g-1][f-1]
-			}
-		}
-	}
-	t.debugPerf.EndOperation("2D_Otsu_Integral_Tables")
-
-	// Calculate global means using integral images
-	totalMeanG := integralMuG[255][255]
-	totalMeanF := integralMuF[255][255]
+	totalMeanG := stats.totalMeanG
+	totalMeanF := stats.totalMeanF
 
 	t.debugPerf.LogStep("2D_Otsu_Integral_Search", "Global statistics", fmt.Sprintf("meanG=%.3f, meanF=%.3f", totalMeanG, totalMeanF))
 
@@ -194,7 +76,7 @@ func (t *TwoDOtsu) findOptimalThresholdsWithIntegralImage(hist [][]float64) (int
 	// Use integral images for O(1) region queries
 	for s := 1; s < 255; s++ {
 		for thresholdT := 1; thresholdT < 255; thresholdT++ {
-			variance := t.calculateVarianceWithIntegralImage(integralP, integralMuG, integralMuF, s, thresholdT, totalMeanG, totalMeanF)
+			variance := t.calculateVarianceWithIntegralImage(stats, s, thresholdT)
 
 			if variance > maxBetweenClassVariance {
 				maxBetweenClassVariance = variance
@@ -216,35 +98,15 @@ func (t *TwoDOtsu) findOptimalThresholdsWithIntegralImage(hist [][]float64) (int
 	return bestS, bestT, maxBetweenClassVariance
 }
 
-func (t *TwoDOtsu) calculateVarianceWithIntegralImage(integralP, integralMuG, integralMuF [][]float64, s, thresholdT int, totalMeanG, totalMeanF float64) float64 {
-	// Query regions using O(1) integral image lookups
-	getRegionSum := func(integral [][]float64, x1, y1, x2, y2 int) float64 {
-		sum := integral[x2][y2]
-		if x1 > 0 {
-			sum -= integral[x1-1][y2]
-		}
-		if y1 > 0 {
-			sum -= integral[x2][y1-1]
-		}
-		if x1 > 0 && y1 > 0 {
-			sum += integral[x1-1][y1-1]
-		}
-		return sum
-	}
-
-	// Calculate class statistics using integral images
-	w0 := getRegionSum(integralP, 0, 0, s, thresholdT)         // Background
-	w3 := getRegionSum(integralP, s+1, thresholdT+1, 255, 255) // Foreground
+func (t *TwoDOtsu) calculateVarianceWithIntegralImage(stats *CumulativeStats, s, thresholdT int) float64 {
+	// Calculate class statistics using O(1) summed area table lookups
+	w0, mu0G, mu0F := stats.classStats(0, 0, s, thresholdT)         // Background
+	w3, mu3G, mu3F := stats.classStats(s+1, thresholdT+1, 255, 255) // Foreground
 
 	if w0 <= 1e-10 || w3 <= 1e-10 {
 		return 0.0
 	}
 
-	mu0G := getRegionSum(integralMuG, 0, 0, s, thresholdT) / w0
-	mu0F := getRegionSum(integralMuF, 0, 0, s, thresholdT) / w0
-	mu3G := getRegionSum(integralMuG, s+1, thresholdT+1, 255, 255) / w3
-	mu3F := getRegionSum(integralMuF, s+1, thresholdT+1, 255, 255) / w3
-
 	// Calculate between-class variance with edge preservation weighting
 	diffG := mu0G - mu3G
 	diffF := mu0F - mu3F
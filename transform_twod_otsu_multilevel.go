@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	multiLevelPosterized = "posterized"
+	multiLevelLabels     = "labels"
+)
+
+// applyMultiLevel2DOtsu splits the joint histogram into classes diagonal
+// blocks, darkest first, and writes either the class index of every pixel
+// or evenly spaced gray levels from black to white.
+func (t *TwoDOtsu) applyMultiLevel2DOtsu(ctx context.Context, gray, guided gocv.Mat, classes int, output string) (gocv.Mat, error) {
+	t.debugImage.LogAlgorithmStep("Multi-level 2D Otsu", fmt.Sprintf("Separating %d classes (%s output)", classes, output))
+
+	t.debugPerf.StartOperation("2D_Otsu_MultiLevel_Histogram", "joint_histogram_construction")
+	hist, err := t.buildHistogram2D(ProgressRange(ctx, 0, 0.5), gray, guided)
+	t.debugPerf.EndOperation("2D_Otsu_MultiLevel_Histogram")
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	if gray.Total() == 0 {
+		return gocv.NewMat(), fmt.Errorf("2D Otsu input has no pixels")
+	}
+	stats := newCumulativeStats(hist)
+
+	if err := ctx.Err(); err != nil {
+		return gocv.NewMat(), err
+	}
+
+	t.debugPerf.StartOperation("2D_Otsu_MultiLevel_Search", fmt.Sprintf("classes=%d", classes))
+	grayThresholds, meanThresholds, variance := t.findMultiLevelThresholds(stats, classes)
+	t.debugPerf.EndOperation("2D_Otsu_MultiLevel_Search")
+	t.debugImage.LogAlgorithmStep("Multi-level 2D Otsu", fmt.Sprintf("Thresholds s=%v t=%v (variance %.6f)", grayThresholds, meanThresholds, variance))
+	ReportProgress(ctx, 0.9)
+	t.recordAnalysis(twoDOtsuAnalysis{
+		hist: hist,
+		note: fmt.Sprintf("%d classes: s=%v, t=%v", classes, grayThresholds, meanThresholds),
+	})
+
+	levels := make([]uint8, classes)
+	for class := range levels {
+		if output == multiLevelLabels {
+			levels[class] = uint8(class)
+		} else {
+			levels[class] = uint8(class * 255 / (classes - 1))
+		}
+	}
+	lookup := multiLevelLookup(stats, grayThresholds, meanThresholds)
+
+	grayPixels, releaseGray, err := continuousPixels(gray)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	defer releaseGray()
+	guidedPixels, releaseGuided, err := continuousPixels(guided)
+	if err != nil {
+		return gocv.NewMat(), err
+	}
+	defer releaseGuided()
+
+	result := gocv.NewMatWithSize(gray.Rows(), gray.Cols(), gocv.MatTypeCV8U)
+	pixels, err := result.DataPtrUint8()
+	if err != nil {
+		result.Close()
+		return gocv.NewMat(), openCVError("write pixels", err)
+	}
+	for i, g := range grayPixels {
+		pixels[i] = levels[lookup[int(g)<<8|int(guidedPixels[i])]]
+	}
+
+	ReportProgress(ctx, 1.0)
+	return result, nil
+}
+
+// findMultiLevelThresholds returns classes-1 ascending gray and mean
+// thresholds. An exhaustive search along the diagonal s = t, where most
+// pixels of a scan lie, is refined one threshold at a time until the
+// between-class variance stops improving.
+func (t *TwoDOtsu) findMultiLevelThresholds(stats *CumulativeStats, classes int) ([]int, []int, float64) {
+	n := classes - 1
+	diagonal := make([]int, n)
+	bestDiagonal := make([]int, n)
+	best := 0.0
+
+	var search func(level, start int)
+	search = func(level, start int) {
+		if level == n {
+			if variance := multiLevelVariance(stats, diagonal, diagonal); variance > best {
+				best = variance
+				copy(bestDiagonal, diagonal)
+			}
+			return
+		}
+		for d := start; d <= 254-(n-1-level); d++ {
+			diagonal[level] = d
+			search(level+1, d+1)
+		}
+	}
+	search(0, 0)
+
+	grayThresholds := slices.Clone(bestDiagonal)
+	meanThresholds := slices.Clone(bestDiagonal)
+	for pass := 0; pass < 8; pass++ {
+		improved := false
+		for i := 0; i < n; i++ {
+			for _, thresholds := range [][]int{grayThresholds, meanThresholds} {
+				low, high := 0, 254
+				if i > 0 {
+					low = thresholds[i-1] + 1
+				}
+				if i < n-1 {
+					high = thresholds[i+1] - 1
+				}
+
+				chosen := thresholds[i]
+				for value := low; value <= high; value++ {
+					thresholds[i] = value
+					if variance := multiLevelVariance(stats, grayThresholds, meanThresholds); variance > best {
+						best = variance
+						chosen = value
+						improved = true
+					}
+				}
+				thresholds[i] = chosen
+			}
+		}
+		t.debugPerf.LogStep("2D_Otsu_MultiLevel_Search", "Refinement pass", fmt.Sprintf("pass=%d, variance=%.6f", pass+1, best))
+		if !improved {
+			break
+		}
+	}
+
+	return grayThresholds, meanThresholds, best
+}
+
+// multiLevelVariance is the between-class variance of the diagonal blocks
+// (s[i-1], s[i]] x (t[i-1], t[i]], using O(1) CumulativeStats queries.
+func multiLevelVariance(stats *CumulativeStats, grayThresholds, meanThresholds []int) float64 {
+	variance := 0.0
+	g1, f1 := 0, 0
+	for i := 0; i <= len(grayThresholds); i++ {
+		g2, f2 := 255, 255
+		if i < len(grayThresholds) {
+			g2, f2 = grayThresholds[i], meanThresholds[i]
+		}
+
+		weight, meanG, meanF := stats.classStats(g1, f1, g2, f2)
+		if weight > 1e-10 {
+			diffG := meanG - stats.totalMeanG
+			diffF := meanF - stats.totalMeanF
+			variance += weight * (diffG*diffG + diffF*diffF)
+		}
+		g1, f1 = g2+1, f2+1
+	}
+	return variance
+}
+
+// multiLevelLookup maps every (g, f) pair to a class. Pairs inside a
+// diagonal block take its class; pairs off the diagonal go to the class
+// with the nearest mean among the non-empty classes.
+func multiLevelLookup(stats *CumulativeStats, grayThresholds, meanThresholds []int) []uint8 {
+	classes := len(grayThresholds) + 1
+	weights := make([]float64, classes)
+	meansG := make([]float64, classes)
+	meansF := make([]float64, classes)
+	g1, f1 := 0, 0
+	for i := 0; i < classes; i++ {
+		g2, f2 := 255, 255
+		if i < len(grayThresholds) {
+			g2, f2 = grayThresholds[i], meanThresholds[i]
+		}
+		weights[i], meansG[i], meansF[i] = stats.classStats(g1, f1, g2, f2)
+		g1, f1 = g2+1, f2+1
+	}
+
+	classOf := func(value int, thresholds []int) int {
+		class := 0
+		for class < len(thresholds) && value > thresholds[class] {
+			class++
+		}
+		return class
+	}
+
+	lookup := make([]uint8, 65536)
+	for g := 0; g < 256; g++ {
+		grayClass := classOf(g, grayThresholds)
+		for f := 0; f < 256; f++ {
+			class := grayClass
+			if classOf(f, meanThresholds) != grayClass {
+				nearest := -1.0
+				for i := 0; i < classes; i++ {
+					if weights[i] <= 1e-10 {
+						continue
+					}
+					diffG := float64(g) - meansG[i]
+					diffF := float64(f) - meansF[i]
+					if distance := diffG*diffG + diffF*diffF; nearest < 0 || distance < nearest {
+						nearest = distance
+						class = i
+					}
+				}
+			}
+			lookup[g<<8|f] = uint8(class)
+		}
+	}
+	return lookup
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"time"
@@ -8,44 +9,37 @@ import (
 	"gocv.io/x/gocv"
 )
 
-func (t *TwoDOtsu) apply2DOtsu(gray, guided gocv.Mat) gocv.Mat {
-	t.debugImage.LogAlgorithmStep("2D Otsu", "Using GoCV CalcHist API for histogram construction")
+// apply2DOtsu also returns the histogram and thresholds it used, which
+// the caller records when they describe the whole image.
+func (t *TwoDOtsu) apply2DOtsu(ctx context.Context, gray, guided gocv.Mat) (gocv.Mat, twoDOtsuAnalysis, error) {
+	t.debugImage.LogAlgorithmStep("2D Otsu", "Using flat histogram from raw pixel data")
 	t.debugPerf.LogAlgorithmPhase("2D Otsu Standard", "Histogram construction phase", gray)
 
 	if gray.Empty() || guided.Empty() {
-		return gocv.NewMat()
+		return gocv.NewMat(), twoDOtsuAnalysis{}, fmt.Errorf("2D Otsu input is empty")
 	}
 
-	// Use GoCV's hardware-accelerated CalcHist instead of manual construction
 	t.debugPerf.StartOperation("2D_Otsu_Histogram", "joint_histogram_construction")
-	jointHist := t.buildJoint2DHistogram(gray, guided)
+	hist, err := t.buildHistogram2D(ProgressRange(ctx, 0, 0.8), gray, guided)
 	t.debugPerf.EndOperation("2D_Otsu_Histogram")
-	defer func() {
-		for i := range jointHist {
-			jointHist[i] = nil
-		}
-	}()
+	if err != nil {
+		return gocv.NewMat(), twoDOtsuAnalysis{}, err
+	}
 
 	totalPixels := gray.Total()
 	if totalPixels == 0 {
 		t.debugImage.LogAlgorithmStep("2D Otsu", "ERROR: No pixels to process")
-		return gocv.NewMat()
+		return gocv.NewMat(), twoDOtsuAnalysis{}, fmt.Errorf("2D Otsu input has no pixels")
 	}
 
 	t.debugPerf.LogStep("2D_Otsu_Standard", "Histogram normalization", fmt.Sprintf("total_pixels=%d", totalPixels))
 
-	// Normalize using vectorized operations
-	t.debugPerf.StartOperation("2D_Otsu_Normalize", "histogram_normalization")
-	invTotalPixels := 1.0 / float64(totalPixels)
-	for g := 0; g < 256; g++ {
-		for f := 0; f < 256; f++ {
-			jointHist[g][f] *= invTotalPixels
-		}
-	}
-	t.debugPerf.EndOperation("2D_Otsu_Normalize")
+	t.debugPerf.StartOperation("2D_Otsu_Precompute", "cumulative_statistics")
+	stats := newCumulativeStats(hist)
+	t.debugPerf.EndOperation("2D_Otsu_Precompute")
 
 	t.debugPerf.StartOperation("2D_Otsu_ThresholdSearch", "optimal_threshold_calculation")
-	bestS, bestT, maxVariance := t.findOptimalThresholdsRecursive(jointHist)
+	bestS, bestT, maxVariance := t.findOptimalThresholdsRecursive(stats)
 	t.debugPerf.EndOperation("2D_Otsu_ThresholdSearch")
 	t.debugImage.LogOptimalThresholds(bestS, bestT, maxVariance)
 
@@ -56,65 +50,19 @@ func (t *TwoDOtsu) apply2DOtsu(gray, guided gocv.Mat) gocv.Mat {
 	result := t.performVectorized2DOtsuClassification(gray, guided, bestS, bestT)
 	t.debugPerf.LogMatrixOperation("Classification", gray, result)
 	t.debugPerf.EndOperation("2D_Otsu_Classification")
+	ReportProgress(ctx, 1.0)
 
 	t.debugImage.LogAlgorithmStep("2D Otsu", "Binarization completed using modern APIs")
-	return result
+	return result, twoDOtsuAnalysis{
+		hist: hist, s: bestS, t: bestT, hasThresholds: true,
+		note: fmt.Sprintf("Otsu thresholds s=%d, t=%d", bestS, bestT),
+	}, nil
 }
 
-func (t *TwoDOtsu) buildJoint2DHistogram(gray, guided gocv.Mat) [][]float64 {
-	t.debugImage.LogAlgorithmStep("Joint 2D Histogram", "Building using block processing for cache efficiency")
-
-	hist := make([][]float64, 256)
-	for i := range hist {
-		hist[i] = make([]float64, 256)
-	}
-
-	size := gray.Size()
-	width, height := size[1], size[0]
-	totalPixels := width * height
-
-	t.debugPerf.LogStep("Joint_2D_Histogram", "Processing setup", fmt.Sprintf("size=%dx%d, total_pixels=%d", width, height, totalPixels))
-
-	// Process in cache-friendly blocks for better performance
-	blockSize := 32
-	totalBlocks := ((height + blockSize - 1) / blockSize) * ((width + blockSize - 1) / blockSize)
-	processedBlocks := 0
-	startTime := time.Now()
-
-	for yBlock := 0; yBlock < height; yBlock += blockSize {
-		yEnd := min(yBlock+blockSize, height)
-		for xBlock := 0; xBlock < width; xBlock += blockSize {
-			xEnd := min(xBlock+blockSize, width)
-
-			// Process block with boundary checks
-			for y := yBlock; y < yEnd; y++ {
-				for x := xBlock; x < xEnd; x++ {
-					grayVal := int(gray.GetUCharAt(y, x))
-					guidedVal := int(guided.GetUCharAt(y, x))
-
-					if grayVal >= 0 && grayVal < 256 && guidedVal >= 0 && guidedVal < 256 {
-						hist[grayVal][guidedVal]++
-					}
-				}
-			}
-
-			processedBlocks++
-			t.debugPerf.LogLoopProgress("Joint_2D_Histogram", processedBlocks, totalBlocks, startTime)
-		}
-	}
-
-	return hist
-}
-
-func (t *TwoDOtsu) findOptimalThresholdsRecursive(hist [][]float64) (int, int, float64) {
+func (t *TwoDOtsu) findOptimalThresholdsRecursive(cumulativeStats *CumulativeStats) (int, int, float64) {
 	t.debugImage.LogAlgorithmStep("Recursive Threshold Search", "Using dynamic programming for acceleration")
 	t.debugPerf.LogAlgorithmPhase("Threshold Search", "Dynamic programming optimization", gocv.NewMat())
 
-	// Pre-compute cumulative statistics for dynamic programming
-	t.debugPerf.StartOperation("2D_Otsu_Precompute", "cumulative_statistics")
-	cumulativeStats := t.precomputeCumulativeStatistics(hist)
-	t.debugPerf.EndOperation("2D_Otsu_Precompute")
-
 	maxBetweenClassVariance := 0.0
 	bestS, bestT := 0, 0
 
@@ -148,121 +96,14 @@ func (t *TwoDOtsu) findOptimalThresholdsRecursive(hist [][]float64) (int, int, f
 	return bestS, bestT, maxBetweenClassVariance
 }
 
-type CumulativeStats struct {
-	cumulativeP   [][]float64
-	cumulativeMuG [][]float64
-	cumulativeMuF [][]float64
-	totalMeanG    float64
-	totalMeanF    float64
-}
-
-func (t *TwoDOtsu) precomputeCumulativeStatistics(hist [][]float64) *CumulativeStats {
-	t.debugPerf.LogStep("2D_Otsu_Precompute", "Building cumulative tables", "256x256_lookup_tables")
-
-	stats := &CumulativeStats{
-		cumulativeP:   make([][]float64, 256),
-		cumulativeMuG: make([][]float64, 256),
-		cumulativeMuF: make([][]float64, 256),
-	}
-
-	for i := range stats.cumulativeP {
-		stats.cumulativeP[i] = make([]float64, 256)
-		stats.cumulativeMuG[i] = make([]float64, 256)
-		stats.cumulativeMuF[i] = make([]float64, 256)
-	}
-
-	// Build cumulative tables using dynamic programming
-	for g := 0; g < 256; g++ {
-		for f := 0; f < 256; f++ {
-			prob := hist[g][f]
-			muG := float64(g) * prob
-			muF := float64(f) * prob
-
-			stats.cumulativeP[g][f] = prob
-			stats.cumulativeMuG[g][f] = muG
-			stats.cumulativeMuF[g][f] = muF
-
-			if g > 0 {
-				stats.cumulativeP[g][f] += stats.cumulativeP[g-1][f]
-				stats.cumulativeMuG[g][f] += stats.cumulativeMuG[g-1][f]
-				stats.cumulativeMuF[g][f] += stats.cumulativeMuF[g-1][f]
-			}
-			if f > 0 {
-				stats.cumulativeP[g][f] += stats.cumulativeP[g][f-1]
-				stats.cumulativeMuG[g][f] += stats.cumulativeMuG[g][f-1]
-				stats.cumulativeMuF[g][f] += stats.cumulativeMuF[g][f-1]
-			}
-			if g > 0 && f > 0 {
-				stats.cumulativeP[g][f] -= stats.cumulativeP[g-1][f-1]
-				stats.cumulativeMuG[g][f] -= stats.cumulativeMuG[g-1][f-1]
-				stats.cumulativeMuF[g][f] -= stats.cumulativeMuF[g-1][f-1]
-			}
-
-			stats.totalMeanG += muG
-			stats.totalMeanF += muF
-		}
-	}
-
-	return stats
-}
-
 func (t *TwoDOtsu) calculateBetweenClassVarianceDP(stats *CumulativeStats, s, thresholdT int) float64 {
 	// Use cumulative tables for O(1) region queries instead of O(n^2) summations
-	getRegionStats := func(cumP, cumMu [][]float64, x1, y1, x2, y2 int) (float64, float64) {
-		if x1 < 0 || y1 < 0 || x2 >= 256 || y2 >= 256 || x1 > x2 || y1 > y2 {
-			return 0.0, 0.0
-		}
-
-		prob := cumP[x2][y2]
-		mu := cumMu[x2][y2]
-
-		if x1 > 0 {
-			prob -= cumP[x1-1][y2]
-			mu -= cumMu[x1-1][y2]
-		}
-		if y1 > 0 {
-			prob -= cumP[x2][y1-1]
-			mu -= cumMu[x2][y1-1]
-		}
-		if x1 > 0 && y1 > 0 {
-			prob += cumP[x1-1][y1-1]
-			mu += cumMu[x1-1][y1-1]
-		}
-
-		return prob, mu
-	}
-
-	// Calculate class probabilities and means using cumulative tables
-	w0, mu0G := getRegionStats(stats.cumulativeP, stats.cumulativeMuG, 0, 0, s, thresholdT)
-	_, mu0F := getRegionStats(stats.cumulativeP, stats.cumulativeMuF, 0, 0, s, thresholdT)
-
-	w3, mu3G := getRegionStats(stats.cumulativeP, stats.cumulativeMuG, s+1, thresholdT+1, 255, 255)
-	_, mu3F := getRegionStats(stats.cumulativeP, stats.cumulativeMuF, s+1, thresholdT+1, 255, 255)
+	w0, mu0G, mu0F := stats.classStats(0, 0, s, thresholdT)
+	w3, mu3G, mu3F := stats.classStats(s+1, thresholdT+1, 255, 255)
 
 	// Mixed regions for robust handling of noisy historical images
-	w1, mu1G := getRegionStats(stats.cumulativeP, stats.cumulativeMuG, s+1, 0, 255, thresholdT)
-	_, mu1F := getRegionStats(stats.cumulativeP, stats.cumulativeMuF, s+1, 0, 255, thresholdT)
-
-	w2, mu2G := getRegionStats(stats.cumulativeP, stats.cumulativeMuG, 0, thresholdT+1, s, 255)
-	_, mu2F := getRegionStats(stats.cumulativeP, stats.cumulativeMuF, 0, thresholdT+1, s, 255)
-
-	// Normalize means by probabilities
-	if w0 > 1e-10 {
-		mu0G /= w0
-		mu0F /= w0
-	}
-	if w3 > 1e-10 {
-		mu3G /= w3
-		mu3F /= w3
-	}
-	if w1 > 1e-10 {
-		mu1G /= w1
-		mu1F /= w1
-	}
-	if w2 > 1e-10 {
-		mu2G /= w2
-		mu2F /= w2
-	}
+	w1 := stats.region(stats.cumulativeP, s+1, 0, 255, thresholdT)
+	w2 := stats.region(stats.cumulativeP, 0, thresholdT+1, s, 255)
 
 	// Calculate robust between-class variance with noise handling
 	betweenClassVariance := 0.0
@@ -1,5 +1,77 @@
 package main
 
+var twoDOtsuParameters = []ParameterSpec{
+	{
+		Name: "windowRadius", Label: "Window Radius", Type: ParameterInt,
+		Min: 1, Max: 20, Step: 1, Default: 5,
+		Help: "Radius of the guided filter window that supplies the neighbourhood mean",
+	},
+	{
+		Name: "epsilon", Label: "Epsilon", Type: ParameterFloat,
+		Min: 0.001, Max: 1.0, Step: 0.001, Default: 0.02,
+		Help: "Guided filter regularization; larger values smooth across edges",
+	},
+	{
+		Name: "morphKernelSize", Label: "Morphological Kernel Size", Type: ParameterInt,
+		Min: 1, Max: 15, Step: 2, OddOnly: true, Default: 3,
+		Help: "Size of the opening and closing kernel applied to the binary result",
+	},
+	{
+		Name: "noiseReduction", Label: "Enable Historical Noise Reduction", Type: ParameterBool,
+		Default: true,
+		Help:    "Denoise paper texture before thresholding",
+	},
+	{
+		Name: "useIntegralImage", Label: "Use Integral Image Acceleration", Type: ParameterBool,
+		Default: true,
+		Help:    "Search thresholds with the integral image variance; off uses the recursive search with mixed-class penalty",
+	},
+	{
+		Name: "adaptiveRegions", Label: "Adaptive Regions", Type: ParameterInt,
+		Min: 1, Max: 8, Step: 1, Default: 4,
+		Help: "Split the image into N x N regions with their own threshold; 1 uses a global threshold",
+	},
+	{
+		Name: "regionMode", Label: "Region Mode", Type: ParameterEnum,
+		Values: []string{regionModeBlended, regionModeTiles}, Default: regionModeBlended,
+		Help: "blended interpolates thresholds between overlapping regions; tiles thresholds each region on its own",
+	},
+	{
+		Name: "regionOverlap", Label: "Region Overlap", Type: ParameterFloat,
+		Min: 0, Max: 1.0, Step: 0.05, Default: 0.5,
+		Help: "Fraction of a region added on every side of its histogram window in blended mode",
+	},
+	{
+		Name: "classes", Label: "Classes", Type: ParameterInt,
+		Min: 2, Max: 4, Step: 1, Default: 2,
+		Help: "3 or 4 separates ink, stains and paper with thresholds of the whole image; adaptive regions apply to 2 classes only",
+	},
+	{
+		Name: "multiLevelOutput", Label: "Multi-level Output", Type: ParameterEnum,
+		Values: []string{multiLevelPosterized, multiLevelLabels}, Default: multiLevelPosterized,
+		Help: "posterized spreads the classes from black to white; labels writes the class index 0, 1, 2, ... per pixel",
+	},
+	{
+		Name: "manualThresholds", Label: "Manual Thresholds", Type: ParameterBool,
+		Default: false,
+		Help:    "Binarize the whole image with the manual s and t instead of searching; takes precedence over regions and classes",
+	},
+	{
+		Name: "manualS", Label: "Manual Gray Threshold s", Type: ParameterInt,
+		Min: 0, Max: 255, Step: 1, Default: 128,
+		Help: "Pixels brighter than s are background when their guided mean is also above t",
+	},
+	{
+		Name: "manualT", Label: "Manual Mean Threshold t", Type: ParameterInt,
+		Min: 0, Max: 255, Step: 1, Default: 128,
+		Help: "Threshold on the guided-filter mean; drag the crosshair in the histogram to set s and t together",
+	},
+}
+
+func (t *TwoDOtsu) ParameterSpecs() []ParameterSpec {
+	return twoDOtsuParameters
+}
+
 func (t *TwoDOtsu) GetParameters() map[string]interface{} {
 	t.paramMutex.RLock()
 	defer t.paramMutex.RUnlock()
@@ -11,6 +83,13 @@ func (t *TwoDOtsu) GetParameters() map[string]interface{} {
 		"noiseReduction":   t.noiseReduction,
 		"useIntegralImage": t.useIntegralImage,
 		"adaptiveRegions":  t.adaptiveRegions,
+		"regionMode":       t.regionMode,
+		"regionOverlap":    t.regionOverlap,
+		"classes":          t.classes,
+		"multiLevelOutput": t.multiLevelOutput,
+		"manualThresholds": t.manualThresholds,
+		"manualS":          t.manualS,
+		"manualT":          t.manualT,
 	}
 }
 
@@ -18,30 +97,34 @@ func (t *TwoDOtsu) SetParameters(params map[string]interface{}) {
 	t.paramMutex.Lock()
 	defer t.paramMutex.Unlock()
 
-	if radius, ok := params["windowRadius"].(int); ok {
-		if radius >= 1 && radius <= 20 {
-			t.windowRadius = radius
-		}
-	}
-	if eps, ok := params["epsilon"].(float64); ok {
-		if eps > 0.001 && eps <= 1.0 {
-			t.epsilon = eps
-		}
-	}
-	if kernel, ok := params["morphKernelSize"].(int); ok {
-		if kernel >= 1 && kernel <= 15 && kernel%2 == 1 {
-			t.morphKernelSize = kernel
-		}
-	}
-	if noise, ok := params["noiseReduction"].(bool); ok {
-		t.noiseReduction = noise
-	}
-	if integral, ok := params["useIntegralImage"].(bool); ok {
-		t.useIntegralImage = integral
-	}
-	if regions, ok := params["adaptiveRegions"].(int); ok {
-		if regions >= 1 && regions <= 8 {
-			t.adaptiveRegions = regions
+	for name, value := range validParameters(twoDOtsuParameters, params) {
+		switch name {
+		case "windowRadius":
+			t.windowRadius = value.(int)
+		case "epsilon":
+			t.epsilon = value.(float64)
+		case "morphKernelSize":
+			t.morphKernelSize = value.(int)
+		case "noiseReduction":
+			t.noiseReduction = value.(bool)
+		case "useIntegralImage":
+			t.useIntegralImage = value.(bool)
+		case "adaptiveRegions":
+			t.adaptiveRegions = value.(int)
+		case "regionMode":
+			t.regionMode = value.(string)
+		case "regionOverlap":
+			t.regionOverlap = value.(float64)
+		case "classes":
+			t.classes = value.(int)
+		case "multiLevelOutput":
+			t.multiLevelOutput = value.(string)
+		case "manualThresholds":
+			t.manualThresholds = value.(bool)
+		case "manualS":
+			t.manualS = value.(int)
+		case "manualT":
+			t.manualT = value.(int)
 		}
 	}
 }
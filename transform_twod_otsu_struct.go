@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"sync"
 
 	"gocv.io/x/gocv"
@@ -18,21 +19,45 @@ type TwoDOtsu struct {
 	noiseReduction   bool
 	useIntegralImage bool
 	adaptiveRegions  int
+	regionMode       string
+	regionOverlap    float64
+	classes          int
+	multiLevelOutput string
+	manualThresholds bool
+	manualS          int
+	manualT          int
 
-	onParameterChanged func()
+	// analysis is the histogram view of the latest run; onAnalysis is
+	// called after it changes, from the processing goroutine
+	analysisMutex sync.Mutex
+	analysis      twoDOtsuAnalysis
+	onAnalysis    func()
+}
+
+const (
+	regionModeBlended = "blended"
+	regionModeTiles   = "tiles"
+)
+
+func init() {
+	RegisterTransformation(TransformationInfo{
+		ID:          "2d-otsu",
+		DisplayName: "2D Otsu",
+		Category:    "Binarization",
+		Description: "Two-dimensional Otsu thresholding on gray level and guided-filter mean",
+		Factory: func(config *DebugConfig) Transformation {
+			return NewTwoDOtsu(config)
+		},
+	})
 }
 
 func NewTwoDOtsu(config *DebugConfig) *TwoDOtsu {
-	return &TwoDOtsu{
-		debugImage:       NewDebugImage(config),
-		debugPerf:        NewDebugPerformance(config),
-		windowRadius:     5,
-		epsilon:          0.02,
-		morphKernelSize:  3,
-		noiseReduction:   true,
-		useIntegralImage: true,
-		adaptiveRegions:  4,
+	t := &TwoDOtsu{
+		debugImage: NewDebugImage(config),
+		debugPerf:  NewDebugPerformance(config),
 	}
+	t.SetParameters(defaultParameters(twoDOtsuParameters))
+	return t
 }
 
 func (t *TwoDOtsu) Name() string {
@@ -43,10 +68,10 @@ func (t *TwoDOtsu) Close() {
 	// No resources to cleanup - GoCV MatProfile handles tracking
 }
 
-func (t *TwoDOtsu) Apply(src gocv.Mat) gocv.Mat {
-	return t.applyWithScale(src, 1.0)
+func (t *TwoDOtsu) Apply(ctx context.Context, src gocv.Mat) (gocv.Mat, error) {
+	return t.applyWithScale(ctx, src, 1.0)
 }
 
-func (t *TwoDOtsu) ApplyPreview(src gocv.Mat) gocv.Mat {
-	return t.applyWithScale(src, 0.5)
+func (t *TwoDOtsu) ApplyPreview(ctx context.Context, src gocv.Mat) (gocv.Mat, error) {
+	return t.applyWithScale(ctx, src, 0.5)
 }
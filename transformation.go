@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"sync"
 
 	"fyne.io/fyne/v2"
@@ -9,9 +10,15 @@ import (
 
 type Transformation interface {
 	Name() string
-	Apply(input gocv.Mat) gocv.Mat        // Full resolution for saving
-	ApplyPreview(input gocv.Mat) gocv.Mat // Optimized for real-time preview
+	// Apply and ApplyPreview report progress through ReportProgress and
+	// return ctx.Err() once ctx is cancelled. Failures are reported with
+	// InvalidParameterError, DimensionLimitError or OpenCVError.
+	Apply(ctx context.Context, input gocv.Mat) (gocv.Mat, error)        // Full resolution for saving
+	ApplyPreview(ctx context.Context, input gocv.Mat) (gocv.Mat, error) // Optimized for real-time preview
 	GetParametersWidget(onParameterChanged func()) fyne.CanvasObject
+	// ParameterSpecs describes every key of GetParameters. Forms, recipe
+	// validation and batch flags are derived from it.
+	ParameterSpecs() []ParameterSpec
 	GetParameters() map[string]interface{}
 	SetParameters(params map[string]interface{})
 	Close() // For cleanup of resources
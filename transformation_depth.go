@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"gocv.io/x/gocv"
+)
+
+// DepthAware is implemented by transformations that work on more than
+// 8 bits per channel. SupportedDepths lists the Mat depths Apply accepts
+// and keeps; every other transformation is given 8-bit input.
+type DepthAware interface {
+	SupportedDepths() []gocv.MatType
+}
+
+// allDepths are the depths images are loaded in: 16-bit integer and float
+// samples are kept, float in 0..1
+var allDepths = []gocv.MatType{gocv.MatTypeCV8U, gocv.MatTypeCV16U, gocv.MatTypeCV32F}
+
+func supportedDepths(t Transformation) []gocv.MatType {
+	if aware, ok := t.(DepthAware); ok {
+		return aware.SupportedDepths()
+	}
+	return []gocv.MatType{gocv.MatTypeCV8U}
+}
+
+// inputForStep returns input in a depth t accepts: as it is when t supports
+// its depth, as float when t takes float, and as 8 bits otherwise.
+// converted reports whether the result is a new Mat for the caller to close.
+func inputForStep(t Transformation, input gocv.Mat) (result gocv.Mat, converted bool, err error) {
+	depths := supportedDepths(t)
+	depth := matDepth(input)
+	if slices.Contains(depths, depth) {
+		return input, false, nil
+	}
+
+	target := gocv.MatTypeCV8U
+	if slices.Contains(depths, gocv.MatTypeCV32F) {
+		target = gocv.MatTypeCV32F
+	}
+	result, err = convertDepth(input, target)
+	if err != nil {
+		return gocv.NewMat(), false, err
+	}
+	return result, true, nil
+}
+
+// depthMax is the sample value of full intensity at depth
+func depthMax(depth gocv.MatType) float64 {
+	switch depth {
+	case gocv.MatTypeCV16U:
+		return 65535
+	case gocv.MatTypeCV32F, gocv.MatTypeCV64F:
+		return 1
+	}
+	return 255
+}
+
+func depthName(depth gocv.MatType) string {
+	switch depth {
+	case gocv.MatTypeCV8U:
+		return "8-bit"
+	case gocv.MatTypeCV16U:
+		return "16-bit"
+	case gocv.MatTypeCV32F:
+		return "32-bit float"
+	case gocv.MatTypeCV64F:
+		return "64-bit float"
+	}
+	return fmt.Sprintf("depth %d", depth)
+}
+
+// convertDepth converts src to depth, rescaling so full intensity stays
+// full intensity. The result is always a new Mat.
+func convertDepth(src gocv.Mat, depth gocv.MatType) (gocv.Mat, error) {
+	from := matDepth(src)
+	if from == depth {
+		return src.Clone(), nil
+	}
+
+	result := gocv.NewMat()
+	scale := depthMax(depth) / depthMax(from)
+	if err := src.ConvertToWithParams(&result, depth, float32(scale), 0); err != nil {
+		result.Close()
+		return gocv.NewMat(), openCVError(fmt.Sprintf("%s to %s conversion", depthName(from), depthName(depth)), err)
+	}
+	return result, nil
+}
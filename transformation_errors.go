@@ -0,0 +1,81 @@
+package main
+
+import "fmt"
+
+// InvalidParameterError reports a parameter value a transformation cannot
+// work with, such as a scale factor of zero.
+type InvalidParameterError struct {
+	Parameter string
+	Value     interface{}
+	Reason    string
+}
+
+func (e *InvalidParameterError) Error() string {
+	return fmt.Sprintf("invalid %s %v: %s", e.Parameter, e.Value, e.Reason)
+}
+
+// DimensionLimitError reports an image size outside what a transformation
+// supports, either as input or as the size it would produce.
+type DimensionLimitError struct {
+	Width  int
+	Height int
+	Limit  int
+	What   string
+}
+
+func (e *DimensionLimitError) Error() string {
+	if e.Width <= 0 || e.Height <= 0 {
+		return fmt.Sprintf("invalid %s dimensions %dx%d", e.What, e.Width, e.Height)
+	}
+	return fmt.Sprintf("%s dimensions %dx%d exceed the limit of %d pixels per side", e.What, e.Width, e.Height, e.Limit)
+}
+
+// OpenCVError wraps a failed GoCV call with the operation that was running
+type OpenCVError struct {
+	Operation string
+	Err       error
+}
+
+func (e *OpenCVError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("OpenCV %s failed", e.Operation)
+	}
+	return fmt.Sprintf("OpenCV %s failed: %v", e.Operation, e.Err)
+}
+
+func (e *OpenCVError) Unwrap() error {
+	return e.Err
+}
+
+// StepError identifies the pipeline step that failed
+type StepError struct {
+	Index   int
+	Name    string
+	Preview bool
+	Err     error
+}
+
+func (e *StepError) Error() string {
+	pass := ""
+	if e.Preview {
+		pass = " preview"
+	}
+	return fmt.Sprintf("step %d (%s)%s failed: %v", e.Index+1, e.Name, pass, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+func openCVError(operation string, err error) error {
+	return &OpenCVError{Operation: operation, Err: err}
+}
+
+// checkDimensions returns a DimensionLimitError unless width and height are
+// positive and at most limit
+func checkDimensions(what string, width, height, limit int) error {
+	if width <= 0 || height <= 0 || width > limit || height > limit {
+		return &DimensionLimitError{Width: width, Height: height, Limit: limit, What: what}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+// applyParameters validates params against the transformation's parameter
+// specs and applies them. Unlike SetParameters, which ignores values it
+// cannot use, it reports the first unknown or invalid parameter.
+func applyParameters(transformation Transformation, params map[string]interface{}) error {
+	specs := transformation.ParameterSpecs()
+	converted := make(map[string]interface{}, len(params))
+
+	for name, value := range params {
+		spec, ok := findParameterSpec(specs, name)
+		if !ok {
+			return &InvalidParameterError{Parameter: name, Value: value, Reason: "unknown parameter"}
+		}
+		valid, err := spec.Validate(value)
+		if err != nil {
+			return err
+		}
+		converted[name] = valid
+	}
+
+	transformation.SetParameters(converted)
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"math"
+)
+
+// ProgressFunc receives the completed fraction of a processing job, 0 to 1
+type ProgressFunc func(fraction float64)
+
+type progressKey struct{}
+
+// progressScope maps a nested operation's 0..1 progress onto its share of
+// the job, so transformations report locally without knowing their position.
+type progressScope struct {
+	report ProgressFunc
+	start  float64
+	end    float64
+}
+
+// WithProgress returns a context that delivers ReportProgress calls to report
+func WithProgress(ctx context.Context, report ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, progressScope{report: report, start: 0, end: 1})
+}
+
+// ProgressRange returns a context whose 0..1 progress covers the part
+// [start, end] of the progress range of ctx.
+func ProgressRange(ctx context.Context, start, end float64) context.Context {
+	scope, ok := ctx.Value(progressKey{}).(progressScope)
+	if !ok {
+		return ctx
+	}
+
+	span := scope.end - scope.start
+	return context.WithValue(ctx, progressKey{}, progressScope{
+		report: scope.report,
+		start:  scope.start + start*span,
+		end:    scope.start + end*span,
+	})
+}
+
+// ReportProgress reports the completed fraction of the current operation.
+// It is a no-op when ctx carries no progress callback.
+func ReportProgress(ctx context.Context, fraction float64) {
+	scope, ok := ctx.Value(progressKey{}).(progressScope)
+	if !ok {
+		return
+	}
+
+	fraction = math.Max(0, math.Min(fraction, 1))
+	scope.report(scope.start + fraction*(scope.end-scope.start))
+}
+
+// StageFunc receives the name of the step a job is working on
+type StageFunc func(stage string)
+
+type stageKey struct{}
+
+// stageScope prefixes the stages of a nested operation with the stage of
+// the operation around it
+type stageScope struct {
+	report StageFunc
+	prefix string
+}
+
+// WithStage returns a context that delivers ReportStage calls to report
+func WithStage(ctx context.Context, report StageFunc) context.Context {
+	return context.WithValue(ctx, stageKey{}, stageScope{report: report})
+}
+
+// SubStage reports stage and returns a context whose stages are reported as
+// parts of it
+func SubStage(ctx context.Context, stage string) context.Context {
+	scope, ok := ctx.Value(stageKey{}).(stageScope)
+	if !ok {
+		return ctx
+	}
+
+	ReportStage(ctx, stage)
+	return context.WithValue(ctx, stageKey{}, stageScope{report: scope.report, prefix: scope.prefix + stage + ": "})
+}
+
+// ReportStage reports the step the current operation is working on. It is
+// a no-op when ctx carries no stage callback.
+func ReportStage(ctx context.Context, stage string) {
+	if scope, ok := ctx.Value(stageKey{}).(stageScope); ok {
+		scope.report(scope.prefix + stage)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TransformationInfo describes a transformation that can be created by identifier.
+// DisplayName must match the Name() of the transformations the factory returns.
+type TransformationInfo struct {
+	ID          string
+	DisplayName string
+	Category    string
+	Description string
+	Factory     func(config *DebugConfig) Transformation
+}
+
+type TransformationRegistry struct {
+	mutex   sync.RWMutex
+	entries map[string]TransformationInfo
+}
+
+var transformationRegistry = &TransformationRegistry{
+	entries: make(map[string]TransformationInfo),
+}
+
+// RegisterTransformation adds a transformation to the global registry.
+// It is meant to be called from init functions and panics on invalid or duplicate entries.
+func RegisterTransformation(info TransformationInfo) {
+	transformationRegistry.Register(info)
+}
+
+func (r *TransformationRegistry) Register(info TransformationInfo) {
+	if info.ID == "" || info.DisplayName == "" || info.Factory == nil {
+		panic(fmt.Sprintf("invalid transformation registration: %+v", info))
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id := strings.ToLower(info.ID)
+	if _, exists := r.entries[id]; exists {
+		panic(fmt.Sprintf("transformation %q registered twice", info.ID))
+	}
+	r.entries[id] = info
+}
+
+// List returns all registrations ordered by category and display name
+func (r *TransformationRegistry) List() []TransformationInfo {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	list := make([]TransformationInfo, 0, len(r.entries))
+	for _, info := range r.entries {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Category != list[j].Category {
+			return list[i].Category < list[j].Category
+		}
+		return list[i].DisplayName < list[j].DisplayName
+	})
+	return list
+}
+
+func (r *TransformationRegistry) IDs() []string {
+	list := r.List()
+	ids := make([]string, len(list))
+	for i, info := range list {
+		ids[i] = info.ID
+	}
+	return ids
+}
+
+func (r *TransformationRegistry) Lookup(id string) (TransformationInfo, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	info, ok := r.entries[strings.ToLower(id)]
+	return info, ok
+}
+
+// New creates a transformation by identifier
+func (r *TransformationRegistry) New(id string, config *DebugConfig) (Transformation, error) {
+	info, ok := r.Lookup(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown transformation %q (available: %s)", id, strings.Join(r.IDs(), ", "))
+	}
+	return info.Factory(config), nil
+}
+
+// IDOf finds the identifier a transformation was registered under
+func (r *TransformationRegistry) IDOf(transformation Transformation) (string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	name := transformation.Name()
+	for _, info := range r.entries {
+		if info.DisplayName == name {
+			return info.ID, nil
+		}
+	}
+	return "", fmt.Errorf("transformation %q is not registered", name)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+
+	"gocv.io/x/gocv"
+)
+
+type resolutionKey struct{}
+
+// withImageResolution tells the transformations run with ctx the
+// resolution of their input
+func withImageResolution(ctx context.Context, resolution Resolution) context.Context {
+	return context.WithValue(ctx, resolutionKey{}, resolution)
+}
+
+// imageResolution is the resolution of the image being transformed, or the
+// zero Resolution when it is not known
+func imageResolution(ctx context.Context) Resolution {
+	resolution, _ := ctx.Value(resolutionKey{}).(Resolution)
+	return resolution
+}
+
+// ResolutionChanger is implemented by transformations that set the
+// resolution of their output, such as scaling to a target DPI. ok is false
+// when the output keeps the physical size of the input.
+type ResolutionChanger interface {
+	OutputResolution(input Resolution) (output Resolution, ok bool)
+}
+
+// stepResolution is the resolution of a step's output: the one the
+// transformation sets, or the input resolution scaled with the image so the
+// printed size stays the same.
+func stepResolution(transformation Transformation, input Resolution, before, after gocv.Mat) Resolution {
+	if changer, ok := transformation.(ResolutionChanger); ok {
+		if output, ok := changer.OutputResolution(input); ok {
+			return output
+		}
+	}
+	if before.Cols() == 0 || before.Rows() == 0 {
+		return input
+	}
+	return input.scaled(float64(after.Cols())/float64(before.Cols()), float64(after.Rows())/float64(before.Rows()))
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type ParameterType int
+
+const (
+	ParameterInt ParameterType = iota
+	ParameterFloat
+	ParameterBool
+	ParameterEnum
+)
+
+func (t ParameterType) String() string {
+	switch t {
+	case ParameterInt:
+		return "int"
+	case ParameterFloat:
+		return "float"
+	case ParameterBool:
+		return "bool"
+	case ParameterEnum:
+		return "enum"
+	default:
+		return "unknown"
+	}
+}
+
+// ParameterSpec describes one entry of a transformation's GetParameters map.
+// Forms, recipe validation and the batch command line are built from it.
+type ParameterSpec struct {
+	Name    string // key in GetParameters and SetParameters
+	Label   string // human readable name for forms
+	Type    ParameterType
+	Min     float64 // inclusive bounds for int and float parameters
+	Max     float64
+	Step    float64  // suggested increment, 0 if free-form
+	Values  []string // allowed values of enum parameters
+	OddOnly bool     // int parameters that must be odd, such as kernel sizes
+	Default interface{}
+	Help    string
+}
+
+// Validate converts value to the parameter's Go type and checks its
+// constraints. JSON decodes every number as float64, so whole floats are
+// accepted for int parameters.
+func (s ParameterSpec) Validate(value interface{}) (interface{}, error) {
+	invalid := func(reason string) error {
+		return &InvalidParameterError{Parameter: s.Name, Value: value, Reason: reason}
+	}
+
+	switch s.Type {
+	case ParameterInt:
+		var v int
+		switch n := value.(type) {
+		case int:
+			v = n
+		case int64:
+			v = int(n)
+		case float64:
+			if n != math.Trunc(n) {
+				return nil, invalid("expected an integer")
+			}
+			v = int(n)
+		default:
+			return nil, invalid(fmt.Sprintf("expected an integer, got %T", value))
+		}
+		if float64(v) < s.Min || float64(v) > s.Max {
+			return nil, invalid("must be " + s.Range())
+		}
+		if s.OddOnly && v%2 == 0 {
+			return nil, invalid("must be odd")
+		}
+		return v, nil
+
+	case ParameterFloat:
+		var v float64
+		switch n := value.(type) {
+		case float64:
+			v = n
+		case int:
+			v = float64(n)
+		case int64:
+			v = float64(n)
+		default:
+			return nil, invalid(fmt.Sprintf("expected a number, got %T", value))
+		}
+		if math.IsNaN(v) || v < s.Min || v > s.Max {
+			return nil, invalid("must be " + s.Range())
+		}
+		return v, nil
+
+	case ParameterBool:
+		v, ok := value.(bool)
+		if !ok {
+			return nil, invalid(fmt.Sprintf("expected true or false, got %T", value))
+		}
+		return v, nil
+
+	case ParameterEnum:
+		v, ok := value.(string)
+		if !ok || !slices.Contains(s.Values, v) {
+			return nil, invalid("must be one of " + strings.Join(s.Values, ", "))
+		}
+		return v, nil
+	}
+
+	return nil, invalid("unsupported parameter type")
+}
+
+// Parse converts command line or form text and validates the result
+func (s ParameterSpec) Parse(raw string) (interface{}, error) {
+	raw = strings.TrimSpace(raw)
+
+	var value interface{}
+	var err error
+	switch s.Type {
+	case ParameterInt:
+		value, err = strconv.Atoi(raw)
+	case ParameterFloat:
+		value, err = strconv.ParseFloat(raw, 64)
+	case ParameterBool:
+		value, err = strconv.ParseBool(raw)
+	default:
+		value = raw
+	}
+	if err != nil {
+		return nil, &InvalidParameterError{Parameter: s.Name, Value: raw, Reason: fmt.Sprintf("expected %s", s.Type)}
+	}
+	return s.Validate(value)
+}
+
+// Range describes the accepted values, for example "1-15, odd"
+func (s ParameterSpec) Range() string {
+	switch s.Type {
+	case ParameterInt:
+		text := fmt.Sprintf("%d-%d", int(s.Min), int(s.Max))
+		if s.OddOnly {
+			text += ", odd"
+		}
+		return text
+	case ParameterFloat:
+		return fmt.Sprintf("%s-%s", formatFloat(s.Min), formatFloat(s.Max))
+	case ParameterEnum:
+		return strings.Join(s.Values, "|")
+	default:
+		return "true|false"
+	}
+}
+
+// Format renders value the way Parse accepts it
+func (s ParameterSpec) Format(value interface{}) string {
+	if v, ok := value.(float64); ok {
+		return formatFloat(v)
+	}
+	return fmt.Sprint(value)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func findParameterSpec(specs []ParameterSpec, name string) (ParameterSpec, bool) {
+	for _, spec := range specs {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return ParameterSpec{}, false
+}
+
+// validParameters returns the entries of params that pass their spec, with
+// values converted to the declared types. Unknown and invalid entries are
+// dropped, matching the lenient contract of SetParameters.
+func validParameters(specs []ParameterSpec, params map[string]interface{}) map[string]interface{} {
+	valid := make(map[string]interface{}, len(params))
+	for name, value := range params {
+		spec, ok := findParameterSpec(specs, name)
+		if !ok {
+			continue
+		}
+		if converted, err := spec.Validate(value); err == nil {
+			valid[name] = converted
+		}
+	}
+	return valid
+}
+
+// defaultParameters returns the declared default of every parameter
+func defaultParameters(specs []ParameterSpec) map[string]interface{} {
+	defaults := make(map[string]interface{}, len(specs))
+	for _, spec := range specs {
+		defaults[spec.Name] = spec.Default
+	}
+	return defaults
+}
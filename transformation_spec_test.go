@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+var (
+	testIntSpec   = ParameterSpec{Name: "radius", Type: ParameterInt, Min: 1, Max: 15}
+	testOddSpec   = ParameterSpec{Name: "kernel", Type: ParameterInt, Min: 1, Max: 15, OddOnly: true}
+	testFloatSpec = ParameterSpec{Name: "k", Type: ParameterFloat, Min: -1, Max: 1}
+	testBoolSpec  = ParameterSpec{Name: "denoise", Type: ParameterBool}
+	testEnumSpec  = ParameterSpec{Name: "mode", Type: ParameterEnum, Values: []string{"blended", "tiles"}}
+)
+
+func TestParameterSpecValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   ParameterSpec
+		value  interface{}
+		want   interface{}
+		reason string // part of the error reason, empty when valid
+	}{
+		{"int at minimum", testIntSpec, 1, 1, ""},
+		{"int at maximum", testIntSpec, 15, 15, ""},
+		{"int below minimum", testIntSpec, 0, nil, "must be 1-15"},
+		{"int above maximum", testIntSpec, 16, nil, "must be 1-15"},
+		{"int64", testIntSpec, int64(7), 7, ""},
+		{"whole float as int", testIntSpec, 7.0, 7, ""},
+		{"fractional float as int", testIntSpec, 7.5, nil, "expected an integer"},
+		{"string as int", testIntSpec, "7", nil, "expected an integer, got string"},
+		{"odd", testOddSpec, 5, 5, ""},
+		{"even where odd only", testOddSpec, 4, nil, "must be odd"},
+		{"odd out of range", testOddSpec, 17, nil, "must be 1-15, odd"},
+
+		{"float at minimum", testFloatSpec, -1.0, -1.0, ""},
+		{"float at maximum", testFloatSpec, 1.0, 1.0, ""},
+		{"float below minimum", testFloatSpec, -1.01, nil, "must be -1-1"},
+		{"float above maximum", testFloatSpec, 1.01, nil, "must be -1-1"},
+		{"int as float", testFloatSpec, 1, 1.0, ""},
+		{"NaN", testFloatSpec, math.NaN(), nil, "must be -1-1"},
+		{"bool as float", testFloatSpec, true, nil, "expected a number, got bool"},
+
+		{"bool", testBoolSpec, false, false, ""},
+		{"string as bool", testBoolSpec, "true", nil, "expected true or false, got string"},
+
+		{"enum value", testEnumSpec, "tiles", "tiles", ""},
+		{"unknown enum value", testEnumSpec, "grid", nil, "must be one of blended, tiles"},
+		{"int as enum", testEnumSpec, 1, nil, "must be one of blended, tiles"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.spec.Validate(test.value)
+			checkParameterResult(t, test.spec, got, err, test.want, test.reason)
+		})
+	}
+}
+
+func TestParameterSpecParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   ParameterSpec
+		raw    string
+		want   interface{}
+		reason string
+	}{
+		{"int", testIntSpec, " 15 ", 15, ""},
+		{"int above maximum", testIntSpec, "16", nil, "must be 1-15"},
+		{"fraction as int", testIntSpec, "7.5", nil, "expected int"},
+		{"text as int", testIntSpec, "seven", nil, "expected int"},
+		{"empty int", testIntSpec, "", nil, "expected int"},
+		{"even where odd only", testOddSpec, "4", nil, "must be odd"},
+
+		{"float", testFloatSpec, "-0.25", -0.25, ""},
+		{"float below minimum", testFloatSpec, "-1.5", nil, "must be -1-1"},
+		{"NaN", testFloatSpec, "NaN", nil, "must be -1-1"},
+		{"text as float", testFloatSpec, "high", nil, "expected float"},
+
+		{"bool", testBoolSpec, "true", true, ""},
+		{"text as bool", testBoolSpec, "maybe", nil, "expected bool"},
+
+		{"enum", testEnumSpec, "blended", "blended", ""},
+		{"unknown enum value", testEnumSpec, "Blended", nil, "must be one of blended, tiles"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.spec.Parse(test.raw)
+			checkParameterResult(t, test.spec, got, err, test.want, test.reason)
+		})
+	}
+}
+
+func checkParameterResult(t *testing.T, spec ParameterSpec, got interface{}, err error, want interface{}, reason string) {
+	t.Helper()
+	if reason == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Errorf("got %v (%T), want %v (%T)", got, got, want, want)
+		}
+		return
+	}
+
+	var invalid *InvalidParameterError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("got %v, %v; want an InvalidParameterError", got, err)
+	}
+	if invalid.Parameter != spec.Name || !strings.Contains(invalid.Reason, reason) {
+		t.Errorf("error %v, want parameter %s and reason %q", err, spec.Name, reason)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+type tileWorkersKey struct{}
+
+// tileWorkers is the concurrency available to runTiles. Tiles scheduled by
+// an outer runTiles already keep every CPU busy, so nested calls get one.
+func tileWorkers(ctx context.Context) int {
+	if workers, ok := ctx.Value(tileWorkersKey{}).(int); ok {
+		return workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// runTiles calls process for tiles 0..count-1 on at most workers goroutines.
+// worker identifies the calling goroutine (0..workers-1), so callers can keep
+// per-worker accumulators without locking. Every tile owns the Mats it
+// creates and must close them before returning; shared inputs are only read.
+//
+// Progress on ctx advances as tiles complete; the ctx passed to process
+// carries cancellation but no progress, since concurrent tiles cannot report
+// a meaningful fraction. The first error cancels the remaining tiles and is
+// returned, unless ctx itself was cancelled.
+func runTiles(ctx context.Context, count, workers int, process func(ctx context.Context, worker, tile int) error) error {
+	if count <= 0 {
+		return ctx.Err()
+	}
+	workers = max(1, min(workers, count))
+
+	tileCtx := context.WithValue(ctx, progressKey{}, nil)
+	if workers > 1 {
+		tileCtx = context.WithValue(tileCtx, tileWorkersKey{}, 1)
+	}
+	tileCtx, cancel := context.WithCancel(tileCtx)
+	defer cancel()
+
+	tiles := make(chan int)
+	var wg sync.WaitGroup
+	var errMutex sync.Mutex
+	var firstErr error
+	completed := 0
+
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tile := range tiles {
+				if err := process(tileCtx, worker, tile); err != nil {
+					errMutex.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					errMutex.Unlock()
+					cancel()
+					continue
+				}
+
+				errMutex.Lock()
+				completed++
+				ReportProgress(ctx, float64(completed)/float64(count))
+				errMutex.Unlock()
+			}
+		}()
+	}
+
+	for tile := 0; tile < count; tile++ {
+		if tileCtx.Err() != nil {
+			break
+		}
+		select {
+		case tiles <- tile:
+		case <-tileCtx.Done():
+		}
+	}
+	close(tiles)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return firstErr
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// finishWithin fails the test if run does not return within a generous
+// limit, so a deadlock shows up as a failure rather than a hung test
+func finishWithin(t *testing.T, run func() error) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- run() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("runTiles did not return")
+		return nil
+	}
+}
+
+func TestRunTilesRunsEveryTileOnce(t *testing.T) {
+	for _, workers := range []int{1, 3, 8, 200} {
+		const count = 100
+		var runs [count]int32
+		var busy, mostBusy int32
+		var progressMutex sync.Mutex
+		var progress []float64
+
+		ctx := WithProgress(context.Background(), func(fraction float64) {
+			progressMutex.Lock()
+			progress = append(progress, fraction)
+			progressMutex.Unlock()
+		})
+		err := finishWithin(t, func() error {
+			return runTiles(ctx, count, workers, func(ctx context.Context, worker, tile int) error {
+				if worker < 0 || worker >= workers {
+					t.Errorf("worker %d of %d", worker, workers)
+				}
+				now := atomic.AddInt32(&busy, 1)
+				for {
+					most := atomic.LoadInt32(&mostBusy)
+					if now <= most || atomic.CompareAndSwapInt32(&mostBusy, most, now) {
+						break
+					}
+				}
+				atomic.AddInt32(&runs[tile], 1)
+				atomic.AddInt32(&busy, -1)
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatalf("%d workers: %v", workers, err)
+		}
+
+		for tile, n := range runs {
+			if n != 1 {
+				t.Errorf("%d workers: tile %d ran %d times", workers, tile, n)
+			}
+		}
+		if int(mostBusy) > workers {
+			t.Errorf("%d workers: %d tiles ran at once", workers, mostBusy)
+		}
+		if len(progress) != count || progress[len(progress)-1] != 1 {
+			t.Errorf("%d workers: progress %v, want %d reports ending at 1", workers, progress, count)
+		}
+	}
+}
+
+func TestRunTilesFirstErrorCancels(t *testing.T) {
+	failure := errors.New("tile 3 failed")
+	const count = 1000
+	var started int32
+
+	err := finishWithin(t, func() error {
+		return runTiles(context.Background(), count, 4, func(ctx context.Context, worker, tile int) error {
+			atomic.AddInt32(&started, 1)
+			switch {
+			case tile == 3:
+				return failure
+			case tile > 3:
+				// Later tiles only finish once cancelled, after tile 3 failed
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		})
+	})
+
+	if err != failure {
+		t.Errorf("error %v, want %v", err, failure)
+	}
+	if started == count {
+		t.Errorf("all %d tiles started after the first error", count)
+	}
+}
+
+func TestRunTilesCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var ran int32
+	err := finishWithin(t, func() error {
+		return runTiles(ctx, 10, 4, func(ctx context.Context, worker, tile int) error {
+			atomic.AddInt32(&ran, 1)
+			return nil
+		})
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error %v, want %v", err, context.Canceled)
+	}
+	if ran != 0 {
+		t.Errorf("%d tiles ran with a cancelled context", ran)
+	}
+
+	// Cancelling while tiles run wins over the errors it causes
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	err = finishWithin(t, func() error {
+		return runTiles(ctx, 100, 4, func(tileCtx context.Context, worker, tile int) error {
+			if tile == 10 {
+				cancel()
+			}
+			if err := tileCtx.Err(); err != nil {
+				return errors.New("tile saw cancellation")
+			}
+			return nil
+		})
+	})
+	if err != context.Canceled {
+		t.Errorf("error %v, want %v", err, context.Canceled)
+	}
+
+	if err := runTiles(ctx, 0, 4, nil); err != context.Canceled {
+		t.Errorf("no tiles: error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRunTilesNested(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		var inner int32
+		err := finishWithin(t, func() error {
+			return runTiles(context.Background(), 8, workers, func(ctx context.Context, worker, tile int) error {
+				if nested := tileWorkers(ctx); workers > 1 && nested != 1 {
+					t.Errorf("nested tiles get %d workers, want 1", nested)
+				}
+				return runTiles(ctx, 8, tileWorkers(ctx), func(ctx context.Context, worker, tile int) error {
+					atomic.AddInt32(&inner, 1)
+					return nil
+				})
+			})
+		})
+		if err != nil {
+			t.Fatalf("%d workers: %v", workers, err)
+		}
+		if inner != 64 {
+			t.Errorf("%d workers: %d inner tiles ran, want 64", workers, inner)
+		}
+	}
+}